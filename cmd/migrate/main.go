@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/migration"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+)
+
+// exportValidity is how long the export message printed for the factory owner to sign stays valid
+const exportValidity = 30 * time.Minute
+
+// migrate inspects the migration offer of a new agent, printing the measurement to allowlist on the old agent, and
+// with -source asks the old agent to export its secrets to it. Without -signature it prints the message the factory
+// owner has to sign instead, to be passed back with -signature and the printed -deadline.
+func main() {
+	targetUrl := flag.String("target", "", "url of the new agent waiting for a migration")
+	sourceUrl := flag.String("source", "", "url of the old agent to export the secrets from")
+	collateralFile := flag.String("collateral", "", "collateral JSON with the Intel root CA and CRLs, downloaded if omitted")
+	deadline := flag.Int64("deadline", 0, "unix time the export signature is valid until")
+	signature := flag.String("signature", "", "factory owner's personal_sign signature of the export message")
+	flag.Parse()
+
+	if *targetUrl == "" {
+		slog.Error("-target is required")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	measurement, err := inspectTarget(ctx, *targetUrl, *collateralFile)
+	if err != nil {
+		slog.Error("failed to inspect target", "error", err)
+		os.Exit(1)
+	}
+	fmt.Println("target measurement:", measurement)
+
+	if *sourceUrl == "" {
+		return
+	}
+
+	if *signature == "" {
+		if *deadline == 0 {
+			*deadline = time.Now().Add(exportValidity).Unix()
+		}
+
+		sourceAddress, err := fetchAddress(ctx, *sourceUrl)
+		if err != nil {
+			slog.Error("failed to fetch source address", "error", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("sign the following message with the factory owner's wallet and pass -deadline %d -signature:\n", *deadline)
+		fmt.Println(agent.ExportMessage(sourceAddress, *targetUrl, *deadline))
+		return
+	}
+
+	if err := export(ctx, *sourceUrl, agent.ExportRequest{Target: *targetUrl, Deadline: *deadline, Signature: *signature}); err != nil {
+		slog.Error("failed to export secrets", "error", err)
+		os.Exit(1)
+	}
+	fmt.Println("secrets exported")
+}
+
+func inspectTarget(ctx context.Context, targetUrl string, collateralFile string) (tdx.Measurement, error) {
+	var collateral *tdx.Collateral
+	var err error
+	if collateralFile != "" {
+		collateral, err = tdx.LoadCollateral(collateralFile)
+	} else {
+		collateral, err = tdx.DownloadCollateral(ctx, http.DefaultClient)
+	}
+	if err != nil {
+		return tdx.Measurement{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(targetUrl, "/")+migration.OfferPath, nil)
+	if err != nil {
+		return tdx.Measurement{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return tdx.Measurement{}, fmt.Errorf("failed to fetch offer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return tdx.Measurement{}, fmt.Errorf("failed to fetch offer: unexpected status code %d", resp.StatusCode)
+	}
+
+	var offer migration.Offer
+	if err := json.NewDecoder(resp.Body).Decode(&offer); err != nil {
+		return tdx.Measurement{}, fmt.Errorf("failed to decode offer: %w", err)
+	}
+
+	quote, err := migration.VerifyOffer(&offer, collateral, time.Now())
+	if err != nil {
+		return tdx.Measurement{}, err
+	}
+
+	return tdx.MeasurementOf(&quote.Body), nil
+}
+
+func fetchAddress(ctx context.Context, agentUrl string) (common.Address, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(agentUrl, "/")+"/address", nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to fetch address: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return common.Address{}, fmt.Errorf("failed to fetch address: unexpected status code %d", resp.StatusCode)
+	}
+
+	address, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to read address: %w", err)
+	}
+	if !common.IsHexAddress(string(address)) {
+		return common.Address{}, fmt.Errorf("invalid address %q", address)
+	}
+
+	return common.HexToAddress(string(address)), nil
+}
+
+func export(ctx context.Context, sourceUrl string, exportRequest agent.ExportRequest) error {
+	body, err := json.Marshal(exportRequest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(sourceUrl, "/")+migration.ExportPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request export: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("export failed with status code %d: %s", resp.StatusCode, message)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+)
+
+type options struct {
+	agentUrl       string
+	quoteFile      string
+	addressHex     string
+	pubkeyFile     string
+	configFile     string
+	factoryAddress string
+	chainId        string
+	rpcUrl         string
+	collateralFile string
+	nonceHex       string
+	measurements   string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.agentUrl, "agent", "", "agent api url to request a fresh quote and the agent info from")
+	flag.StringVar(&opts.quoteFile, "quote", "", "saved quote, binary or hex, instead of fetching it from the agent")
+	flag.StringVar(&opts.addressHex, "address", "", "agent wallet address, for offline verification")
+	flag.StringVar(&opts.pubkeyFile, "pubkey", "", "saved /pubkey response, for offline verification")
+	flag.StringVar(&opts.configFile, "config", "", "saved /config response, for offline verification")
+	flag.StringVar(&opts.factoryAddress, "factory", "", "factory address the agent serves")
+	flag.StringVar(&opts.chainId, "chain-id", "", "chain id, read from the rpc if omitted")
+	flag.StringVar(&opts.rpcUrl, "rpc", "", "ethereum rpc url to check that the agent is an authorized signer")
+	flag.StringVar(&opts.collateralFile, "collateral", "", "collateral JSON with the Intel root CA and CRLs, downloaded if omitted")
+	flag.StringVar(&opts.nonceHex, "nonce", "", "hex nonce a saved quote was requested with")
+	flag.StringVar(&opts.measurements, "measurements", "", "comma-separated allowed measurements, each the hex MRTD and RTMR0 to RTMR3 separated by colons")
+	flag.Parse()
+
+	result, err := run(context.Background(), opts)
+	if err != nil {
+		slog.Error("verification failed", "error", err)
+		os.Exit(1)
+	}
+
+	body := result.Quote.Body
+	fmt.Println("quote verified")
+	fmt.Println("agent address:      ", result.ReportData.Address)
+	fmt.Println("factory address:    ", result.ReportData.FactoryAddress)
+	fmt.Println("chain id:           ", result.ReportData.ChainId)
+	fmt.Println("rsa public key hash:", hex.EncodeToString(result.ReportData.RsaPublicKeyHash[:]))
+	fmt.Println("config hash:        ", hex.EncodeToString(result.ReportData.ConfigHash[:]))
+	if len(result.ReportData.Nonce) > 0 {
+		fmt.Println("nonce:              ", hex.EncodeToString(result.ReportData.Nonce))
+	}
+	fmt.Println("measurement:        ", result.Measurement)
+	fmt.Println("mrtd:               ", hex.EncodeToString(body.MrTd[:]))
+	fmt.Println("rtmr0:              ", hex.EncodeToString(body.Rtmr0[:]))
+	fmt.Println("rtmr1:              ", hex.EncodeToString(body.Rtmr1[:]))
+	fmt.Println("rtmr2:              ", hex.EncodeToString(body.Rtmr2[:]))
+	fmt.Println("rtmr3:              ", hex.EncodeToString(body.Rtmr3[:]))
+	if opts.rpcUrl != "" {
+		fmt.Println("authorized signer:  ", result.AuthorizedSigner)
+	}
+}
+
+func run(ctx context.Context, opts options) (*attestation.Result, error) {
+	if !common.IsHexAddress(opts.factoryAddress) {
+		return nil, fmt.Errorf("invalid factory address %q", opts.factoryAddress)
+	}
+
+	config := attestation.VerifierConfig{
+		FactoryAddress: common.HexToAddress(opts.factoryAddress),
+	}
+
+	for _, value := range strings.Split(opts.measurements, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		measurement, err := tdx.ParseMeasurement(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid measurement %q: %w", value, err)
+		}
+		config.AllowedMeasurements = append(config.AllowedMeasurements, measurement)
+	}
+
+	if opts.chainId != "" {
+		chainId, ok := new(big.Int).SetString(opts.chainId, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid chain id %q", opts.chainId)
+		}
+		config.ChainId = chainId
+	}
+
+	if opts.rpcUrl != "" {
+		ethClient, err := ethclient.DialContext(ctx, opts.rpcUrl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial ethereum client: %w", err)
+		}
+		config.EthClient = ethClient
+	}
+
+	collateral, err := loadCollateral(ctx, opts.collateralFile)
+	if err != nil {
+		return nil, err
+	}
+	config.Collateral = collateral
+
+	verifier, err := attestation.NewVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.quoteFile == "" {
+		if opts.agentUrl == "" {
+			return nil, fmt.Errorf("either -agent or -quote is required")
+		}
+		return verifier.VerifyAgent(ctx, opts.agentUrl)
+	}
+
+	quote, err := os.ReadFile(opts.quoteFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quote: %w", err)
+	}
+
+	var agentInfo *attestation.AgentInfo
+	if opts.agentUrl != "" {
+		agentInfo, err = verifier.FetchAgentInfo(ctx, opts.agentUrl)
+	} else {
+		agentInfo, err = loadAgentInfo(opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := hex.DecodeString(strings.TrimPrefix(opts.nonceHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %w", err)
+	}
+
+	return verifier.VerifyQuote(ctx, quote, agentInfo, nonce)
+}
+
+func loadAgentInfo(opts options) (*attestation.AgentInfo, error) {
+	if !common.IsHexAddress(opts.addressHex) || opts.pubkeyFile == "" || opts.configFile == "" {
+		return nil, fmt.Errorf("-address, -pubkey and -config are required without -agent")
+	}
+
+	rawPublicKey, err := os.ReadFile(opts.pubkeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	rsaPublicKey, err := attestation.ParseRsaPublicKey(rawPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := os.ReadFile(opts.configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return &attestation.AgentInfo{
+		Address:      common.HexToAddress(opts.addressHex),
+		RsaPublicKey: rsaPublicKey,
+		Config:       config,
+	}, nil
+}
+
+func loadCollateral(ctx context.Context, path string) (*tdx.Collateral, error) {
+	if path != "" {
+		return tdx.LoadCollateral(path)
+	}
+
+	return tdx.DownloadCollateral(ctx, http.DefaultClient)
+}
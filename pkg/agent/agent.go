@@ -5,12 +5,14 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"math/big"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,17 +20,24 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gin-gonic/gin"
 	"github.com/hashicorp/golang-lru/v2/expirable"
 
 	"github.com/NethermindEth/yayois-garden/pkg/agent/art"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/fetcher"
 	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
 	"github.com/NethermindEth/yayois-garden/pkg/agent/indexer"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/migration"
 	"github.com/NethermindEth/yayois-garden/pkg/agent/nft"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/queue"
 	"github.com/NethermindEth/yayois-garden/pkg/agent/setup"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/txmanager"
 	"github.com/NethermindEth/yayois-garden/pkg/agent/wallet"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation"
 	contractYayoiCollection "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiCollection"
+	"github.com/NethermindEth/yayois-garden/pkg/envelope"
 )
 
 type AgentEthClient interface {
@@ -36,27 +45,44 @@ type AgentEthClient interface {
 	ethereum.LogFilterer
 	ethereum.BlockNumberReader
 	ethereum.ChainIDReader
+	ethereum.TransactionReader
 }
 
 type Agent struct {
 	artGenerator art.ArtGenerator
 	indexer      *indexer.Indexer
+	queue        *queue.Queue
+	txManager    *txmanager.TxManager
 	ethClient    AgentEthClient
 	wallet       *wallet.Wallet
 	nftUploader  *nft.NftUploader
+	uploader     filestorage.Uploader
 	tappdClient  TappdClient
 	apiRouter    *gin.Engine
-	httpClient   *http.Client
+	fetcher      *fetcher.Fetcher
 
-	systemPromptCache *expirable.LRU[string, string]
-	rsaPrivateKey     *rsa.PrivateKey
+	migrationSender       *migration.Sender
+	accountPrivateKeySeed []byte
+	exports               *exports
+	exportMu              sync.Mutex
+
+	systemPromptCache  *expirable.LRU[string, *art.CollectionPrompt]
+	collectionStatuses *collectionStatuses
+	previewLimiter     *previewLimiter
+	promptUploadBucket *tokenBucket
+	tombstones         *tombstones
+	rsaPrivateKey      *rsa.PrivateKey
+	reportData         *attestation.ReportData
+	attestedConfig     []byte
+
+	allowUnencryptedSystemPrompts bool
 
 	factoryAddress         common.Address
 	eventPollingInterval   time.Duration
 	auctionPollingInterval time.Duration
 	apiIpPort              string
+	lifetime               time.Duration
 
-	mu    sync.Mutex
 	clock AgentClock
 }
 
@@ -71,18 +97,44 @@ func (d DefaultAgentClock) Now() time.Time {
 }
 
 type AgentConfig struct {
-	ArtGenerator art.ArtGenerator
-	Uploader     filestorage.Uploader
-	EthClient    AgentEthClient
-	TappdClient  TappdClient
-	HttpClient   *http.Client
+	ArtGenerator      art.ArtGenerator
+	Uploader          filestorage.Uploader
+	EthClient         AgentEthClient
+	TappdClient       TappdClient
+	HttpClient        *http.Client
+	IndexerStateStore indexer.StateStore
+	JobStore          queue.Store
+	// ImageStore keeps generated images until they are pinned. They are kept in memory only if nil.
+	ImageStore     queue.ImageStore
+	TombstoneStore TombstoneStore
+	// CollectionStatusStore persists collection statuses across restarts. They are kept in memory only if nil.
+	CollectionStatusStore CollectionStatusStore
+	// MigrationSender exports the agent's secrets to allowlisted enclaves. Exports are disabled if nil.
+	MigrationSender *migration.Sender
+	// ExportStore persists completed exports, which keep the agent from signing after a restart. They are kept in
+	// memory only if nil.
+	ExportStore ExportStore
+
+	// IpfsGateways and ArweaveGateways resolve content-addressed system prompt uris, tried in order
+	IpfsGateways    []string
+	ArweaveGateways []string
 
 	FactoryAddress         common.Address
 	EventPollingInterval   time.Duration
 	AuctionPollingInterval time.Duration
+	ConfirmationDepth      uint64
+	SubscribeLogs          bool
 	AccountPrivateKeySeed  []byte
 	ApiIpPort              string
 	RsaPrivateKey          *rsa.PrivateKey
+	// AttestedConfig describes the generator and uploader settings committed to by the quotes. The settings owned by
+	// the agent itself are filled in by NewAgent.
+	AttestedConfig attestation.Config
+	// CollectionLifetime is how long after its creation a collection's system prompt can be used. Defaults to 30 days.
+	CollectionLifetime time.Duration
+	// AllowUnencryptedSystemPrompts accepts system prompts that cannot be decrypted as plain text instead of marking
+	// their collections unusable.
+	AllowUnencryptedSystemPrompts bool
 
 	Clock AgentClock
 }
@@ -91,14 +143,43 @@ const (
 	systemPromptCacheSize = 1000
 	systemPromptCacheTTL  = 1 * time.Hour
 	systemPromptMaxSize   = 5000
+
+	indexerStateFileName    = "indexer_state"
+	jobQueueFileName        = "job_queue"
+	imagesDirName           = "images"
+	pendingReplicasFileName = "pending_replicas"
+
+	finalizationWorkers      = 10
+	finalizationMaxAttempts  = 5
+	finalizationBaseBackoff  = 30 * time.Second
+	finalizationMaxBackoff   = 30 * time.Minute
+	finalizationPollInterval = 1 * time.Second
+	finalizationRetention    = 7 * 24 * time.Hour
+
+	txPollInterval   = 1 * time.Second
+	txBumpInterval   = 2 * time.Minute
+	txReceiptTimeout = 15 * time.Minute
+)
+
+const (
+	stageSystemPrompt = "system_prompt"
+	stageGenerate     = "generate"
+	stagePinImage     = "pin_image"
+	stagePinMetadata  = "pin_metadata"
+	stageSign         = "sign"
+	stageSubmit       = "submit"
+	stageConfirm      = "confirm"
 )
 
 func NewAgent(ctx context.Context, config *AgentConfig) (*Agent, error) {
 	if config == nil {
 		return nil, errors.New("config is nil")
 	}
+	if config.RsaPrivateKey == nil {
+		return nil, errors.New("rsa private key is nil")
+	}
 
-	systemPromptCache := expirable.NewLRU[string, string](systemPromptCacheSize, nil, systemPromptCacheTTL)
+	systemPromptCache := expirable.NewLRU[string, *art.CollectionPrompt](systemPromptCacheSize, nil, systemPromptCacheTTL)
 
 	indexer, err := indexer.NewIndexer(indexer.IndexerConfig{
 		EthClient:              config.EthClient,
@@ -106,6 +187,9 @@ func NewAgent(ctx context.Context, config *AgentConfig) (*Agent, error) {
 		EventPollingInterval:   config.EventPollingInterval,
 		AuctionPollingInterval: config.AuctionPollingInterval,
 		Clock:                  config.Clock,
+		StateStore:             config.IndexerStateStore,
+		ConfirmationDepth:      config.ConfirmationDepth,
+		SubscribeLogs:          config.SubscribeLogs,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create indexer: %w", err)
@@ -121,27 +205,108 @@ func NewAgent(ctx context.Context, config *AgentConfig) (*Agent, error) {
 		return nil, fmt.Errorf("failed to create wallet: %w", err)
 	}
 
+	txManager, err := txmanager.NewTxManager(txmanager.TxManagerConfig{
+		EthClient:      config.EthClient,
+		Auth:           wallet.Auth(),
+		PollInterval:   txPollInterval,
+		BumpInterval:   txBumpInterval,
+		ReceiptTimeout: txReceiptTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction manager: %w", err)
+	}
+
 	nftUploader := nft.NewNftUploader(config.Uploader)
 
+	fetcher, err := fetcher.NewFetcher(fetcher.FetcherConfig{
+		HttpClient:      config.HttpClient,
+		IpfsGateways:    config.IpfsGateways,
+		ArweaveGateways: config.ArweaveGateways,
+		MaxSize:         systemPromptMaxSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fetcher: %w", err)
+	}
+
+	clock := config.Clock
+	if clock == nil {
+		clock = DefaultAgentClock{}
+	}
+
+	lifetime := config.CollectionLifetime
+	if lifetime == 0 {
+		lifetime = defaultCollectionLifetime
+	}
+
+	attestedConfig := config.AttestedConfig
+	attestedConfig.IpfsGateways = fetcher.IpfsGateways()
+	attestedConfig.ArweaveGateways = fetcher.ArweaveGateways()
+	attestedConfig.AllowUnencryptedSystemPrompts = config.AllowUnencryptedSystemPrompts
+	attestedConfig.CollectionLifetimeSeconds = int64(lifetime / time.Second)
+	if replicated, ok := config.Uploader.(*filestorage.ReplicatedUploader); ok {
+		attestedConfig.UploaderQuorum = replicated.Quorum()
+		attestedConfig.UploaderMirrors = replicated.Mirrors()
+	}
+	if config.MigrationSender != nil {
+		for _, measurement := range config.MigrationSender.AllowedMeasurements() {
+			attestedConfig.MigrationAllowedMeasurements = append(attestedConfig.MigrationAllowedMeasurements, measurement.String())
+		}
+	}
+
+	reportData, attestedConfigJson, err := newReportData(wallet.Address(), config.FactoryAddress, chainID, &config.RsaPrivateKey.PublicKey, attestedConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report data: %w", err)
+	}
+
 	agent := &Agent{
 		artGenerator: config.ArtGenerator,
 		indexer:      indexer,
+		txManager:    txManager,
 		ethClient:    config.EthClient,
 		wallet:       wallet,
 		nftUploader:  nftUploader,
+		uploader:     config.Uploader,
 		tappdClient:  config.TappdClient,
 		apiRouter:    nil,
-		httpClient:   config.HttpClient,
+		fetcher:      fetcher,
+
+		migrationSender:       config.MigrationSender,
+		accountPrivateKeySeed: config.AccountPrivateKeySeed,
+		exports:               newExports(config.ExportStore),
+
+		systemPromptCache:  systemPromptCache,
+		collectionStatuses: newCollectionStatuses(config.CollectionStatusStore),
+		previewLimiter:     newPreviewLimiter(clock, previewCooldown),
+		promptUploadBucket: newTokenBucket(clock, promptUploadBurst, promptUploadRefillInterval),
+		tombstones:         newTombstones(config.TombstoneStore),
+		rsaPrivateKey:      config.RsaPrivateKey,
+		reportData:         reportData,
+		attestedConfig:     attestedConfigJson,
 
-		systemPromptCache: systemPromptCache,
-		rsaPrivateKey:     config.RsaPrivateKey,
+		allowUnencryptedSystemPrompts: config.AllowUnencryptedSystemPrompts,
 
 		factoryAddress:         config.FactoryAddress,
 		eventPollingInterval:   config.EventPollingInterval,
 		auctionPollingInterval: config.AuctionPollingInterval,
 		apiIpPort:              config.ApiIpPort,
+		lifetime:               lifetime,
 
-		clock: config.Clock,
+		clock: clock,
+	}
+
+	agent.queue, err = queue.NewQueue(queue.QueueConfig{
+		Store:        config.JobStore,
+		Handler:      agent.processAuctionEnd,
+		Workers:      finalizationWorkers,
+		MaxAttempts:  finalizationMaxAttempts,
+		BaseBackoff:  finalizationBaseBackoff,
+		MaxBackoff:   finalizationMaxBackoff,
+		PollInterval: finalizationPollInterval,
+		Retention:    finalizationRetention,
+		ImageStore:   config.ImageStore,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job queue: %w", err)
 	}
 
 	agent.apiRouter = agent.generateRouter()
@@ -159,32 +324,244 @@ func NewAgentConfigFromSetupResult(setupResult *setup.SetupResult) (*AgentConfig
 		return nil, fmt.Errorf("failed to dial ethereum client: %w", err)
 	}
 
+	artGenerator, err := newArtGeneratorFromSetupResult(setupResult)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create art generator: %w", err)
+	}
+
+	uploaderBackend, uploader, err := newUploaderFromSetupResult(setupResult)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create uploader: %w", err)
+	}
+
+	var migrationSender *migration.Sender
+	if len(setupResult.MigrationAllowedMeasurements) > 0 {
+		migrationSender, err = migration.NewSender(migration.SenderConfig{
+			HttpClient:          http.DefaultClient,
+			TappdClient:         tappd.NewTappdClient(tappd.WithEndpoint(setupResult.DstackTappdEndpoint)),
+			AllowedMeasurements: setupResult.MigrationAllowedMeasurements,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create migration sender: %w", err)
+		}
+	}
+
 	return &AgentConfig{
-		ArtGenerator:   art.NewOpenAiGenerator(setupResult.OpenAiApiKey, setupResult.OpenAiModel),
-		Uploader:       filestorage.NewPinataUploader(setupResult.PinataJwtKey),
+		ArtGenerator:   artGenerator,
+		Uploader:       uploader,
 		EthClient:      ethClient,
 		TappdClient:    tappd.NewTappdClient(tappd.WithEndpoint(setupResult.DstackTappdEndpoint)),
 		FactoryAddress: setupResult.FactoryAddress,
 		HttpClient:     http.DefaultClient,
+		IndexerStateStore: indexer.NewFileStateStore(
+			setupResult.DstackTappdEndpoint,
+			secureSiblingFile(setupResult.SecureFile, indexerStateFileName),
+		),
+		JobStore: queue.NewFileStore(
+			setupResult.DstackTappdEndpoint,
+			secureSiblingFile(setupResult.SecureFile, jobQueueFileName),
+		),
+		ImageStore: queue.NewFileImageStore(
+			setupResult.DstackTappdEndpoint,
+			secureSiblingFile(setupResult.SecureFile, imagesDirName),
+		),
+		TombstoneStore: NewFileTombstoneStore(
+			setupResult.DstackTappdEndpoint,
+			secureSiblingFile(setupResult.SecureFile, tombstonesFileName),
+		),
+		CollectionStatusStore: NewFileCollectionStatusStore(
+			setupResult.DstackTappdEndpoint,
+			secureSiblingFile(setupResult.SecureFile, collectionStatusesFileName),
+		),
+		MigrationSender: migrationSender,
+		ExportStore: NewFileExportStore(
+			setupResult.DstackTappdEndpoint,
+			secureSiblingFile(setupResult.SecureFile, exportsFileName),
+		),
+
+		IpfsGateways:    setupResult.IpfsGateways,
+		ArweaveGateways: setupResult.ArweaveGateways,
 
 		EventPollingInterval:   5 * time.Second,
 		AuctionPollingInterval: 1 * time.Minute,
+		ConfirmationDepth:      setupResult.ConfirmationDepth,
+		SubscribeLogs:          isWebsocketUrl(setupResult.EthereumRpcUrl),
 		AccountPrivateKeySeed:  setupResult.AccountPrivateKeySeed,
 		ApiIpPort:              setupResult.ApiIpPort,
 		RsaPrivateKey:          setupResult.RsaPrivateKey,
 
+		AllowUnencryptedSystemPrompts: setupResult.AllowUnencryptedSystemPrompts,
+		AttestedConfig: attestation.Config{
+			ArtGenerator:         artGenerator.DefaultBackend(),
+			OpenAiModel:          setupResult.OpenAiModel,
+			StableDiffusionUrl:   setupResult.StableDiffusionUrl,
+			StableDiffusionModel: setupResult.StableDiffusionModel,
+			ReplicateModel:       setupResult.ReplicateModel,
+			Uploader:             uploaderBackend,
+		},
+
 		Clock: DefaultAgentClock{},
 	}, nil
 }
 
+// newArtGeneratorFromSetupResult registers every art generator backend that has its settings configured, using the
+// selected one as the default. The local generator only draws test patterns, so it is registered only when selected.
+func newArtGeneratorFromSetupResult(setupResult *setup.SetupResult) (*art.Registry, error) {
+	defaultBackend := setupResult.ArtGenerator
+	if defaultBackend == "" {
+		defaultBackend = art.BackendOpenAi
+	}
+
+	registry := art.NewRegistry(defaultBackend)
+	if defaultBackend == art.BackendLocal {
+		registry.Register(art.BackendLocal, art.NewLocalGenerator())
+	}
+	if setupResult.OpenAiApiKey != "" {
+		registry.Register(art.BackendOpenAi, art.NewOpenAiGenerator(setupResult.OpenAiApiKey, setupResult.OpenAiModel))
+	}
+	if setupResult.StableDiffusionUrl != "" {
+		registry.Register(art.BackendStableDiffusion, art.NewStableDiffusionGenerator(setupResult.StableDiffusionUrl, setupResult.StableDiffusionModel, http.DefaultClient))
+	}
+	if setupResult.ReplicateApiToken != "" {
+		registry.Register(art.BackendReplicate, art.NewReplicateGenerator("", setupResult.ReplicateApiToken, setupResult.ReplicateModel, http.DefaultClient))
+	}
+
+	if _, err := registry.Generator(defaultBackend); err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// newUploaderFromSetupResult creates the configured uploaders, replicating uploads when there are several or when
+// there are mirrors. Setups sealed before the uploader was configurable use Pinata.
+func newUploaderFromSetupResult(setupResult *setup.SetupResult) (string, filestorage.Uploader, error) {
+	backends := setupResult.Uploaders
+	if len(backends) == 0 {
+		backends = []string{filestorage.BackendPinata}
+	}
+
+	// replicas only agree on cids if every IPFS backend builds the same DAG
+	replicated := len(backends) > 1
+
+	uploaders := make(map[string]filestorage.Uploader)
+	for _, backend := range backends {
+		uploader, err := newUploader(backend, setupResult, replicated)
+		if err != nil {
+			return "", nil, err
+		}
+		uploaders[backend] = uploader
+	}
+
+	mirrors := make(map[string]filestorage.Uploader)
+	for _, backend := range setupResult.UploaderMirrors {
+		mirror, err := newUploader(backend, setupResult, false)
+		if err != nil {
+			return "", nil, err
+		}
+		mirrors[backend] = mirror
+	}
+
+	if len(uploaders) == 1 && len(mirrors) == 0 {
+		return backends[0], uploaders[backends[0]], nil
+	}
+
+	replicatedUploader, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders: uploaders,
+		Mirrors:   mirrors,
+		Quorum:    int(setupResult.UploaderQuorum),
+		PendingStore: filestorage.NewFilePendingStore(
+			setupResult.DstackTappdEndpoint,
+			secureSiblingFile(setupResult.SecureFile, pendingReplicasFileName),
+		),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return strings.Join(backends, ","), replicatedUploader, nil
+}
+
+func newUploader(backend string, setupResult *setup.SetupResult, replica bool) (filestorage.Uploader, error) {
+	switch backend {
+	case filestorage.BackendPinata:
+		return filestorage.NewPinataUploader(filestorage.PinataUploaderConfig{
+			HttpClient: http.DefaultClient,
+			JwtKey:     setupResult.PinataJwtKey,
+			CidV1:      replica,
+		}), nil
+	case filestorage.BackendSwarm:
+		return filestorage.NewSwarmUploader(filestorage.SwarmUploaderConfig{
+			HttpClient:     http.DefaultClient,
+			BeeApiUrl:      setupResult.BeeApiUrl,
+			PostageBatchId: setupResult.SwarmPostageBatchId,
+		})
+	case filestorage.BackendKubo:
+		return filestorage.NewKuboUploader(filestorage.KuboUploaderConfig{
+			HttpClient: http.DefaultClient,
+			ApiUrl:     setupResult.KuboApiUrl,
+		})
+	case filestorage.BackendS3:
+		return filestorage.NewS3Uploader(filestorage.S3UploaderConfig{
+			HttpClient:           http.DefaultClient,
+			Endpoint:             setupResult.S3Endpoint,
+			Region:               setupResult.S3Region,
+			Bucket:               setupResult.S3Bucket,
+			AccessKeyId:          setupResult.S3AccessKeyId,
+			SecretAccessKey:      setupResult.S3SecretAccessKey,
+			KeyPrefix:            setupResult.S3KeyPrefix,
+			PublicBaseUrl:        setupResult.S3PublicBaseUrl,
+			ServerSideEncryption: setupResult.S3Sse,
+			KmsKeyId:             setupResult.S3KmsKeyId,
+		})
+	default:
+		return nil, fmt.Errorf("unknown uploader %q", backend)
+	}
+}
+
+// secureSiblingFile returns the path of a file stored in the same directory as the sealed setup file.
+func secureSiblingFile(secureFile string, name string) string {
+	return filepath.Join(filepath.Dir(secureFile), name)
+}
+
+func isWebsocketUrl(url string) bool {
+	return strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://")
+}
+
 func (a *Agent) Start(ctx context.Context) error {
 	slog.Info("starting agent")
 
 	a.StartServer(ctx)
 
+	// expired collections must be known before any job can decrypt their system prompts
+	if err := a.tombstones.load(ctx); err != nil {
+		return fmt.Errorf("failed to load tombstones: %w", err)
+	}
+	if err := a.collectionStatuses.load(ctx); err != nil {
+		return fmt.Errorf("failed to load collection statuses: %w", err)
+	}
+	// an agent whose secrets were exported must not sign alongside the new agent
+	if err := a.exports.load(ctx); err != nil {
+		return fmt.Errorf("failed to load exports: %w", err)
+	}
+	if a.exports.any() {
+		a.wallet.SetSigningDisabled(true)
+		slog.Warn("secrets were exported, signing is disabled")
+	}
+
+	if err := a.queue.Load(ctx); err != nil {
+		slog.Error("failed to load job queue", "error", err)
+	}
+	a.queue.Start(ctx)
+
 	auctionEndChan := make(chan indexer.AuctionEnd, 1000)
 	a.indexer.Start(ctx, auctionEndChan)
 
+	go a.expireCollectionsTask(ctx)
+	if runner, ok := a.uploader.(filestorage.Runner); ok {
+		go runner.Run(ctx)
+	}
+
 	slog.Info("agent started")
 
 	for {
@@ -193,111 +570,305 @@ func (a *Agent) Start(ctx context.Context) error {
 			if !ok {
 				return nil
 			}
-			go a.processAuctionEnd(ctx, auctionEnd)
+			if _, err := a.queue.Enqueue(ctx, auctionEnd); err != nil {
+				slog.Error("failed to enqueue auction end", "error", err)
+			}
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
 }
 
-func (a *Agent) processAuctionEnd(ctx context.Context, event indexer.AuctionEnd) {
+func (a *Agent) processAuctionEnd(ctx context.Context, job *queue.Job) error {
+	event := job.AuctionEnd
+	artifacts := &job.Artifacts
+
+	// the secrets were exported, so the new agent finalizes the auction
+	if a.wallet.SigningDisabled() {
+		return queue.Permanent(wallet.ErrSigningDisabled)
+	}
+
 	collection, err := contractYayoiCollection.NewContractYayoiCollection(event.CollectionAddress, a.ethClient)
 	if err != nil {
-		slog.Error("failed to create collection", "error", err)
-		return
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	auction, err := collection.GetAuction(&bind.CallOpts{Context: ctx}, new(big.Int).SetUint64(event.AuctionId))
+	if err != nil {
+		return fmt.Errorf("failed to get auction: %w", err)
+	}
+	if auction.Finished {
+		slog.Info("auction already finished", "collection", event.CollectionAddress, "auctionId", event.AuctionId)
+		return nil
 	}
 
-	systemPrompt, ok := a.systemPromptCache.Get(event.CollectionAddress.Hex())
-	if !ok {
-		systemPromptUri, err := collection.SystemPromptUri(nil)
+	if artifacts.ImageHash == "" {
+		job.Stage = stageSystemPrompt
+		endTime, err := collection.GetAuctionEndTime(&bind.CallOpts{Context: ctx}, new(big.Int).SetUint64(event.AuctionId))
 		if err != nil {
-			slog.Error("failed to get system prompt uri", "error", err)
-			return
+			return fmt.Errorf("failed to get auction end time: %w", err)
 		}
+		auctionEndedAt := time.Unix(endTime.Int64(), 0).UTC()
 
-		systemPrompt, err = a.readSystemPromptFromUri(ctx, systemPromptUri)
+		if err := a.checkCollectionLifetime(ctx, collection, event.CollectionAddress, auctionEndedAt); err != nil {
+			if errors.Is(err, ErrCollectionExpired) {
+				return queue.Permanent(err)
+			}
+			return err
+		}
+
+		collectionPrompt, err := a.getCollectionPrompt(ctx, collection, event.CollectionAddress, auctionEndedAt)
 		if err != nil {
-			slog.Error("failed to read system prompt", "error", err)
-			return
+			if errors.Is(err, ErrInvalidSystemPrompt) || errors.Is(err, ErrCollectionExpired) {
+				return queue.Permanent(err)
+			}
+			return err
 		}
 
-		a.systemPromptCache.Add(event.CollectionAddress.Hex(), systemPrompt)
+		job.Stage = stageGenerate
+		image, err := a.artGenerator.Generate(ctx, art.GenerationRequest{
+			SystemPrompt: collectionPrompt.SystemPrompt,
+			Prompt:       event.Prompt,
+			Params:       collectionPrompt.Params,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to generate art: %w", err)
+		}
+
+		imageHash := sha256.Sum256(image.Data)
+		if err := a.queue.SaveImage(ctx, hex.EncodeToString(imageHash[:]), image.Data); err != nil {
+			return fmt.Errorf("failed to save art: %w", err)
+		}
+
+		artifacts.ImageHash = hex.EncodeToString(imageHash[:])
+		artifacts.Generator = image.Backend
+		artifacts.Model = image.Model
+		artifacts.GeneratedAt = a.clock.Now()
+		slog.Info("generated art", "job", job.Id, "contentType", image.ContentType, "size", len(image.Data), "sha256", artifacts.ImageHash)
+		a.checkpoint(ctx, job)
 	}
 
 	domain, err := collection.Eip712Domain(nil)
 	if err != nil {
-		slog.Error("failed to get eip712 domain", "error", err)
-		return
+		return fmt.Errorf("failed to get eip712 domain: %w", err)
 	}
 
-	artUrl, err := a.artGenerator.GenerateUrl(ctx, event.Prompt, systemPrompt)
-	if err != nil {
-		slog.Error("failed to generate art", "error", err)
-		return
+	if artifacts.ImageCid == "" {
+		job.Stage = stagePinImage
+		image, err := a.queue.LoadImage(ctx, artifacts.ImageHash)
+		if err != nil {
+			return fmt.Errorf("failed to load art: %w", err)
+		}
+
+		imageHash := sha256.Sum256(image)
+		if image == nil || hex.EncodeToString(imageHash[:]) != artifacts.ImageHash {
+			// The stored image is missing or does not match the generated one, so the next attempt has to generate it again
+			artifacts.ImageHash = ""
+			return errors.New("generated art is missing or does not match its hash")
+		}
+
+		imageCid, err := a.nftUploader.UploadImage(ctx, image)
+		if err != nil {
+			return fmt.Errorf("failed to upload art: %w", err)
+		}
+
+		artifacts.ImageCid = imageCid
+		a.checkpoint(ctx, job)
+
+		// The pinned image is no longer needed
+		if err := a.queue.DeleteImage(ctx, artifacts.ImageHash); err != nil {
+			slog.Error("failed to delete pinned art", "job", job.Id, "error", err)
+		}
 	}
 
-	ipfsHash, err := a.nftUploader.UploadUrl(ctx, domain.Name, event.Prompt, artUrl)
+	if artifacts.TokenUri == "" {
+		job.Stage = stagePinMetadata
+		tokenUri, err := a.nftUploader.UploadMetadata(ctx, nft.NewMetadata(nft.Token{
+			CollectionName:    domain.Name,
+			CollectionAddress: event.CollectionAddress,
+			AuctionId:         event.AuctionId,
+			Prompt:            event.Prompt,
+			WinningBid:        auction.HighestBid,
+			Winner:            event.Winner,
+			Generator:         artifacts.Generator,
+			Model:             artifacts.Model,
+			GeneratedAt:       artifacts.GeneratedAt,
+			ImageUri:          filestorage.Uri(artifacts.ImageCid),
+		}))
+		if err != nil {
+			return fmt.Errorf("failed to upload metadata: %w", err)
+		}
+
+		artifacts.TokenUri = tokenUri
+		a.checkpoint(ctx, job)
+	}
+
+	if len(artifacts.Signature) == 0 {
+		job.Stage = stageSign
+		signature, err := a.wallet.SignMintMessage(event.Winner, artifacts.TokenUri, wallet.EIP712Domain{
+			Name:              domain.Name,
+			Version:           domain.Version,
+			ChainId:           domain.ChainId,
+			VerifyingContract: domain.VerifyingContract,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to sign mint message: %w", err)
+		}
+
+		artifacts.Signature = signature
+		a.checkpoint(ctx, job)
+	}
+
+	if artifacts.TxHash == (common.Hash{}) {
+		job.Stage = stageSubmit
+		tx, err := a.txManager.Send(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return collection.FinishPromptAuction(opts, new(big.Int).SetUint64(event.AuctionId), artifacts.TokenUri, artifacts.Signature)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to finish prompt auction: %w", err)
+		}
+
+		artifacts.TxHash = tx.Hash()
+		a.checkpoint(ctx, job)
+	}
+
+	job.Stage = stageConfirm
+	txHash := artifacts.TxHash
+	receipt, err := a.waitForReceipt(ctx, job)
 	if err != nil {
-		slog.Error("failed to upload art", "error", err)
-		return
+		var revertErr *txmanager.RevertError
+		switch {
+		case errors.Is(err, ethereum.NotFound):
+			// The transaction was dropped, so the next attempt has to submit a new one reusing its nonce
+			a.txManager.Resync()
+			artifacts.TxHash = common.Hash{}
+		case errors.As(err, &revertErr):
+			// The transaction reverted, so the next attempt has to submit a new one
+			artifacts.TxHash = common.Hash{}
+		}
+		return fmt.Errorf("failed to confirm transaction %s: %w", txHash, err)
 	}
 
-	signature, err := a.wallet.SignMintMessage(event.Winner, ipfsHash, wallet.EIP712Domain{
-		Name:              domain.Name,
-		Version:           domain.Version,
-		ChainId:           domain.ChainId,
-		VerifyingContract: domain.VerifyingContract,
-	})
+	artifacts.ReceiptBlock = receipt.BlockNumber.Uint64()
+	slog.Info("auction finalized", "collection", event.CollectionAddress, "auctionId", event.AuctionId, "tx", receipt.TxHash, "block", artifacts.ReceiptBlock)
+
+	return nil
+}
+
+// getCollectionPrompt returns the collection's system prompt together with the generation params stored next to it,
+// for an auction that ended at auctionEndedAt. A collection whose prompt fails validation is marked unusable and keeps
+// failing with ErrInvalidSystemPrompt. The prompt of an expired collection is neither cached nor does it change the
+// collection's status.
+func (a *Agent) getCollectionPrompt(ctx context.Context, collection *contractYayoiCollection.ContractYayoiCollection, collectionAddress common.Address, auctionEndedAt time.Time) (*art.CollectionPrompt, error) {
+	expired := a.tombstones.hasCollection(collectionAddress)
+	if !expired {
+		if status, ok := a.collectionStatuses.get(collectionAddress); ok && !status.Usable {
+			return nil, fmt.Errorf("%w: collection %s is unusable: %s", ErrInvalidSystemPrompt, collectionAddress, status.Reason)
+		}
+
+		if collectionPrompt, ok := a.systemPromptCache.Get(collectionAddress.Hex()); ok {
+			return collectionPrompt, nil
+		}
+	}
+
+	systemPromptUri, err := collection.SystemPromptUri(nil)
 	if err != nil {
-		slog.Error("failed to sign mint message", "error", err)
-		return
+		return nil, fmt.Errorf("failed to get system prompt uri: %w", err)
 	}
 
-	a.mu.Lock()
-	_, err = collection.FinishPromptAuction(a.wallet.Auth(), big.NewInt(int64(event.AuctionId)), ipfsHash, signature)
+	collectionPrompt, err := a.loadCollectionPrompt(ctx, systemPromptUri, auctionEndedAt)
+	if expired {
+		return collectionPrompt, err
+	}
 	if err != nil {
-		slog.Error("failed to finish prompt auction", "error", err)
-		return
+		if errors.Is(err, ErrInvalidSystemPrompt) {
+			slog.Error("collection marked unusable", "collection", collectionAddress, "error", err)
+			a.setCollectionStatus(ctx, CollectionStatus{
+				Address:   collectionAddress,
+				Usable:    false,
+				Reason:    err.Error(),
+				CheckedAt: a.clock.Now(),
+			})
+		}
+		return nil, err
 	}
-	a.mu.Unlock()
+
+	a.setCollectionStatus(ctx, CollectionStatus{
+		Address:   collectionAddress,
+		Usable:    true,
+		CheckedAt: a.clock.Now(),
+	})
+	a.systemPromptCache.Add(collectionAddress.Hex(), collectionPrompt)
+	return collectionPrompt, nil
 }
 
-func (a *Agent) readSystemPromptFromUri(ctx context.Context, uri string) (string, error) {
-	headReq, err := http.NewRequestWithContext(ctx, http.MethodHead, uri, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create HEAD request: %w", err)
+// loadCollectionPrompt fetches and decrypts a system prompt for use at the given time, unless it has been tombstoned
+// by then.
+func (a *Agent) loadCollectionPrompt(ctx context.Context, systemPromptUri string, at time.Time) (*art.CollectionPrompt, error) {
+	if a.tombstones.covers(systemPromptUri, "", at) {
+		return nil, fmt.Errorf("%w: system prompt %s", ErrCollectionExpired, systemPromptUri)
 	}
 
-	headResp, err := a.httpClient.Do(headReq)
+	body, err := a.fetchSystemPrompt(ctx, systemPromptUri)
 	if err != nil {
-		return "", fmt.Errorf("failed to perform HEAD request: %w", err)
+		return nil, fmt.Errorf("failed to read system prompt: %w", err)
 	}
-	headResp.Body.Close()
 
-	if headResp.ContentLength >= systemPromptMaxSize {
-		slog.Info("System prompt too large, skipping")
-		return "", nil
+	if a.tombstones.covers(systemPromptUri, systemPromptKeyHash(body), at) {
+		return nil, fmt.Errorf("%w: system prompt %s", ErrCollectionExpired, systemPromptUri)
+	}
+
+	systemPrompt, err := a.openSystemPrompt(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system prompt: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	collectionPrompt, err := art.ParseCollectionPrompt(systemPrompt)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GET request: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSystemPrompt, err)
 	}
 
-	resp, err := a.httpClient.Do(req)
+	return collectionPrompt, nil
+}
+
+func (a *Agent) checkpoint(ctx context.Context, job *queue.Job) {
+	if err := a.queue.Checkpoint(ctx, job); err != nil {
+		slog.Error("failed to checkpoint job", "job", job.Id, "stage", job.Stage, "error", err)
+	}
+}
+
+// waitForReceipt waits for the job's submitted transaction to be mined, recording the hash of every fee-bumped
+// replacement so that a restarted agent keeps tracking the latest one.
+func (a *Agent) waitForReceipt(ctx context.Context, job *queue.Job) (*types.Receipt, error) {
+	tx, _, err := a.ethClient.TransactionByHash(ctx, job.Artifacts.TxHash)
 	if err != nil {
-		return "", fmt.Errorf("failed to perform GET request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return a.txManager.WaitMined(ctx, tx, func(replacement *types.Transaction) {
+		job.Artifacts.TxHash = replacement.Hash()
+		a.checkpoint(ctx, job)
+	})
+}
+
+func (a *Agent) fetchSystemPrompt(ctx context.Context, uri string) ([]byte, error) {
+	body, err := a.fetcher.Fetch(ctx, uri)
 	if err != nil {
-		return "", err
+		if errors.Is(err, fetcher.ErrTooLarge) || errors.Is(err, fetcher.ErrUnsupportedUri) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSystemPrompt, err)
+		}
+		return nil, err
 	}
 
-	// Attempt to decrypt body; if fail, fallback to raw body
-	decryptedBody, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, a.rsaPrivateKey, body, nil)
+	return body, nil
+}
+
+func (a *Agent) openSystemPrompt(body []byte) (string, error) {
+	decryptedBody, err := a.decryptSystemPrompt(body)
 	if err != nil {
+		if !a.allowUnencryptedSystemPrompts {
+			return "", fmt.Errorf("%w: failed to decrypt: %w", ErrInvalidSystemPrompt, err)
+		}
 		slog.Warn("failed to decrypt body, using raw content", "error", err)
 		decryptedBody = body
 	}
@@ -305,6 +876,27 @@ func (a *Agent) readSystemPromptFromUri(ctx context.Context, uri string) (string
 	return string(decryptedBody), nil
 }
 
+// systemPromptKeyHash identifies a sealed system prompt by the hash of its wrapped key. Prompts encrypted directly with
+// RSA-OAEP are their own wrapped key.
+func systemPromptKeyHash(body []byte) string {
+	wrappedKey, err := envelope.WrappedKey(body)
+	if err != nil {
+		wrappedKey = body
+	}
+
+	hash := sha256.Sum256(wrappedKey)
+	return hex.EncodeToString(hash[:])
+}
+
+// decryptSystemPrompt opens an envelope, or a prompt encrypted directly with RSA-OAEP as produced by older clients.
+func (a *Agent) decryptSystemPrompt(body []byte) ([]byte, error) {
+	if envelope.IsEnvelope(body) {
+		return envelope.Open(a.rsaPrivateKey, body)
+	}
+
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, a.rsaPrivateKey, body, nil)
+}
+
 func (a *Agent) FactoryAddress() common.Address {
 	return a.factoryAddress
 }
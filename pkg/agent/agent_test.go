@@ -6,7 +6,6 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
-	"encoding/binary"
 	"fmt"
 	"io"
 	"math/big"
@@ -24,7 +23,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/NethermindEth/yayois-garden/pkg/agent"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/art"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/nft"
 	"github.com/NethermindEth/yayois-garden/pkg/agent/wallet"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation"
 	contractYayoiCollection "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiCollection"
 	contractYayoiFactory "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiFactory"
 )
@@ -47,20 +50,20 @@ var agentWallet, _ = wallet.NewWallet(agentPrivateKeySeed[:], big.NewInt(1337))
 var agentAddress = agentWallet.Address()
 
 type mockArtGenerator struct {
-	generateUrl func(ctx context.Context, prompt string, systemPrompt string) (string, error)
+	generate func(ctx context.Context, req art.GenerationRequest) (*art.Image, error)
 }
 
-func (m *mockArtGenerator) GenerateUrl(ctx context.Context, prompt string, systemPrompt string) (string, error) {
-	return m.generateUrl(ctx, prompt, systemPrompt)
+func (m *mockArtGenerator) Generate(ctx context.Context, req art.GenerationRequest) (*art.Image, error) {
+	return m.generate(ctx, req)
 }
 
 type mockUploader struct {
-	uploadUrl  func(ctx context.Context, url string) (string, error)
+	uploadFile func(ctx context.Context, fileName string, data []byte) (string, error)
 	uploadJson func(ctx context.Context, json interface{}) (string, error)
 }
 
-func (m *mockUploader) UploadUrl(ctx context.Context, url string) (string, error) {
-	return m.uploadUrl(ctx, url)
+func (m *mockUploader) UploadFile(ctx context.Context, fileName string, data []byte) (string, error) {
+	return m.uploadFile(ctx, fileName, data)
 }
 
 func (m *mockUploader) UploadJson(ctx context.Context, json interface{}) (string, error) {
@@ -187,12 +190,10 @@ func TestAgent_Quote(t *testing.T) {
 
 	mockTappdClient := &mockTappdClient{
 		tdxQuote: func(ctx context.Context, reportData []byte) (*tappd.TdxQuoteResponse, error) {
-			writer := bytes.NewBuffer([]byte{})
+			expected, err := a.ReportData().MarshalBinary()
+			require.NoError(t, err)
 
-			binary.Write(writer, binary.BigEndian, a.Address().Bytes())
-			binary.Write(writer, binary.BigEndian, a.FactoryAddress().Bytes())
-
-			if !bytes.Equal(reportData, writer.Bytes()) {
+			if !bytes.Equal(reportData, expected) {
 				return nil, assert.AnError
 			}
 
@@ -221,6 +222,12 @@ func TestAgent_Quote(t *testing.T) {
 	quote, err := a.Quote(context.Background())
 	require.NoError(t, err)
 	assert.Equal(t, "test-quote", quote)
+
+	rsaPublicKeyHash, err := attestation.HashRsaPublicKey(&rsaPrivateKey.PublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, rsaPublicKeyHash, a.ReportData().RsaPublicKeyHash)
+	assert.Equal(t, attestation.HashConfig(a.AttestedConfig()), a.ReportData().ConfigHash)
+	assert.Equal(t, a.FactoryAddress(), a.ReportData().FactoryAddress)
 }
 
 func TestAgent_MainFlow(t *testing.T) {
@@ -253,9 +260,9 @@ func TestAgent_MainFlow(t *testing.T) {
 		require.NotNil(t, tx2Receipt, "Should have a valid transaction receipt")
 
 		systemPrompt := "test system prompt"
-		systemPromptUri := "ipfs://demo"
+		systemPromptUri := "https://example.com/system-prompt"
 		userPrompt := "test user prompt"
-		artUri := "test-art-uri"
+		artImage := []byte("test-art-image")
 		uploadedArtUri := "test-uploaded-art-uri"
 		uploadedJsonUri := "test-uploaded-json-uri"
 		collectionName := "test-collection-name"
@@ -300,24 +307,27 @@ func TestAgent_MainFlow(t *testing.T) {
 			config.FactoryAddress = factoryAddr
 			config.EventPollingInterval = 1 * time.Second
 			config.AuctionPollingInterval = 1 * time.Second
+			config.AllowUnencryptedSystemPrompts = true
 			config.ArtGenerator = &mockArtGenerator{
-				generateUrl: func(ctx context.Context, prompt string, systemPrompt string) (string, error) {
-					require.Equal(t, prompt, userPrompt)
-					require.Equal(t, systemPrompt, systemPrompt)
-					return artUri, nil
+				generate: func(ctx context.Context, req art.GenerationRequest) (*art.Image, error) {
+					require.Equal(t, userPrompt, req.Prompt)
+					require.Equal(t, systemPrompt, req.SystemPrompt)
+					return &art.Image{Data: artImage, ContentType: "image/png"}, nil
 				},
 			}
 			config.Uploader = &mockUploader{
-				uploadUrl: func(ctx context.Context, url string) (string, error) {
-					require.Equal(t, url, artUri)
+				uploadFile: func(ctx context.Context, fileName string, data []byte) (string, error) {
+					require.Equal(t, artImage, data)
 					return uploadedArtUri, nil
 				},
 				uploadJson: func(ctx context.Context, json interface{}) (string, error) {
-					require.Equal(t, json, map[string]string{
-						"name":        collectionName,
-						"description": userPrompt,
-						"image":       uploadedArtUri,
-					})
+					metadata, ok := json.(*nft.Metadata)
+					require.True(t, ok)
+					require.Equal(t, collectionName, metadata.Name)
+					require.Equal(t, userPrompt, metadata.Description)
+					require.Equal(t, "ipfs://"+uploadedArtUri, metadata.Image)
+					require.Contains(t, metadata.Attributes, nft.Attribute{TraitType: "Winner", Value: userAddress.Hex()})
+					require.Contains(t, metadata.Attributes, nft.Attribute{TraitType: "Winning Bid", Value: "20"})
 					return uploadedJsonUri, nil
 				},
 			}
@@ -370,7 +380,7 @@ func TestAgent_MainFlow(t *testing.T) {
 
 		token0, err := collectionInstance.TokenURI(nil, big.NewInt(0))
 		require.NoError(t, err)
-		require.Equal(t, token0, uploadedJsonUri)
+		require.Equal(t, "ipfs://"+uploadedJsonUri, token0)
 	})
 
 	t.Run("encrypted system prompt", func(t *testing.T) {
@@ -404,9 +414,8 @@ func TestAgent_MainFlow(t *testing.T) {
 		require.NotNil(t, tx2Receipt, "Should have a valid transaction receipt")
 
 		systemPromptDecrypted := "test system prompt (decrypted)"
-		systemPromptUri := "ipfs://demo-encrypted"
 		userPrompt := "test user prompt"
-		artUri := "test-art-uri"
+		artImage := []byte("test-art-image")
 		uploadedArtUri := "test-uploaded-art-uri"
 		uploadedJsonUri := "test-uploaded-json-uri"
 		collectionName := "test-collection-name-encrypted"
@@ -415,10 +424,15 @@ func TestAgent_MainFlow(t *testing.T) {
 		systemPromptEncrypted, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &rsaPrivateKey.PublicKey, []byte(systemPromptDecrypted), nil)
 		require.NoError(t, err)
 
+		systemPromptCid, err := ipfs.CidV0(systemPromptEncrypted)
+		require.NoError(t, err)
+		systemPromptUri := "ipfs://" + systemPromptCid.String()
+		ipfsGateway := "https://gateway.test"
+
 		mockHttpClient := &http.Client{
 			Transport: &mockHttpTransport{
 				roundTrip: func(req *http.Request) (*http.Response, error) {
-					if req.URL.String() == systemPromptUri {
+					if req.URL.String() == ipfsGateway+"/ipfs/"+systemPromptCid.String() {
 						return &http.Response{
 							StatusCode: http.StatusOK,
 							Body:       io.NopCloser(bytes.NewBuffer(systemPromptEncrypted)),
@@ -450,27 +464,30 @@ func TestAgent_MainFlow(t *testing.T) {
 		testAgent := setupTestAgent(t, func(config *agent.AgentConfig) {
 			config.EthClient = mockEthClient
 			config.HttpClient = mockHttpClient
+			config.IpfsGateways = []string{ipfsGateway}
 			config.FactoryAddress = factoryAddr
 			config.EventPollingInterval = 1 * time.Second
 			config.AuctionPollingInterval = 1 * time.Second
 			config.ArtGenerator = &mockArtGenerator{
-				generateUrl: func(ctx context.Context, prompt string, systemPrompt string) (string, error) {
-					require.Equal(t, prompt, userPrompt)
-					require.Equal(t, systemPromptDecrypted, systemPrompt)
-					return artUri, nil
+				generate: func(ctx context.Context, req art.GenerationRequest) (*art.Image, error) {
+					require.Equal(t, userPrompt, req.Prompt)
+					require.Equal(t, systemPromptDecrypted, req.SystemPrompt)
+					return &art.Image{Data: artImage, ContentType: "image/png"}, nil
 				},
 			}
 			config.Uploader = &mockUploader{
-				uploadUrl: func(ctx context.Context, url string) (string, error) {
-					require.Equal(t, url, artUri)
+				uploadFile: func(ctx context.Context, fileName string, data []byte) (string, error) {
+					require.Equal(t, artImage, data)
 					return uploadedArtUri, nil
 				},
 				uploadJson: func(ctx context.Context, json interface{}) (string, error) {
-					require.Equal(t, json, map[string]string{
-						"name":        collectionName,
-						"description": userPrompt,
-						"image":       uploadedArtUri,
-					})
+					metadata, ok := json.(*nft.Metadata)
+					require.True(t, ok)
+					require.Equal(t, collectionName, metadata.Name)
+					require.Equal(t, userPrompt, metadata.Description)
+					require.Equal(t, "ipfs://"+uploadedArtUri, metadata.Image)
+					require.Contains(t, metadata.Attributes, nft.Attribute{TraitType: "Winner", Value: userAddress.Hex()})
+					require.Contains(t, metadata.Attributes, nft.Attribute{TraitType: "Winning Bid", Value: "20"})
 					return uploadedJsonUri, nil
 				},
 			}
@@ -522,7 +539,7 @@ func TestAgent_MainFlow(t *testing.T) {
 
 		token0, err := collectionInstance.TokenURI(nil, big.NewInt(0))
 		require.NoError(t, err)
-		require.Equal(t, token0, uploadedJsonUri)
+		require.Equal(t, "ipfs://"+uploadedJsonUri, token0)
 	})
 }
 
@@ -2,12 +2,20 @@ package agent
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/migration"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
 )
 
 func (a *Agent) generateRouter() *gin.Engine {
@@ -25,7 +33,33 @@ func (a *Agent) generateRouter() *gin.Engine {
 		})
 	})
 
+	router.GET("/report-data", func(c *gin.Context) {
+		c.JSON(http.StatusOK, a.ReportData())
+	})
+
+	router.GET("/config", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", a.AttestedConfig())
+	})
+
+	// GET /quote?nonce=<hex> returns a quote committing to the nonce together with its report data preimage
 	router.GET("/quote", func(c *gin.Context) {
+		if rawNonce := c.Query("nonce"); rawNonce != "" {
+			nonce, err := hex.DecodeString(strings.TrimPrefix(rawNonce, "0x"))
+			if err != nil || len(nonce) > attestation.MaxNonceSize {
+				c.String(http.StatusBadRequest, "nonce must be hex encoded and at most %d bytes", attestation.MaxNonceSize)
+				return
+			}
+
+			quote, err := a.FreshQuote(c.Request.Context(), nonce)
+			if err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			c.JSON(http.StatusOK, quote)
+			return
+		}
+
 		quote, err := a.Quote(c.Request.Context())
 		if err != nil {
 			c.String(http.StatusInternalServerError, err.Error())
@@ -35,6 +69,108 @@ func (a *Agent) generateRouter() *gin.Engine {
 		c.JSON(http.StatusOK, quote)
 	})
 
+	// POST /migration/export takes an ExportRequest signed by the factory owner. The secrets are only sealed to enclaves
+	// running an allowlisted measurement, and the agent stops signing once they are sent.
+	router.POST(migration.ExportPath, func(c *gin.Context) {
+		var request ExportRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err := a.ExportSecrets(c.Request.Context(), request)
+		switch {
+		case errors.Is(err, ErrMigrationDisabled):
+			c.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, ErrInvalidExportRequest):
+			c.String(http.StatusBadRequest, err.Error())
+		case errors.Is(err, ErrExportNotAllowed), errors.Is(err, migration.ErrInvalidOffer), errors.Is(err, tdx.ErrMeasurementNotAllowed):
+			c.String(http.StatusForbidden, err.Error())
+		case err != nil:
+			c.String(http.StatusBadGateway, err.Error())
+		default:
+			c.Status(http.StatusOK)
+		}
+	})
+
+	// POST /prompts takes a system prompt sealed to /pubkey as the raw request body, signed by its creator in the
+	// UploadSignatureHeader
+	router.POST("/prompts", func(c *gin.Context) {
+		sealed, err := io.ReadAll(io.LimitReader(c.Request.Body, systemPromptMaxSize))
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		uploaded, err := a.UploadSystemPrompt(c.Request.Context(), sealed, c.GetHeader(UploadSignatureHeader))
+		switch {
+		case errors.Is(err, ErrSystemPromptTooLarge):
+			c.String(http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, ErrInvalidSystemPrompt):
+			c.String(http.StatusBadRequest, err.Error())
+		case errors.Is(err, ErrUploadNotAllowed):
+			c.String(http.StatusForbidden, err.Error())
+		case errors.Is(err, ErrUploadRateLimited):
+			c.String(http.StatusTooManyRequests, err.Error())
+		case err != nil:
+			slog.Error("failed to upload system prompt", "error", err)
+			c.String(http.StatusBadGateway, "failed to upload system prompt")
+		default:
+			c.JSON(http.StatusCreated, uploaded)
+		}
+	})
+
+	router.POST("/previews", func(c *gin.Context) {
+		var req PreviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		preview, err := a.GeneratePreview(c.Request.Context(), req)
+		switch {
+		case errors.Is(err, ErrInvalidPreviewRequest), errors.Is(err, ErrInvalidSystemPrompt):
+			c.String(http.StatusBadRequest, err.Error())
+		case errors.Is(err, ErrPreviewNotAllowed):
+			c.String(http.StatusForbidden, err.Error())
+		case errors.Is(err, ErrCollectionExpired):
+			c.String(http.StatusGone, err.Error())
+		case errors.Is(err, ErrPreviewRateLimited):
+			c.String(http.StatusTooManyRequests, err.Error())
+		case err != nil:
+			slog.Error("failed to generate preview", "error", err)
+			c.String(http.StatusBadGateway, "failed to generate preview")
+		case preview.Image != nil:
+			c.Data(http.StatusOK, preview.Image.ContentType, preview.Image.Data)
+		default:
+			c.JSON(http.StatusOK, map[string]string{"uri": preview.Uri})
+		}
+	})
+
+	router.GET("/collections", func(c *gin.Context) {
+		c.JSON(http.StatusOK, a.CollectionStatuses())
+	})
+
+	router.GET("/collections/:address", func(c *gin.Context) {
+		address := c.Param("address")
+		if !common.IsHexAddress(address) {
+			c.String(http.StatusBadRequest, "invalid collection address")
+			return
+		}
+
+		status, ok := a.CollectionStatus(common.HexToAddress(address))
+		if !ok {
+			c.String(http.StatusNotFound, "collection has not been checked")
+			return
+		}
+
+		c.JSON(http.StatusOK, status)
+	})
+
+	router.GET("/lifetimes", func(c *gin.Context) {
+		c.JSON(http.StatusOK, a.CollectionLifetimes())
+	})
+
 	return router
 }
 
@@ -43,7 +179,26 @@ func (a *Agent) GetRouter() *gin.Engine {
 }
 
 func (a *Agent) Quote(ctx context.Context) (string, error) {
-	reportDataBytes, err := generateReportDataBytes(a.wallet.Address(), a.factoryAddress)
+	return a.quote(ctx, a.reportData)
+}
+
+// FreshQuote returns a quote whose report data also commits to the caller's nonce, so that it cannot be a replay.
+func (a *Agent) FreshQuote(ctx context.Context, nonce []byte) (*FreshQuote, error) {
+	reportData := a.reportData.WithNonce(nonce)
+
+	quote, err := a.quote(ctx, reportData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FreshQuote{
+		Quote:      quote,
+		ReportData: reportData,
+	}, nil
+}
+
+func (a *Agent) quote(ctx context.Context, reportData *attestation.ReportData) (string, error) {
+	reportDataBytes, err := reportData.MarshalBinary()
 	if err != nil {
 		return "", err
 	}
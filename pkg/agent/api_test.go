@@ -1,20 +1,37 @@
 package agent_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Dstack-TEE/dstack/sdk/go/tappd"
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/NethermindEth/yayois-garden/pkg/agent"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/art"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/migration"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+	contractYayoiFactory "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiFactory"
+	"github.com/NethermindEth/yayois-garden/pkg/envelope"
 )
 
 func setupTestAgent(t *testing.T, opts ...func(*agent.AgentConfig)) *agent.Agent {
@@ -77,6 +94,57 @@ func TestAgentApi_GetRouter(t *testing.T) {
 		assert.Equal(t, "test-quote", quote)
 	})
 
+	t.Run("GET /quote with nonce", func(t *testing.T) {
+		var quotedReportData []byte
+		testAgent := setupTestAgent(t, func(config *agent.AgentConfig) {
+			config.TappdClient = &mockTappdClient{
+				tdxQuote: func(ctx context.Context, reportData []byte) (*tappd.TdxQuoteResponse, error) {
+					quotedReportData = reportData
+					return &tappd.TdxQuoteResponse{
+						Quote: "fresh-quote",
+					}, nil
+				},
+			}
+		})
+
+		router := testAgent.GetRouter()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/quote?nonce=0x0102030405", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Quote      string                 `json:"quote"`
+			ReportData map[string]interface{} `json:"reportData"`
+		}
+		err := json.NewDecoder(w.Body).Decode(&response)
+		require.NoError(t, err)
+
+		expected := testAgent.ReportData().WithNonce([]byte{1, 2, 3, 4, 5})
+		expectedBytes, err := expected.MarshalBinary()
+		require.NoError(t, err)
+		expectedPreimage, err := expected.Preimage()
+		require.NoError(t, err)
+
+		assert.Equal(t, "fresh-quote", response.Quote)
+		assert.Equal(t, expectedBytes, quotedReportData)
+		assert.Equal(t, hex.EncodeToString(expectedBytes), response.ReportData["reportData"])
+		assert.Equal(t, hex.EncodeToString(expectedPreimage), response.ReportData["preimage"])
+		assert.Equal(t, "0102030405", response.ReportData["nonce"])
+	})
+
+	t.Run("GET /quote invalid nonce", func(t *testing.T) {
+		for _, nonce := range []string{"not-hex", strings.Repeat("ab", attestation.MaxNonceSize+1)} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/quote?nonce="+nonce, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+	})
+
 	t.Run("GET /quote error", func(t *testing.T) {
 		testAgent := setupTestAgent(t, func(config *agent.AgentConfig) {
 			config.TappdClient = &mockTappdClient{
@@ -110,4 +178,417 @@ func TestAgentApi_GetRouter(t *testing.T) {
 		assert.Equal(t, rsaPrivateKey.PublicKey.N.String(), pubKey["n"])
 		assert.Equal(t, strconv.Itoa(rsaPrivateKey.PublicKey.E), pubKey["e"])
 	})
+
+	t.Run("GET /report-data", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/report-data", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var reportData map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&reportData))
+
+		expected, err := testAgent.ReportData().MarshalBinary()
+		require.NoError(t, err)
+		assert.Equal(t, hex.EncodeToString(expected), reportData["reportData"])
+	})
+
+	t.Run("GET /config", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/config", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, testAgent.ReportData().ConfigHash, attestation.HashConfig(w.Body.Bytes()))
+	})
+
+	t.Run("GET /collections", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/collections", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, "[]", w.Body.String())
+	})
+
+	t.Run("GET /lifetimes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/lifetimes", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, "[]", w.Body.String())
+	})
+
+	t.Run("POST /migration/export", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/migration/export", bytes.NewBufferString(`{`))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/migration/export", bytes.NewBufferString(`{"target":"https://example.com"}`))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, agent.ErrMigrationDisabled.Error(), w.Body.String())
+	})
+
+	t.Run("GET /collections/:address", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/collections/0x1234567890123456789012345678901234567890", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/collections/not-an-address", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAgentApi_Export(t *testing.T) {
+	mockEthClient, simBackend, simClock := newMockEthClient()
+
+	factoryAddr, _, _, err := contractYayoiFactory.DeployContractYayoiFactory(
+		ownerAuth,
+		mockEthClient,
+		common.HexToAddress("0x0000000000000000000000000000000000000000"),
+		big.NewInt(10),
+		big.NewInt(1),
+		uint64(1),
+		ownerAddress,
+	)
+	require.NoError(t, err)
+	simBackend.Commit()
+
+	// the target never offers, so no secrets leave the agent
+	target := httptest.NewServer(http.NotFoundHandler())
+	defer target.Close()
+
+	sender, err := migration.NewSender(migration.SenderConfig{
+		TappdClient:         &mockTappdClient{},
+		Collateral:          &tdx.Collateral{},
+		AllowedMeasurements: []tdx.Measurement{{}},
+	})
+	require.NoError(t, err)
+
+	testAgent := setupTestAgent(t, func(config *agent.AgentConfig) {
+		config.EthClient = mockEthClient
+		config.Clock = simClock
+		config.FactoryAddress = factoryAddr
+		config.MigrationSender = sender
+	})
+	router := testAgent.GetRouter()
+
+	requestExport := func(key *ecdsa.PrivateKey, deadline time.Time) *httptest.ResponseRecorder {
+		signature, err := crypto.Sign(accounts.TextHash([]byte(agent.ExportMessage(testAgent.Address(), target.URL, deadline.Unix()))), key)
+		require.NoError(t, err)
+		signature[crypto.RecoveryIDOffset] += 27
+
+		body, err := json.Marshal(agent.ExportRequest{
+			Target:    target.URL,
+			Deadline:  deadline.Unix(),
+			Signature: hexutil.Encode(signature),
+		})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/migration/export", bytes.NewReader(body))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("unsigned", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/migration/export", bytes.NewBufferString(`{"target":"`+target.URL+`"}`))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		w := requestExport(ownerAccount, simClock.Now().Add(-time.Minute))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("deadline too far", func(t *testing.T) {
+		w := requestExport(ownerAccount, simClock.Now().Add(24*time.Hour))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("not the factory owner", func(t *testing.T) {
+		w := requestExport(userAccount, simClock.Now().Add(time.Minute))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), agent.ErrExportNotAllowed.Error())
+	})
+
+	t.Run("factory owner", func(t *testing.T) {
+		w := requestExport(ownerAccount, simClock.Now().Add(time.Minute))
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Contains(t, w.Body.String(), "failed to fetch offer")
+	})
+}
+
+func signUpload(t *testing.T, key *ecdsa.PrivateKey, sealed []byte) string {
+	signature, err := crypto.Sign(accounts.TextHash([]byte(agent.UploadMessage(sealed))), key)
+	require.NoError(t, err)
+	return hexutil.Encode(signature)
+}
+
+func sealCreatorPrompt(t *testing.T, key *ecdsa.PrivateKey, systemPrompt string) []byte {
+	sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte(fmt.Sprintf(
+		`{"systemPrompt": %q, "creator": %q}`, systemPrompt, crypto.PubkeyToAddress(key.PublicKey).Hex(),
+	)))
+	require.NoError(t, err)
+	return sealed
+}
+
+func TestAgentApi_UploadPrompt(t *testing.T) {
+	creatorKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	var uploaded []byte
+	testAgent := setupTestAgent(t, func(config *agent.AgentConfig) {
+		config.Uploader = &mockUploader{
+			uploadFile: func(ctx context.Context, fileName string, data []byte) (string, error) {
+				uploaded = data
+				cid, err := ipfs.CidV0(data)
+				require.NoError(t, err)
+				return cid.String(), nil
+			},
+		}
+	})
+	router := testAgent.GetRouter()
+
+	postPrompt := func(body []byte, signature string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/prompts", bytes.NewReader(body))
+		if signature != "" {
+			req.Header.Set(agent.UploadSignatureHeader, signature)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("sealed prompt", func(t *testing.T) {
+		sealed := sealCreatorPrompt(t, creatorKey, "a watercolor garden")
+
+		w := postPrompt(sealed, signUpload(t, creatorKey, sealed))
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var response agent.UploadedSystemPrompt
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+
+		cid, err := ipfs.CidV0(sealed)
+		require.NoError(t, err)
+		assert.Equal(t, "ipfs://"+cid.String(), response.Uri)
+		assert.Equal(t, sealed, uploaded)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		sealed := sealCreatorPrompt(t, creatorKey, "a watercolor garden")
+
+		w := postPrompt(sealed, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("not the creator", func(t *testing.T) {
+		sealed := sealCreatorPrompt(t, creatorKey, "a watercolor garden")
+
+		w := postPrompt(sealed, signUpload(t, otherKey, sealed))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), agent.ErrUploadNotAllowed.Error())
+	})
+
+	t.Run("no creator", func(t *testing.T) {
+		sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte("a watercolor garden"))
+		require.NoError(t, err)
+
+		w := postPrompt(sealed, signUpload(t, creatorKey, sealed))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("plain text prompt", func(t *testing.T) {
+		body := []byte("a watercolor garden")
+		w := postPrompt(body, signUpload(t, creatorKey, body))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte(`{"systemPrompt": "a watercolor garden", "params": {"size": "large"}}`))
+		require.NoError(t, err)
+
+		w := postPrompt(sealed, signUpload(t, creatorKey, sealed))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("malformed json", func(t *testing.T) {
+		sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte(`{"systemPrompt": "a watercolor garden",`))
+		require.NoError(t, err)
+
+		w := postPrompt(sealed, signUpload(t, creatorKey, sealed))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, bytes.Repeat([]byte("a"), 5000))
+		require.NoError(t, err)
+
+		w := postPrompt(sealed, signUpload(t, creatorKey, sealed))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		sealed := sealCreatorPrompt(t, creatorKey, "a watercolor garden")
+		signature := signUpload(t, creatorKey, sealed)
+
+		code := http.StatusCreated
+		for n := 0; n < 20 && code == http.StatusCreated; n++ {
+			code = postPrompt(sealed, signature).Code
+		}
+		assert.Equal(t, http.StatusTooManyRequests, code)
+	})
+}
+
+func TestAgentApi_UploadPromptToUriBackend(t *testing.T) {
+	creatorKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	mirrorUri := "https://cdn.example.com/system-prompt"
+	var uploaded []byte
+	newRouter := func(reference string) http.Handler {
+		testAgent := setupTestAgent(t, func(config *agent.AgentConfig) {
+			config.Uploader = &mockUploader{
+				uploadFile: func(ctx context.Context, fileName string, data []byte) (string, error) {
+					uploaded = data
+					return reference, nil
+				},
+			}
+			config.HttpClient = &http.Client{
+				Transport: &mockHttpTransport{
+					roundTrip: func(req *http.Request) (*http.Response, error) {
+						if req.URL.String() == mirrorUri {
+							return &http.Response{
+								StatusCode: http.StatusOK,
+								Body:       io.NopCloser(bytes.NewReader(uploaded)),
+							}, nil
+						}
+						return nil, fmt.Errorf("unexpected request to %s", req.URL)
+					},
+				},
+			}
+		})
+		return testAgent.GetRouter()
+	}
+
+	sealed := sealCreatorPrompt(t, creatorKey, "a watercolor garden")
+	signature := signUpload(t, creatorKey, sealed)
+
+	t.Run("fetched back", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/prompts", bytes.NewReader(sealed))
+		req.Header.Set(agent.UploadSignatureHeader, signature)
+		newRouter(mirrorUri).ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var response agent.UploadedSystemPrompt
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Equal(t, mirrorUri, response.Uri)
+		assert.Empty(t, response.Cid)
+	})
+
+	t.Run("unresolvable", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/prompts", bytes.NewReader(sealed))
+		req.Header.Set(agent.UploadSignatureHeader, signature)
+		newRouter("bzz://"+strings.Repeat("cd", 32)).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+	})
+}
+
+func TestAgentApi_Preview(t *testing.T) {
+	creatorKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte(fmt.Sprintf(
+		`{"systemPrompt": "a watercolor garden", "creator": %q}`, crypto.PubkeyToAddress(creatorKey.PublicKey).Hex(),
+	)))
+	require.NoError(t, err)
+	systemPromptCid, err := ipfs.CidV0(sealed)
+	require.NoError(t, err)
+	systemPromptUri := "ipfs://" + systemPromptCid.String()
+
+	testAgent := setupTestAgent(t, func(config *agent.AgentConfig) {
+		config.ArtGenerator = art.NewLocalGenerator()
+		config.HttpClient = &http.Client{
+			Transport: &mockHttpTransport{
+				roundTrip: func(req *http.Request) (*http.Response, error) {
+					if req.URL.Path == "/ipfs/"+systemPromptCid.String() {
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       io.NopCloser(bytes.NewReader(sealed)),
+						}, nil
+					}
+					return nil, fmt.Errorf("unexpected request to %s", req.URL)
+				},
+			},
+		}
+	})
+	router := testAgent.GetRouter()
+
+	requestPreviewOf := func(uri string, key *ecdsa.PrivateKey, prompt string) *httptest.ResponseRecorder {
+		signature, err := crypto.Sign(accounts.TextHash([]byte(agent.PreviewMessage(uri, prompt))), key)
+		require.NoError(t, err)
+		signature[crypto.RecoveryIDOffset] += 27
+
+		body, err := json.Marshal(agent.PreviewRequest{
+			SystemPromptUri: uri,
+			Prompt:          prompt,
+			Signature:       hexutil.Encode(signature),
+		})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/previews", bytes.NewReader(body))
+		router.ServeHTTP(w, req)
+		return w
+	}
+	requestPreview := func(key *ecdsa.PrivateKey, prompt string) *httptest.ResponseRecorder {
+		return requestPreviewOf(systemPromptUri, key, prompt)
+	}
+
+	t.Run("url system prompt", func(t *testing.T) {
+		w := requestPreviewOf("https://example.com/system-prompt", creatorKey, "a bench")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("not the creator", func(t *testing.T) {
+		w := requestPreview(otherKey, "a bench")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("not the creator again", func(t *testing.T) {
+		// the cooldown is taken before the system prompt is fetched
+		w := requestPreview(otherKey, "a fountain")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("creator", func(t *testing.T) {
+		w := requestPreview(creatorKey, "a bench")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.NotEmpty(t, w.Body.Bytes())
+	})
+
+	t.Run("second preview", func(t *testing.T) {
+		w := requestPreview(creatorKey, "a fountain")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
 }
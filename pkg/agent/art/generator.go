@@ -1,7 +1,108 @@
 package art
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
 
 type ArtGenerator interface {
-	GenerateUrl(ctx context.Context, systemPrompt string, prompt string) (string, error)
+	Generate(ctx context.Context, req GenerationRequest) (*Image, error)
+}
+
+const (
+	maxImageSize = 32 << 20
+)
+
+// Image is a generated image held in memory, so that it can be hashed and checked inside the enclave before pinning.
+type Image struct {
+	Data        []byte
+	ContentType string
+	// Backend is the generator backend and Model the model it used, empty if the backend does not choose one
+	Backend string
+	Model   string
+}
+
+func newImage(data []byte) (*Image, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no image data returned")
+	}
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("image too large: %d bytes", len(data))
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("generated data is not an image: %s", contentType)
+	}
+
+	return &Image{
+		Data:        data,
+		ContentType: contentType,
+	}, nil
+}
+
+const (
+	BackendOpenAi          = "openai"
+	BackendStableDiffusion = "stablediffusion"
+	BackendReplicate       = "replicate"
+	BackendLocal           = "local"
+)
+
+// Registry holds the configured generator backends by name. It generates with the backend requested in the generation
+// params, or the default backend if none is requested.
+type Registry struct {
+	generators     map[string]ArtGenerator
+	defaultBackend string
+}
+
+var _ ArtGenerator = (*Registry)(nil)
+
+func NewRegistry(defaultBackend string) *Registry {
+	return &Registry{
+		generators:     make(map[string]ArtGenerator),
+		defaultBackend: defaultBackend,
+	}
+}
+
+func (r *Registry) Register(backend string, generator ArtGenerator) {
+	r.generators[backend] = generator
+}
+
+func (r *Registry) DefaultBackend() string {
+	return r.defaultBackend
+}
+
+// Generator returns the generator registered for the backend, or the default one if backend is empty.
+func (r *Registry) Generator(backend string) (ArtGenerator, error) {
+	if backend == "" {
+		backend = r.defaultBackend
+	}
+
+	generator, ok := r.generators[backend]
+	if !ok {
+		return nil, fmt.Errorf("art generator backend %q is not configured", backend)
+	}
+
+	return generator, nil
+}
+
+func (r *Registry) Generate(ctx context.Context, req GenerationRequest) (*Image, error) {
+	generator, err := r.Generator(req.Params.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := generator.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	image.Backend = req.Params.Backend
+	if image.Backend == "" {
+		image.Backend = r.defaultBackend
+	}
+
+	return image, nil
 }
@@ -0,0 +1,56 @@
+package art
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+const (
+	localImageSize = 256
+	localGridSize  = 8
+)
+
+// LocalGenerator renders a deterministic pattern derived from the prompts without calling any external service. It
+// is meant for tests and local development.
+type LocalGenerator struct{}
+
+var _ ArtGenerator = (*LocalGenerator)(nil)
+
+func NewLocalGenerator() *LocalGenerator {
+	return &LocalGenerator{}
+}
+
+func (g *LocalGenerator) Generate(ctx context.Context, req GenerationRequest) (*Image, error) {
+	width, height, err := req.Params.Dimensions(localImageSize, localImageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := sha256.Sum256([]byte(styledPrompt(req)))
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	cellWidth := max(width/localGridSize, 1)
+	cellHeight := max(height/localGridSize, 1)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			cell := (y/cellHeight)*localGridSize + x/cellWidth
+			offset := cell % (len(seed) - 2)
+			img.Set(x, y, color.RGBA{R: seed[offset], G: seed[offset+1], B: seed[offset+2], A: 0xff})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return &Image{
+		Data:        buf.Bytes(),
+		ContentType: "image/png",
+	}, nil
+}
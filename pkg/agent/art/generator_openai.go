@@ -2,6 +2,7 @@ package art
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 
 	"github.com/sashabaranov/go-openai"
@@ -24,25 +25,55 @@ func NewOpenAiGenerator(apiKey string, model string) *OpenAiGenerator {
 	}
 }
 
-func (g *OpenAiGenerator) GenerateUrl(ctx context.Context, systemPrompt string, prompt string) (string, error) {
+func (g *OpenAiGenerator) Generate(ctx context.Context, generationRequest GenerationRequest) (*Image, error) {
+	params := generationRequest.Params
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	prompt := generatePrompt(generationRequest.SystemPrompt, generationRequest.Prompt)
+	if params.NegativePrompt != "" {
+		// The images API has no negative prompt, so it is stated in the prompt
+		prompt = fmt.Sprintf("%s\n\nAvoid: %s", prompt, params.NegativePrompt)
+	}
+
 	req := openai.ImageRequest{
-		Prompt:         generatePrompt(systemPrompt, prompt),
+		Prompt:         prompt,
 		Size:           openai.CreateImageSize1024x1024,
-		ResponseFormat: openai.CreateImageResponseFormatURL,
+		ResponseFormat: openai.CreateImageResponseFormatB64JSON,
 		N:              1,
 		Model:          g.model,
+		Quality:        params.Quality,
+		Style:          params.Style,
+	}
+	if params.Size != "" {
+		req.Size = params.Size
+	}
+	if params.Model != "" {
+		req.Model = params.Model
 	}
 
 	resp, err := g.client.CreateImage(ctx, req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(resp.Data) == 0 {
-		return "", fmt.Errorf("no image data returned")
+		return nil, fmt.Errorf("no image data returned")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	image, err := newImage(data)
+	if err != nil {
+		return nil, err
 	}
+	image.Model = req.Model
 
-	return resp.Data[0].URL, nil
+	return image, nil
 }
 
 func generatePrompt(systemPrompt string, prompt string) string {
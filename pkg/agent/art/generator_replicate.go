@@ -0,0 +1,197 @@
+package art
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	replicateDefaultBaseUrl  = "https://api.replicate.com/v1"
+	replicatePollInterval    = 2 * time.Second
+	replicateStatusSucceeded = "succeeded"
+	replicateStatusFailed    = "failed"
+	replicateStatusCanceled  = "canceled"
+)
+
+// ReplicateGenerator generates images through Replicate-style asynchronous predictions: a prediction is created for
+// the model and polled until it succeeds or fails.
+type ReplicateGenerator struct {
+	baseUrl    string
+	apiToken   string
+	model      string
+	httpClient *http.Client
+
+	pollInterval time.Duration
+}
+
+var _ ArtGenerator = (*ReplicateGenerator)(nil)
+
+type replicatePrediction struct {
+	Id     string          `json:"id"`
+	Status string          `json:"status"`
+	Output json.RawMessage `json:"output"`
+	Error  interface{}     `json:"error"`
+	Urls   struct {
+		Get string `json:"get"`
+	} `json:"urls"`
+}
+
+// NewReplicateGenerator creates a generator for the model, given as "owner/name". An empty baseUrl uses the
+// Replicate API.
+func NewReplicateGenerator(baseUrl string, apiToken string, model string, httpClient *http.Client) *ReplicateGenerator {
+	if baseUrl == "" {
+		baseUrl = replicateDefaultBaseUrl
+	}
+
+	return &ReplicateGenerator{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		apiToken:   apiToken,
+		model:      model,
+		httpClient: httpClient,
+
+		pollInterval: replicatePollInterval,
+	}
+}
+
+func (g *ReplicateGenerator) Generate(ctx context.Context, generationRequest GenerationRequest) (*Image, error) {
+	params := generationRequest.Params
+
+	input := map[string]interface{}{
+		"prompt": styledPrompt(generationRequest),
+	}
+	if params.Size != "" {
+		width, height, err := params.Dimensions(0, 0)
+		if err != nil {
+			return nil, err
+		}
+		input["width"] = width
+		input["height"] = height
+	}
+	if params.NegativePrompt != "" {
+		input["negative_prompt"] = params.NegativePrompt
+	}
+
+	model := g.model
+	if params.Model != "" {
+		model = params.Model
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"input": input,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	prediction, err := g.do(ctx, http.MethodPost, fmt.Sprintf("%s/models/%s/predictions", g.baseUrl, model), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prediction: %w", err)
+	}
+
+	getUrl := prediction.Urls.Get
+
+	ticker := time.NewTicker(g.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		switch prediction.Status {
+		case replicateStatusSucceeded:
+			url, err := predictionOutputUrl(prediction.Output)
+			if err != nil {
+				return nil, err
+			}
+			image, err := g.download(ctx, url)
+			if err != nil {
+				return nil, err
+			}
+			image.Model = model
+			return image, nil
+		case replicateStatusFailed, replicateStatusCanceled:
+			return nil, fmt.Errorf("prediction %s %s: %v", prediction.Id, prediction.Status, prediction.Error)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		prediction, err = g.do(ctx, http.MethodGet, getUrl, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get prediction: %w", err)
+		}
+	}
+}
+
+func (g *ReplicateGenerator) do(ctx context.Context, method string, url string, body []byte) (*replicatePrediction, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+g.apiToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var prediction replicatePrediction
+	if err := json.NewDecoder(resp.Body).Decode(&prediction); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &prediction, nil
+}
+
+// download fetches the prediction output before it expires on the provider side.
+func (g *ReplicateGenerator) download(ctx context.Context, url string) (*Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d downloading image", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	return newImage(data)
+}
+
+// predictionOutputUrl extracts the image URL from a prediction output, which is either a URL or a list of URLs
+// depending on the model.
+func predictionOutputUrl(output json.RawMessage) (string, error) {
+	var url string
+	if err := json.Unmarshal(output, &url); err == nil && url != "" {
+		return url, nil
+	}
+
+	var urls []string
+	if err := json.Unmarshal(output, &urls); err == nil && len(urls) > 0 {
+		return urls[0], nil
+	}
+
+	return "", fmt.Errorf("no image data returned")
+}
@@ -0,0 +1,123 @@
+package art
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	stableDiffusionTxt2ImgPath = "/sdapi/v1/txt2img"
+	stableDiffusionImageSize   = 1024
+	stableDiffusionSteps       = 30
+	stableDiffusionHdSteps     = 50
+	stableDiffusionQualityHd   = "hd"
+)
+
+// StableDiffusionGenerator generates images through a Stable Diffusion server exposing the txt2img HTTP API
+// (AUTOMATIC1111, Forge, or a ComfyUI bridge).
+type StableDiffusionGenerator struct {
+	baseUrl    string
+	model      string
+	httpClient *http.Client
+}
+
+var _ ArtGenerator = (*StableDiffusionGenerator)(nil)
+
+type stableDiffusionRequest struct {
+	Prompt           string            `json:"prompt"`
+	NegativePrompt   string            `json:"negative_prompt,omitempty"`
+	Width            int               `json:"width"`
+	Height           int               `json:"height"`
+	Steps            int               `json:"steps"`
+	BatchSize        int               `json:"batch_size"`
+	OverrideSettings map[string]string `json:"override_settings,omitempty"`
+}
+
+type stableDiffusionResponse struct {
+	Images []string `json:"images"`
+}
+
+func NewStableDiffusionGenerator(baseUrl string, model string, httpClient *http.Client) *StableDiffusionGenerator {
+	return &StableDiffusionGenerator{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		model:      model,
+		httpClient: httpClient,
+	}
+}
+
+func (g *StableDiffusionGenerator) Generate(ctx context.Context, generationRequest GenerationRequest) (*Image, error) {
+	params := generationRequest.Params
+
+	width, height, err := params.Dimensions(stableDiffusionImageSize, stableDiffusionImageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody := stableDiffusionRequest{
+		Prompt:         styledPrompt(generationRequest),
+		NegativePrompt: params.NegativePrompt,
+		Width:          width,
+		Height:         height,
+		Steps:          stableDiffusionSteps,
+		BatchSize:      1,
+	}
+	if params.Quality == stableDiffusionQualityHd {
+		reqBody.Steps = stableDiffusionHdSteps
+	}
+
+	model := g.model
+	if params.Model != "" {
+		model = params.Model
+	}
+	if model != "" {
+		reqBody.OverrideSettings = map[string]string{"sd_model_checkpoint": model}
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseUrl+stableDiffusionTxt2ImgPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var respBody stableDiffusionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(respBody.Images) == 0 {
+		return nil, fmt.Errorf("no image data returned")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(respBody.Images[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	image, err := newImage(data)
+	if err != nil {
+		return nil, err
+	}
+	image.Model = model
+
+	return image, nil
+}
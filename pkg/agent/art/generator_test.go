@@ -0,0 +1,196 @@
+package art_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/art"
+)
+
+// testPng is the PNG signature followed by an empty IHDR chunk, enough for content type detection
+var testPng = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+var testRequest = art.GenerationRequest{
+	SystemPrompt: "system prompt",
+	Prompt:       "prompt",
+}
+
+func TestLocalGenerator_Deterministic(t *testing.T) {
+	generator := art.NewLocalGenerator()
+
+	first, err := generator.Generate(context.Background(), testRequest)
+	require.NoError(t, err)
+	second, err := generator.Generate(context.Background(), testRequest)
+	require.NoError(t, err)
+	other, err := generator.Generate(context.Background(), art.GenerationRequest{SystemPrompt: "system prompt", Prompt: "other prompt"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "image/png", first.ContentType)
+	assert.Equal(t, "image/png", http.DetectContentType(first.Data))
+	assert.Equal(t, first.Data, second.Data)
+	assert.NotEqual(t, first.Data, other.Data)
+}
+
+func TestStableDiffusionGenerator_Generate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/sdapi/v1/txt2img", r.URL.Path)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "system prompt\n\nprompt", body["prompt"])
+		assert.Equal(t, "blurry", body["negative_prompt"])
+		assert.Equal(t, float64(1792), body["width"])
+		assert.Equal(t, float64(1024), body["height"])
+		assert.Equal(t, map[string]interface{}{"sd_model_checkpoint": "collection-model"}, body["override_settings"])
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"images": []string{base64.StdEncoding.EncodeToString(testPng)}})
+	}))
+	defer server.Close()
+
+	generator := art.NewStableDiffusionGenerator(server.URL, "test-model", server.Client())
+
+	image, err := generator.Generate(context.Background(), art.GenerationRequest{
+		SystemPrompt: "system prompt",
+		Prompt:       "prompt",
+		Params: art.GenerationParams{
+			Model:          "collection-model",
+			Size:           art.SizeLandscape,
+			NegativePrompt: "blurry",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testPng, image.Data)
+	assert.Equal(t, "image/png", image.ContentType)
+	assert.Equal(t, "collection-model", image.Model)
+}
+
+func TestReplicateGenerator_Generate(t *testing.T) {
+	var server *httptest.Server
+	polls := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/models/owner/model/predictions":
+			assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":     "prediction",
+				"status": "starting",
+				"urls":   map[string]string{"get": server.URL + "/predictions/prediction"},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/predictions/prediction":
+			polls++
+			if polls < 2 {
+				json.NewEncoder(w).Encode(map[string]interface{}{"id": "prediction", "status": "processing"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":     "prediction",
+				"status": "succeeded",
+				"output": []string{server.URL + "/files/image.png"},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/files/image.png":
+			w.Write(testPng)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	generator := art.NewReplicateGenerator(server.URL, "test-token", "owner/model", server.Client())
+
+	image, err := generator.Generate(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.Equal(t, testPng, image.Data)
+	assert.Equal(t, "owner/model", image.Model)
+}
+
+func TestRegistry_Generate(t *testing.T) {
+	registry := art.NewRegistry(art.BackendLocal)
+	registry.Register(art.BackendLocal, art.NewLocalGenerator())
+
+	generator, err := registry.Generator("")
+	require.NoError(t, err)
+	assert.IsType(t, &art.LocalGenerator{}, generator)
+
+	image, err := registry.Generate(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.Equal(t, art.BackendLocal, image.Backend)
+	assert.Empty(t, image.Model)
+
+	_, err = registry.Generate(context.Background(), art.GenerationRequest{
+		Params: art.GenerationParams{Backend: art.BackendReplicate},
+	})
+	assert.Error(t, err)
+}
+
+func TestParseCollectionPrompt(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *art.CollectionPrompt
+		wantErr bool
+	}{
+		{
+			name:    "plain text",
+			content: "a watercolor garden",
+			want:    &art.CollectionPrompt{SystemPrompt: "a watercolor garden"},
+		},
+		{
+			name:    "document with params",
+			content: `{"systemPrompt": "a watercolor garden", "params": {"model": "dall-e-3", "size": "1024x1792", "quality": "hd", "style": "natural"}}`,
+			want: &art.CollectionPrompt{
+				SystemPrompt: "a watercolor garden",
+				Params: art.GenerationParams{
+					Model:   "dall-e-3",
+					Size:    art.SizePortrait,
+					Quality: "hd",
+					Style:   "natural",
+				},
+			},
+		},
+		{
+			name:    "json without system prompt",
+			content: `{"prompt": "a watercolor garden"}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty system prompt",
+			content: `{"systemPrompt": "", "params": {"model": "dall-e-3"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed json",
+			content: ` {"systemPrompt": "a watercolor garden",`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid size",
+			content: `{"systemPrompt": "a watercolor garden", "params": {"size": "large"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "oversized",
+			content: `{"systemPrompt": "a watercolor garden", "params": {"size": "100000x100000"}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := art.ParseCollectionPrompt(tt.content)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
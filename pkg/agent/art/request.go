@@ -0,0 +1,99 @@
+package art
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	SizeSquare    = "1024x1024"
+	SizePortrait  = "1024x1792"
+	SizeLandscape = "1792x1024"
+
+	// MaxImageDimension bounds both sides of a requested size, which is rendered in enclave memory by the local
+	// generator and paid for with the external backends
+	MaxImageDimension = 2048
+)
+
+// GenerationParams are the generation settings a collection declares next to its system prompt. Empty fields use the
+// backend defaults.
+type GenerationParams struct {
+	Backend        string `json:"backend,omitempty"`
+	Model          string `json:"model,omitempty"`
+	Size           string `json:"size,omitempty"`
+	Quality        string `json:"quality,omitempty"`
+	Style          string `json:"style,omitempty"`
+	NegativePrompt string `json:"negativePrompt,omitempty"`
+}
+
+// Dimensions parses Size as "<width>x<height>", each at most MaxImageDimension. It returns the fallback dimensions if
+// Size is empty.
+func (p GenerationParams) Dimensions(fallbackWidth int, fallbackHeight int) (int, int, error) {
+	if p.Size == "" {
+		return fallbackWidth, fallbackHeight, nil
+	}
+
+	var width, height int
+	if _, err := fmt.Sscanf(p.Size, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q", p.Size)
+	}
+	if width > MaxImageDimension || height > MaxImageDimension {
+		return 0, 0, fmt.Errorf("invalid size %q: sides are limited to %d pixels", p.Size, MaxImageDimension)
+	}
+
+	return width, height, nil
+}
+
+func (p GenerationParams) Validate() error {
+	_, _, err := p.Dimensions(0, 0)
+	return err
+}
+
+type GenerationRequest struct {
+	SystemPrompt string
+	Prompt       string
+	Params       GenerationParams
+}
+
+// CollectionPrompt is the private part of a collection stored behind its system prompt URI. Creator is the address
+// allowed to request previews of the collection.
+type CollectionPrompt struct {
+	SystemPrompt string           `json:"systemPrompt"`
+	Params       GenerationParams `json:"params"`
+	Creator      string           `json:"creator,omitempty"`
+}
+
+// ParseCollectionPrompt reads a collection prompt document. Content that does not start with "{" is taken as a plain
+// text system prompt with default parameters; anything else must be a JSON object with a systemPrompt field.
+func ParseCollectionPrompt(content string) (*CollectionPrompt, error) {
+	if !strings.HasPrefix(strings.TrimSpace(content), "{") {
+		return &CollectionPrompt{SystemPrompt: content}, nil
+	}
+
+	var collectionPrompt CollectionPrompt
+	if err := json.Unmarshal([]byte(content), &collectionPrompt); err != nil {
+		return nil, fmt.Errorf("malformed collection prompt: %w", err)
+	}
+	if collectionPrompt.SystemPrompt == "" {
+		return nil, errors.New("collection prompt has no systemPrompt")
+	}
+
+	if err := collectionPrompt.Params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid generation params: %w", err)
+	}
+
+	return &collectionPrompt, nil
+}
+
+// styledPrompt is the generation prompt for backends without a native style setting, where the style is described
+// in the prompt itself.
+func styledPrompt(req GenerationRequest) string {
+	prompt := generatePrompt(req.SystemPrompt, req.Prompt)
+	if req.Params.Style != "" {
+		prompt = fmt.Sprintf("%s\n\nStyle: %s", prompt, req.Params.Style)
+	}
+
+	return prompt
+}
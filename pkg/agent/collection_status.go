@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/sealing"
+)
+
+const collectionStatusesFileName = "collection_statuses"
+
+// ErrInvalidSystemPrompt is returned when a collection's system prompt fails validation. Such a collection is marked
+// unusable and gets no mints.
+var ErrInvalidSystemPrompt = errors.New("invalid system prompt")
+
+type CollectionStatus struct {
+	Address   common.Address `json:"address"`
+	Usable    bool           `json:"usable"`
+	Reason    string         `json:"reason,omitempty"`
+	CheckedAt time.Time      `json:"checkedAt"`
+}
+
+type CollectionStatusStore interface {
+	Load(ctx context.Context) ([]CollectionStatus, error)
+	Save(ctx context.Context, statuses []CollectionStatus) error
+}
+
+type FileCollectionStatusStore struct {
+	dstackTappdEndpoint string
+	filePath            string
+}
+
+var _ CollectionStatusStore = (*FileCollectionStatusStore)(nil)
+
+func NewFileCollectionStatusStore(dstackTappdEndpoint string, filePath string) *FileCollectionStatusStore {
+	return &FileCollectionStatusStore{
+		dstackTappdEndpoint: dstackTappdEndpoint,
+		filePath:            filePath,
+	}
+}
+
+func (s *FileCollectionStatusStore) Load(ctx context.Context) ([]CollectionStatus, error) {
+	if _, err := os.Stat(s.filePath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	data, err := sealing.ReadSealedFile(ctx, s.dstackTappdEndpoint, s.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection statuses: %v", err)
+	}
+
+	var statuses []CollectionStatus
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal collection statuses: %v", err)
+	}
+
+	return statuses, nil
+}
+
+func (s *FileCollectionStatusStore) Save(ctx context.Context, statuses []CollectionStatus) error {
+	data, err := json.Marshal(statuses)
+	if err != nil {
+		return fmt.Errorf("failed to marshal collection statuses: %v", err)
+	}
+
+	return sealing.WriteSealedFile(ctx, s.dstackTappdEndpoint, s.filePath, data)
+}
+
+type collectionStatuses struct {
+	mu       sync.Mutex
+	store    CollectionStatusStore
+	statuses map[common.Address]CollectionStatus
+}
+
+func newCollectionStatuses(store CollectionStatusStore) *collectionStatuses {
+	return &collectionStatuses{
+		store:    store,
+		statuses: make(map[common.Address]CollectionStatus),
+	}
+}
+
+func (c *collectionStatuses) load(ctx context.Context) error {
+	if c.store == nil {
+		return nil
+	}
+
+	loaded, err := c.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, status := range loaded {
+		c.statuses[status.Address] = status
+	}
+
+	return nil
+}
+
+// set records the status, persisting every status when the collection's usability or reason changed. Rechecks that
+// only refresh CheckedAt are kept in memory. The status stays in effect in memory even if it cannot be persisted.
+func (c *collectionStatuses) set(ctx context.Context, status CollectionStatus) error {
+	c.mu.Lock()
+	previous, ok := c.statuses[status.Address]
+	c.statuses[status.Address] = status
+	changed := !ok || previous.Usable != status.Usable || previous.Reason != status.Reason
+	c.mu.Unlock()
+
+	if c.store == nil || !changed {
+		return nil
+	}
+
+	return c.store.Save(ctx, c.all())
+}
+
+func (c *collectionStatuses) get(address common.Address) (CollectionStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.statuses[address]
+	return status, ok
+}
+
+func (c *collectionStatuses) all() []CollectionStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := make([]CollectionStatus, 0, len(c.statuses))
+	for _, status := range c.statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Address.Cmp(statuses[j].Address) < 0 })
+
+	return statuses
+}
+
+// setCollectionStatus records a collection's status, logging failures to persist it.
+func (a *Agent) setCollectionStatus(ctx context.Context, status CollectionStatus) {
+	if err := a.collectionStatuses.set(ctx, status); err != nil {
+		slog.Error("failed to save collection statuses", "collection", status.Address, "error", err)
+	}
+}
+
+// CollectionStatuses returns the validation state of every collection whose system prompt has been checked.
+func (a *Agent) CollectionStatuses() []CollectionStatus {
+	return a.collectionStatuses.all()
+}
+
+func (a *Agent) CollectionStatus(address common.Address) (CollectionStatus, bool) {
+	return a.collectionStatuses.get(address)
+}
@@ -0,0 +1,222 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/migration"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/sealing"
+	contractYayoiFactory "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiFactory"
+)
+
+const (
+	// exportMaxValidity bounds how far in the future the deadline of an export request may be
+	exportMaxValidity = 1 * time.Hour
+	exportsFileName   = "exports"
+)
+
+var (
+	ErrMigrationDisabled    = errors.New("migration is disabled")
+	ErrInvalidExportRequest = errors.New("invalid export request")
+	// ErrExportNotAllowed is returned when the export request is not signed by the factory owner.
+	ErrExportNotAllowed = errors.New("export not allowed")
+)
+
+type ExportRequest struct {
+	Target string `json:"target"`
+	// Deadline is the unix time after which the request is rejected
+	Deadline int64 `json:"deadline"`
+	// Signature is the factory owner's personal_sign signature of ExportMessage
+	Signature string `json:"signature"`
+}
+
+// ExportMessage is the text the factory owner signs with their wallet to export the secrets of the agent to target.
+func ExportMessage(agentAddress common.Address, target string, deadline int64) string {
+	return fmt.Sprintf("Yayoi Garden migration export\nAgent: %s\nTarget: %s\nDeadline: %d", agentAddress.Hex(), target, deadline)
+}
+
+// Export records a completed export. Once the secrets have left the enclave the agent stops signing for good, so that
+// the old and the new agent never sign for the same wallet.
+type Export struct {
+	Target     string    `json:"target"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+type ExportStore interface {
+	Load(ctx context.Context) ([]Export, error)
+	Save(ctx context.Context, exports []Export) error
+}
+
+type FileExportStore struct {
+	dstackTappdEndpoint string
+	filePath            string
+}
+
+var _ ExportStore = (*FileExportStore)(nil)
+
+func NewFileExportStore(dstackTappdEndpoint string, filePath string) *FileExportStore {
+	return &FileExportStore{
+		dstackTappdEndpoint: dstackTappdEndpoint,
+		filePath:            filePath,
+	}
+}
+
+func (s *FileExportStore) Load(ctx context.Context) ([]Export, error) {
+	if _, err := os.Stat(s.filePath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	data, err := sealing.ReadSealedFile(ctx, s.dstackTappdEndpoint, s.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exports: %v", err)
+	}
+
+	var exports []Export
+	if err := json.Unmarshal(data, &exports); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal exports: %v", err)
+	}
+
+	return exports, nil
+}
+
+func (s *FileExportStore) Save(ctx context.Context, exports []Export) error {
+	data, err := json.Marshal(exports)
+	if err != nil {
+		return fmt.Errorf("failed to marshal exports: %v", err)
+	}
+
+	return sealing.WriteSealedFile(ctx, s.dstackTappdEndpoint, s.filePath, data)
+}
+
+type exports struct {
+	mu      sync.Mutex
+	store   ExportStore
+	exports []Export
+}
+
+func newExports(store ExportStore) *exports {
+	return &exports{store: store}
+}
+
+func (e *exports) load(ctx context.Context) error {
+	if e.store == nil {
+		return nil
+	}
+
+	loaded, err := e.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.exports = loaded
+
+	return nil
+}
+
+func (e *exports) any() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.exports) > 0
+}
+
+func (e *exports) add(ctx context.Context, export Export) error {
+	e.mu.Lock()
+	e.exports = append(e.exports, export)
+	all := append([]Export(nil), e.exports...)
+	e.mu.Unlock()
+
+	if e.store == nil {
+		return nil
+	}
+
+	return e.store.Save(ctx, all)
+}
+
+// ExportSecrets sends the agent's wallet seed and RSA key to the agent at the request's target, which must be running
+// an allowlisted measurement and waiting for a migration. The request must be signed by the factory owner. Signing
+// stops before the secrets are sent and only resumes if they were certainly not delivered, after which the target
+// serves the same wallet and system prompts.
+func (a *Agent) ExportSecrets(ctx context.Context, req ExportRequest) error {
+	if a.migrationSender == nil {
+		return ErrMigrationDisabled
+	}
+
+	if err := a.authorizeExport(ctx, req); err != nil {
+		return err
+	}
+
+	a.exportMu.Lock()
+	defer a.exportMu.Unlock()
+
+	signingDisabled := a.wallet.SigningDisabled()
+	a.wallet.SetSigningDisabled(true)
+
+	err := a.migrationSender.Export(ctx, req.Target, migration.NewSecrets(a.accountPrivateKeySeed, a.rsaPrivateKey))
+	if err != nil && !errors.Is(err, migration.ErrDeliveryUnknown) {
+		a.wallet.SetSigningDisabled(signingDisabled)
+		return err
+	}
+
+	// the record keeps signing disabled across restarts; it stays disabled in memory even if it cannot be saved
+	if saveErr := a.exports.add(ctx, Export{Target: req.Target, ExportedAt: a.clock.Now()}); saveErr != nil {
+		slog.Error("failed to save export", "target", req.Target, "error", saveErr)
+	}
+
+	if err != nil {
+		slog.Error("signing disabled after an export of unknown outcome", "target", req.Target, "error", err)
+		return err
+	}
+
+	slog.Info("signing disabled after export", "target", req.Target)
+
+	return nil
+}
+
+func (a *Agent) authorizeExport(ctx context.Context, req ExportRequest) error {
+	if req.Target == "" {
+		return fmt.Errorf("%w: target is required", ErrInvalidExportRequest)
+	}
+
+	deadline := time.Unix(req.Deadline, 0)
+	now := a.clock.Now()
+	if !deadline.After(now) {
+		return fmt.Errorf("%w: deadline has passed", ErrInvalidExportRequest)
+	}
+	if deadline.Sub(now) > exportMaxValidity {
+		return fmt.Errorf("%w: deadline is more than %s away", ErrInvalidExportRequest, exportMaxValidity)
+	}
+
+	signer, err := recoverPersonalSigner(ExportMessage(a.Address(), req.Target, req.Deadline), req.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrExportNotAllowed, err)
+	}
+
+	factory, err := contractYayoiFactory.NewContractYayoiFactoryCaller(a.factoryAddress, a.ethClient)
+	if err != nil {
+		return fmt.Errorf("failed to create factory: %w", err)
+	}
+
+	owner, err := factory.Owner(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("failed to get factory owner: %w", err)
+	}
+
+	if signer != owner {
+		return fmt.Errorf("%w: signer is not the factory owner", ErrExportNotAllowed)
+	}
+
+	return nil
+}
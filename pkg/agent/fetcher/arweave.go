@@ -0,0 +1,227 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+)
+
+const (
+	arweaveTransactionFormat = 2
+	arweaveOwnerExponent     = 65537
+	// arweaveMaxChunkSize is the size of the chunks in an arweave data merkle tree
+	arweaveMaxChunkSize = 256 * 1024
+	arweaveNoteSize     = 32
+)
+
+var (
+	errArweaveSignature = errors.New("invalid arweave transaction signature")
+	errArweaveDataRoot  = errors.New("content does not match arweave data root")
+)
+
+var arweaveEncoding = base64.RawURLEncoding
+
+type arweaveTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type arweaveTransaction struct {
+	Format    int          `json:"format"`
+	LastTx    string       `json:"last_tx"`
+	Owner     string       `json:"owner"`
+	Tags      []arweaveTag `json:"tags"`
+	Target    string       `json:"target"`
+	Quantity  string       `json:"quantity"`
+	DataSize  string       `json:"data_size"`
+	DataRoot  string       `json:"data_root"`
+	Reward    string       `json:"reward"`
+	Signature string       `json:"signature"`
+}
+
+// fetchArweave resolves a layer 1 arweave transaction through the arweave gateways. The transaction header is checked
+// against its id and owner signature, and the data against the signed data root. Bundled data items are not
+// supported.
+func (f *Fetcher) fetchArweave(ctx context.Context, id string) ([]byte, error) {
+	rawId, err := arweaveEncoding.DecodeString(id)
+	if err != nil || len(rawId) != sha256.Size {
+		return nil, fmt.Errorf("%w: invalid arweave transaction id", ErrUnsupportedUri)
+	}
+
+	return f.fetchFromGateways(ctx, f.arweaveGateways, func(gateway string) ([]byte, error) {
+		tx, err := f.getArweaveTransaction(ctx, gateway, id)
+		if err != nil {
+			return nil, err
+		}
+
+		dataRoot, dataSize, err := tx.verify(rawId)
+		if err != nil {
+			return nil, err
+		}
+		if dataSize >= f.maxSize {
+			return nil, fmt.Errorf("%w: size %d exceeds the limit of %d bytes", ErrTooLarge, dataSize, f.maxSize)
+		}
+
+		content, err := f.get(ctx, gateway+"/"+id)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(content)) != dataSize || !bytes.Equal(arweaveDataRoot(content), dataRoot) {
+			return nil, errArweaveDataRoot
+		}
+
+		return content, nil
+	})
+}
+
+func (f *Fetcher) getArweaveTransaction(ctx context.Context, gateway string, id string) (*arweaveTransaction, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gateway+"/tx/"+id, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var tx arweaveTransaction
+	if err := json.NewDecoder(resp.Body).Decode(&tx); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+
+	return &tx, nil
+}
+
+// verify checks that the transaction has the given id and is signed by its owner, returning the signed data root and
+// data size.
+func (tx *arweaveTransaction) verify(id []byte) ([]byte, int64, error) {
+	if tx.Format != arweaveTransactionFormat {
+		return nil, 0, fmt.Errorf("%w: arweave transaction format %d", ErrUnsupportedUri, tx.Format)
+	}
+
+	signature, err := arweaveEncoding.DecodeString(tx.Signature)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	signatureHash := sha256.Sum256(signature)
+	if !bytes.Equal(signatureHash[:], id) {
+		return nil, 0, errArweaveSignature
+	}
+
+	owner, err := arweaveEncoding.DecodeString(tx.Owner)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid owner encoding: %w", err)
+	}
+
+	signatureData, err := tx.signatureData(owner)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(owner), E: arweaveOwnerExponent}
+	digest := sha256.Sum256(signatureData)
+	if err := rsa.VerifyPSS(publicKey, crypto.SHA256, digest[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}); err != nil {
+		return nil, 0, errArweaveSignature
+	}
+
+	dataRoot, err := arweaveEncoding.DecodeString(tx.DataRoot)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid data root encoding: %w", err)
+	}
+
+	dataSize, err := strconv.ParseInt(tx.DataSize, 10, 64)
+	if err != nil || dataSize < 0 {
+		return nil, 0, fmt.Errorf("invalid data size %q", tx.DataSize)
+	}
+	if dataSize > arweaveMaxChunkSize {
+		return nil, 0, fmt.Errorf("%w: size %d exceeds a single chunk", ErrTooLarge, dataSize)
+	}
+
+	return dataRoot, dataSize, nil
+}
+
+// signatureData computes the deep hash a format 2 transaction's owner signs.
+func (tx *arweaveTransaction) signatureData(owner []byte) ([]byte, error) {
+	var decodeErr error
+	decode := func(value string) []byte {
+		decoded, err := arweaveEncoding.DecodeString(value)
+		if err != nil && decodeErr == nil {
+			decodeErr = fmt.Errorf("invalid transaction field encoding: %w", err)
+		}
+		return decoded
+	}
+
+	tags := make([]interface{}, 0, len(tx.Tags))
+	for _, tag := range tx.Tags {
+		tags = append(tags, []interface{}{decode(tag.Name), decode(tag.Value)})
+	}
+
+	hash := arweaveDeepHash([]interface{}{
+		[]byte(strconv.Itoa(tx.Format)),
+		owner,
+		decode(tx.Target),
+		[]byte(tx.Quantity),
+		[]byte(tx.Reward),
+		decode(tx.LastTx),
+		tags,
+		[]byte(tx.DataSize),
+		decode(tx.DataRoot),
+	})
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return hash, nil
+}
+
+// arweaveDeepHash hashes nested lists of byte strings with SHA-384, tagging every value with its type and length.
+func arweaveDeepHash(value interface{}) []byte {
+	switch value := value.(type) {
+	case []interface{}:
+		acc := sha384([]byte("list" + strconv.Itoa(len(value))))
+		for _, item := range value {
+			acc = sha384(acc, arweaveDeepHash(item))
+		}
+		return acc
+	case []byte:
+		return sha384(sha384([]byte("blob"+strconv.Itoa(len(value)))), sha384(value))
+	default:
+		panic(fmt.Sprintf("unsupported deep hash value %T", value))
+	}
+}
+
+// arweaveDataRoot computes the merkle root of data that fits in a single chunk, which is the hash of its only leaf.
+func arweaveDataRoot(data []byte) []byte {
+	dataHash := sha256.Sum256(data)
+
+	note := make([]byte, arweaveNoteSize)
+	new(big.Int).SetInt64(int64(len(data))).FillBytes(note)
+
+	return sha256Concat(sha256Concat(dataHash[:]), sha256Concat(note))
+}
+
+func sha384(parts ...[]byte) []byte {
+	hash := sha512.Sum384(bytes.Join(parts, nil))
+	return hash[:]
+}
+
+func sha256Concat(parts ...[]byte) []byte {
+	hash := sha256.Sum256(bytes.Join(parts, nil))
+	return hash[:]
+}
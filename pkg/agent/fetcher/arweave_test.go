@@ -0,0 +1,95 @@
+package fetcher
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signArweaveTransaction builds a format 2 transaction for content signed by key, returning it with its id.
+func signArweaveTransaction(t *testing.T, key *rsa.PrivateKey, content []byte) (*arweaveTransaction, string) {
+	tx := &arweaveTransaction{
+		Format:   arweaveTransactionFormat,
+		LastTx:   arweaveEncoding.EncodeToString(make([]byte, 32)),
+		Owner:    arweaveEncoding.EncodeToString(key.N.Bytes()),
+		Tags:     []arweaveTag{{Name: arweaveEncoding.EncodeToString([]byte("Content-Type")), Value: arweaveEncoding.EncodeToString([]byte("text/plain"))}},
+		Quantity: "0",
+		DataSize: strconv.Itoa(len(content)),
+		DataRoot: arweaveEncoding.EncodeToString(arweaveDataRoot(content)),
+		Reward:   "1000",
+	}
+
+	signatureData, err := tx.signatureData(key.N.Bytes())
+	require.NoError(t, err)
+	digest := sha256.Sum256(signatureData)
+	signature, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: 32})
+	require.NoError(t, err)
+	tx.Signature = arweaveEncoding.EncodeToString(signature)
+
+	id := sha256.Sum256(signature)
+	return tx, arweaveEncoding.EncodeToString(id[:])
+}
+
+func newArweaveGateway(t *testing.T, id string, tx *arweaveTransaction, content []byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tx/" + id:
+			json.NewEncoder(w).Encode(tx)
+		case "/" + id:
+			w.Write(content)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetcher_FetchArweave(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	content := []byte("system prompt")
+	tx, id := signArweaveTransaction(t, key, content)
+
+	newFetcher := func(gateways ...string) *Fetcher {
+		f, err := NewFetcher(FetcherConfig{ArweaveGateways: gateways, MaxSize: 100})
+		require.NoError(t, err)
+		return f
+	}
+
+	t.Run("verified content", func(t *testing.T) {
+		gateway := newArweaveGateway(t, id, tx, content)
+
+		got, err := newFetcher(gateway.URL).Fetch(context.Background(), "ar://"+id)
+		require.NoError(t, err)
+		assert.Equal(t, content, got)
+	})
+
+	t.Run("swapped content", func(t *testing.T) {
+		gateway := newArweaveGateway(t, id, tx, []byte("another prompt"))
+
+		_, err := newFetcher(gateway.URL).Fetch(context.Background(), "ar://"+id)
+		assert.Error(t, err)
+	})
+
+	t.Run("swapped transaction", func(t *testing.T) {
+		otherTx, _ := signArweaveTransaction(t, key, []byte("another prompt"))
+		swapped := newArweaveGateway(t, id, otherTx, []byte("another prompt"))
+		honest := newArweaveGateway(t, id, tx, content)
+
+		got, err := newFetcher(swapped.URL, honest.URL).Fetch(context.Background(), "ar://"+id)
+		require.NoError(t, err)
+		assert.Equal(t, content, got)
+	})
+}
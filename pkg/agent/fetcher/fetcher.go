@@ -0,0 +1,180 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrTooLarge is returned when the content behind a uri exceeds the configured size limit
+	ErrTooLarge = errors.New("content too large")
+	// ErrUnsupportedUri is returned for uris that cannot be resolved, such as unknown schemes or malformed identifiers
+	ErrUnsupportedUri = errors.New("unsupported uri")
+)
+
+var (
+	DefaultIpfsGateways    = []string{"https://ipfs.io", "https://dweb.link", "https://gateway.pinata.cloud"}
+	DefaultArweaveGateways = []string{"https://arweave.net", "https://ar-io.net"}
+)
+
+const (
+	schemeIpfs    = "ipfs://"
+	schemeArweave = "ar://"
+)
+
+type FetcherConfig struct {
+	HttpClient      *http.Client
+	IpfsGateways    []string
+	ArweaveGateways []string
+	// MaxSize is the exclusive upper bound on the size of fetched content
+	MaxSize int64
+}
+
+// Fetcher reads content from http(s) uris and from content-addressed ipfs:// and ar:// uris. Content-addressed
+// uris are resolved through gateways, falling back to the next one when a gateway fails or serves content that does
+// not match the uri.
+type Fetcher struct {
+	httpClient      *http.Client
+	ipfsGateways    []string
+	arweaveGateways []string
+	maxSize         int64
+}
+
+func NewFetcher(config FetcherConfig) (*Fetcher, error) {
+	if config.MaxSize <= 0 {
+		return nil, errors.New("max size must be positive")
+	}
+
+	httpClient := config.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	ipfsGateways := config.IpfsGateways
+	if len(ipfsGateways) == 0 {
+		ipfsGateways = DefaultIpfsGateways
+	}
+
+	arweaveGateways := config.ArweaveGateways
+	if len(arweaveGateways) == 0 {
+		arweaveGateways = DefaultArweaveGateways
+	}
+
+	return &Fetcher{
+		httpClient:      httpClient,
+		ipfsGateways:    trimGateways(ipfsGateways),
+		arweaveGateways: trimGateways(arweaveGateways),
+		maxSize:         config.MaxSize,
+	}, nil
+}
+
+func (f *Fetcher) IpfsGateways() []string {
+	return f.ipfsGateways
+}
+
+func (f *Fetcher) ArweaveGateways() []string {
+	return f.arweaveGateways
+}
+
+func (f *Fetcher) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(uri, schemeIpfs):
+		return f.fetchIpfs(ctx, strings.TrimPrefix(uri, schemeIpfs))
+	case strings.HasPrefix(uri, schemeArweave):
+		return f.fetchArweave(ctx, strings.TrimPrefix(uri, schemeArweave))
+	case strings.HasPrefix(uri, "https://"), strings.HasPrefix(uri, "http://"):
+		return f.fetchHttp(ctx, uri)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedUri, uri)
+	}
+}
+
+// fetchFromGateways tries every gateway in order until one serves content that passes verification. Gateway errors are
+// not wrapped and the content is only reported as too large when every gateway agrees, so that a single misbehaving
+// gateway cannot get the uri rejected.
+func (f *Fetcher) fetchFromGateways(ctx context.Context, gateways []string, fetch func(gateway string) ([]byte, error)) ([]byte, error) {
+	var errs []error
+	tooLarge := 0
+	for _, gateway := range gateways {
+		content, err := fetch(gateway)
+		if err == nil {
+			return content, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		slog.Warn("failed to fetch from gateway", "gateway", gateway, "error", err)
+		if errors.Is(err, ErrTooLarge) {
+			tooLarge++
+		}
+		errs = append(errs, fmt.Errorf("%s: %v", gateway, err))
+	}
+
+	if tooLarge == len(gateways) {
+		return nil, ErrTooLarge
+	}
+
+	return nil, fmt.Errorf("all gateways failed: %w", errors.Join(errs...))
+}
+
+func (f *Fetcher) fetchHttp(ctx context.Context, uri string) ([]byte, error) {
+	headReq, err := http.NewRequestWithContext(ctx, http.MethodHead, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HEAD request: %w", err)
+	}
+
+	headResp, err := f.httpClient.Do(headReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform HEAD request: %w", err)
+	}
+	headResp.Body.Close()
+
+	if headResp.ContentLength >= f.maxSize {
+		return nil, fmt.Errorf("%w: size %d exceeds the limit of %d bytes", ErrTooLarge, headResp.ContentLength, f.maxSize)
+	}
+
+	return f.get(ctx, uri)
+}
+
+func (f *Fetcher) get(ctx context.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GET request: %w", err)
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform GET request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	// HEAD may omit or misreport the length, so the limit is also enforced on the body itself
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) >= f.maxSize {
+		return nil, fmt.Errorf("%w: size exceeds the limit of %d bytes", ErrTooLarge, f.maxSize)
+	}
+
+	return body, nil
+}
+
+func trimGateways(gateways []string) []string {
+	trimmed := make([]string, 0, len(gateways))
+	for _, gateway := range gateways {
+		trimmed = append(trimmed, strings.TrimSuffix(gateway, "/"))
+	}
+
+	return trimmed
+}
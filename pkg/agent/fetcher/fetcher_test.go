@@ -0,0 +1,116 @@
+package fetcher_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/fetcher"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+)
+
+const testMaxSize = 100
+
+func newTestFetcher(t *testing.T, ipfsGateways ...string) *fetcher.Fetcher {
+	fetcher, err := fetcher.NewFetcher(fetcher.FetcherConfig{
+		IpfsGateways: ipfsGateways,
+		MaxSize:      testMaxSize,
+	})
+	require.NoError(t, err)
+	return fetcher
+}
+
+func newGateway(t *testing.T, content []byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasPrefix(r.URL.Path, "/ipfs/"))
+		w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetcher_FetchIpfs(t *testing.T) {
+	content := []byte("system prompt")
+	cid, err := ipfs.CidV0(content)
+	require.NoError(t, err)
+	uri := "ipfs://" + cid.String()
+
+	t.Run("verified content", func(t *testing.T) {
+		gateway := newGateway(t, content)
+
+		got, err := newTestFetcher(t, gateway.URL).Fetch(context.Background(), uri)
+		require.NoError(t, err)
+		assert.Equal(t, content, got)
+	})
+
+	t.Run("falls back when a gateway swaps the content", func(t *testing.T) {
+		swapped := newGateway(t, []byte("another prompt"))
+		honest := newGateway(t, content)
+
+		got, err := newTestFetcher(t, swapped.URL, honest.URL).Fetch(context.Background(), uri)
+		require.NoError(t, err)
+		assert.Equal(t, content, got)
+	})
+
+	t.Run("fails when no gateway serves matching content", func(t *testing.T) {
+		swapped := newGateway(t, []byte("another prompt"))
+
+		_, err := newTestFetcher(t, swapped.URL).Fetch(context.Background(), uri)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, fetcher.ErrUnsupportedUri)
+	})
+
+	t.Run("raw cid", func(t *testing.T) {
+		gateway := newGateway(t, content)
+
+		got, err := newTestFetcher(t, gateway.URL).Fetch(context.Background(), "ipfs://"+ipfs.CidV1Raw(content).String())
+		require.NoError(t, err)
+		assert.Equal(t, content, got)
+	})
+
+	t.Run("too large on every gateway", func(t *testing.T) {
+		gateway := newGateway(t, make([]byte, testMaxSize))
+
+		_, err := newTestFetcher(t, gateway.URL).Fetch(context.Background(), uri)
+		assert.ErrorIs(t, err, fetcher.ErrTooLarge)
+	})
+}
+
+func TestFetcher_FetchUnsupported(t *testing.T) {
+	f := newTestFetcher(t)
+
+	for _, uri := range []string{
+		"ftp://example.com/prompt",
+		"ipfs://not-a-cid",
+		"ipfs://QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o/prompt.txt",
+		"ar://not-a-transaction-id",
+	} {
+		_, err := f.Fetch(context.Background(), uri)
+		assert.ErrorIs(t, err, fetcher.ErrUnsupportedUri, uri)
+	}
+}
+
+func TestFetcher_FetchHttp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			w.Write(make([]byte, testMaxSize))
+			return
+		}
+		w.Write([]byte("system prompt"))
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(t)
+
+	got, err := f.Fetch(context.Background(), server.URL+"/prompt")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("system prompt"), got)
+
+	_, err = f.Fetch(context.Background(), server.URL+"/large")
+	assert.ErrorIs(t, err, fetcher.ErrTooLarge)
+}
@@ -0,0 +1,35 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+)
+
+// fetchIpfs resolves a bare cid through the ipfs gateways. Paths inside a directory are not supported because the
+// directory listing would have to be verified as well.
+func (f *Fetcher) fetchIpfs(ctx context.Context, rawCid string) ([]byte, error) {
+	if strings.Contains(rawCid, "/") {
+		return nil, fmt.Errorf("%w: ipfs paths are not supported", ErrUnsupportedUri)
+	}
+
+	cid, err := ipfs.ParseCid(rawCid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedUri, err)
+	}
+
+	return f.fetchFromGateways(ctx, f.ipfsGateways, func(gateway string) ([]byte, error) {
+		content, err := f.get(ctx, gateway+"/ipfs/"+rawCid)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := cid.Verify(content); err != nil {
+			return nil, err
+		}
+
+		return content, nil
+	})
+}
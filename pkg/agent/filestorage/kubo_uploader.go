@@ -0,0 +1,132 @@
+package filestorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+)
+
+const kuboAddPath = "/api/v0/add"
+
+// kuboAddParams pin the importer settings that ipfs.CidV1File assumes, whatever the node's defaults are
+var kuboAddParams = url.Values{
+	"cid-version": {"1"},
+	"raw-leaves":  {"true"},
+	"chunker":     {fmt.Sprintf("size-%d", ipfs.MaxBlockSize)},
+	"hash":        {"sha2-256"},
+	"pin":         {"true"},
+	"progress":    {"false"},
+}
+
+type KuboUploaderConfig struct {
+	HttpClient *http.Client
+	// ApiUrl is the RPC API endpoint of the Kubo node, e.g. http://localhost:5001
+	ApiUrl string
+}
+
+// KuboUploader adds and pins files on a self-hosted Kubo node. The cid of every upload is computed locally and uploads
+// the node reports a different cid for are rejected, so the returned cids are known to match the content.
+type KuboUploader struct {
+	httpClient *http.Client
+	apiUrl     string
+}
+
+var _ Uploader = (*KuboUploader)(nil)
+
+type kuboAddResponse struct {
+	Name string `json:"Name"`
+	Hash string `json:"Hash"`
+}
+
+func NewKuboUploader(config KuboUploaderConfig) (*KuboUploader, error) {
+	if config.ApiUrl == "" {
+		return nil, errors.New("kubo api url is required")
+	}
+
+	httpClient := config.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &KuboUploader{
+		httpClient: httpClient,
+		apiUrl:     strings.TrimSuffix(config.ApiUrl, "/"),
+	}, nil
+}
+
+func (u *KuboUploader) UploadFile(ctx context.Context, fileName string, data []byte) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %v", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("failed to write form file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close form: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.apiUrl+kuboAddPath+"?"+kuboAddParams.Encode(), &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file to kubo: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to upload file to kubo: unexpected status code %d", resp.StatusCode)
+	}
+
+	// the response is a stream of JSON objects, the last of which describes the added file
+	var addResponse kuboAddResponse
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &addResponse); err != nil {
+			return "", fmt.Errorf("failed to decode kubo response: %v", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read kubo response: %v", err)
+	}
+
+	cid := ipfs.CidV1File(data)
+
+	reported, err := ipfs.ParseCid(addResponse.Hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse kubo cid %q: %v", addResponse.Hash, err)
+	}
+	if reported.String() != cid.String() {
+		return "", fmt.Errorf("%w: kubo reported %s, expected %s", ipfs.ErrCidMismatch, reported, cid)
+	}
+
+	return cid.String(), nil
+}
+
+func (u *KuboUploader) UploadJson(ctx context.Context, value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal json: %v", err)
+	}
+
+	return u.UploadFile(ctx, "metadata.json", data)
+}
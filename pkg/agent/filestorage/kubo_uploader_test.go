@@ -0,0 +1,90 @@
+package filestorage_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+)
+
+// newTestKubo serves /api/v0/add, reporting the cid returned by reportCid for the uploaded content.
+func newTestKubo(t *testing.T, reportCid func(data []byte) string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v0/add" {
+			http.NotFound(w, r)
+			return
+		}
+
+		assert.Equal(t, "1", r.URL.Query().Get("cid-version"))
+		assert.Equal(t, "true", r.URL.Query().Get("raw-leaves"))
+		assert.Equal(t, "true", r.URL.Query().Get("pin"))
+
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+
+		fmt.Fprintf(w, "{\"Name\":%q,\"Hash\":%q,\"Size\":\"%d\"}\n", header.Filename, reportCid(data), len(data))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestKuboUploader_UploadFile(t *testing.T) {
+	kubo := newTestKubo(t, func(data []byte) string {
+		return ipfs.CidV1File(data).String()
+	})
+
+	uploader, err := filestorage.NewKuboUploader(filestorage.KuboUploaderConfig{ApiUrl: kubo.URL})
+	require.NoError(t, err)
+
+	for _, size := range []int{12, ipfs.MaxBlockSize + 1} {
+		data := make([]byte, size)
+
+		cid, err := uploader.UploadFile(context.Background(), "image.png", data)
+		require.NoError(t, err)
+		assert.Equal(t, ipfs.CidV1File(data).String(), cid)
+		assert.Equal(t, "ipfs://"+cid, filestorage.Uri(cid))
+	}
+
+	cid, err := uploader.UploadJson(context.Background(), map[string]string{"name": "test"})
+	require.NoError(t, err)
+	assert.Equal(t, ipfs.CidV1Raw([]byte(`{"name":"test"}`)).String(), cid)
+}
+
+func TestKuboUploader_RejectsMismatchingCid(t *testing.T) {
+	kubo := newTestKubo(t, func(data []byte) string {
+		return ipfs.CidV1Raw([]byte("other content")).String()
+	})
+
+	uploader, err := filestorage.NewKuboUploader(filestorage.KuboUploaderConfig{ApiUrl: kubo.URL})
+	require.NoError(t, err)
+
+	_, err = uploader.UploadFile(context.Background(), "image.png", []byte("content"))
+	assert.ErrorIs(t, err, ipfs.ErrCidMismatch)
+}
+
+func TestKuboUploader_Errors(t *testing.T) {
+	_, err := filestorage.NewKuboUploader(filestorage.KuboUploaderConfig{})
+	assert.Error(t, err)
+
+	kubo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer kubo.Close()
+
+	uploader, err := filestorage.NewKuboUploader(filestorage.KuboUploaderConfig{ApiUrl: kubo.URL})
+	require.NoError(t, err)
+
+	_, err = uploader.UploadFile(context.Background(), "image.png", []byte("content"))
+	assert.Error(t, err)
+}
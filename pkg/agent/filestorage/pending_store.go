@@ -0,0 +1,57 @@
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/sealing"
+)
+
+type PendingStore interface {
+	Load(ctx context.Context) ([]*PendingReplica, error)
+	Save(ctx context.Context, pending []*PendingReplica) error
+}
+
+type FilePendingStore struct {
+	dstackTappdEndpoint string
+	filePath            string
+}
+
+var _ PendingStore = (*FilePendingStore)(nil)
+
+func NewFilePendingStore(dstackTappdEndpoint string, filePath string) *FilePendingStore {
+	return &FilePendingStore{
+		dstackTappdEndpoint: dstackTappdEndpoint,
+		filePath:            filePath,
+	}
+}
+
+func (s *FilePendingStore) Load(ctx context.Context) ([]*PendingReplica, error) {
+	if _, err := os.Stat(s.filePath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	data, err := sealing.ReadSealedFile(ctx, s.dstackTappdEndpoint, s.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending replicas: %v", err)
+	}
+
+	var pending []*PendingReplica
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending replicas: %v", err)
+	}
+
+	return pending, nil
+}
+
+func (s *FilePendingStore) Save(ctx context.Context, pending []*PendingReplica) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending replicas: %v", err)
+	}
+
+	return sealing.WriteSealedFile(ctx, s.dstackTappdEndpoint, s.filePath, data)
+}
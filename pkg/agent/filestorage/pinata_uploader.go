@@ -1,36 +1,130 @@
 package filestorage
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"mime/multipart"
+	"net/http"
 
 	"github.com/zde37/pinata-go-sdk/pinata"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+)
+
+const (
+	pinataPinFileUrl = "https://api.pinata.cloud/pinning/pinFileToIPFS"
+	// pinataCidV1Options make Pinata build the CIDv1 DAG of raw leaves that ipfs.CidV1File assumes
+	pinataCidV1Options = `{"cidVersion":1}`
 )
 
+type PinataUploaderConfig struct {
+	HttpClient *http.Client
+	JwtKey     string
+	// CidV1 pins files as CIDv1 and rejects uploads Pinata reports a different cid than ipfs.CidV1File for, so that
+	// the cids agree with other IPFS replicas. Files are pinned as CIDv0 otherwise.
+	CidV1 bool
+}
+
 type PinataUploader struct {
 	jwtKey string
+	cidV1  bool
 
-	client *pinata.Client
+	client     *pinata.Client
+	httpClient *http.Client
 }
 
-func NewPinataUploader(jwtKey string) *PinataUploader {
+var _ Uploader = (*PinataUploader)(nil)
+
+type pinataPinResponse struct {
+	IpfsHash string `json:"IpfsHash"`
+}
+
+func NewPinataUploader(config PinataUploaderConfig) *PinataUploader {
+	httpClient := config.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
 	return &PinataUploader{
-		jwtKey: jwtKey,
-		client: pinata.New(pinata.NewAuthWithJWT(jwtKey)),
+		jwtKey:     config.JwtKey,
+		cidV1:      config.CidV1,
+		client:     pinata.New(pinata.NewAuthWithJWT(config.JwtKey)),
+		httpClient: httpClient,
 	}
 }
 
-func (u *PinataUploader) UploadUrl(ctx context.Context, fileUrl string) (string, error) {
-	pinResponse, err := u.client.PinURL(fileUrl, nil)
+func (u *PinataUploader) UploadFile(ctx context.Context, fileName string, data []byte) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %v", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("failed to write form file: %v", err)
+	}
+	if u.cidV1 {
+		if err := writer.WriteField("pinataOptions", pinataCidV1Options); err != nil {
+			return "", fmt.Errorf("failed to write pinata options: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close form: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pinataPinFileUrl, &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+u.jwtKey)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := u.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to pinata: %v", err)
 	}
+	defer resp.Body.Close()
 
-	return pinResponse.IpfsHash, nil
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to upload file to pinata: unexpected status code %d", resp.StatusCode)
+	}
+
+	var pinResponse pinataPinResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pinResponse); err != nil {
+		return "", fmt.Errorf("failed to decode pinata response: %v", err)
+	}
+
+	if !u.cidV1 {
+		return pinResponse.IpfsHash, nil
+	}
+
+	cid := ipfs.CidV1File(data)
+
+	reported, err := ipfs.ParseCid(pinResponse.IpfsHash)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse pinata cid %q: %v", pinResponse.IpfsHash, err)
+	}
+	if reported.String() != cid.String() {
+		return "", fmt.Errorf("%w: pinata reported %s, expected %s", ipfs.ErrCidMismatch, reported, cid)
+	}
+
+	return cid.String(), nil
 }
 
-func (u *PinataUploader) UploadJson(ctx context.Context, json interface{}) (string, error) {
-	pinResponse, err := u.client.PinJSON(json, nil)
+func (u *PinataUploader) UploadJson(ctx context.Context, value interface{}) (string, error) {
+	if u.cidV1 {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal json: %v", err)
+		}
+
+		return u.UploadFile(ctx, "metadata.json", data)
+	}
+
+	pinResponse, err := u.client.PinJSON(value, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to pinata: %v", err)
 	}
@@ -0,0 +1,91 @@
+package filestorage_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+)
+
+// redirectTransport sends every request to the test server instead of its host.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (r redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newTestPinata serves pinFileToIPFS, reporting the cid returned by reportCid for the uploaded content and options.
+func newTestPinata(t *testing.T, reportCid func(data []byte, options string) string) *http.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/pinning/pinFileToIPFS" {
+			http.NotFound(w, r)
+			return
+		}
+
+		assert.Equal(t, "Bearer jwt", r.Header.Get("Authorization"))
+
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]string{"IpfsHash": reportCid(data, r.FormValue("pinataOptions"))}))
+	}))
+	t.Cleanup(server.Close)
+
+	target, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	return &http.Client{Transport: redirectTransport{target: target}}
+}
+
+func TestPinataUploader_CidV1(t *testing.T) {
+	// pinata pins as CIDv0 unless asked for CIDv1
+	httpClient := newTestPinata(t, func(data []byte, options string) string {
+		if options == `{"cidVersion":1}` {
+			return ipfs.CidV1File(data).String()
+		}
+		return "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"
+	})
+
+	data := make([]byte, ipfs.MaxBlockSize+1)
+
+	uploader := filestorage.NewPinataUploader(filestorage.PinataUploaderConfig{HttpClient: httpClient, JwtKey: "jwt"})
+	cid, err := uploader.UploadFile(context.Background(), "image.png", data)
+	require.NoError(t, err)
+	assert.Equal(t, "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", cid)
+
+	// as a replica, pinata agrees with kubo on the cid of the same content
+	uploader = filestorage.NewPinataUploader(filestorage.PinataUploaderConfig{HttpClient: httpClient, JwtKey: "jwt", CidV1: true})
+	cid, err = uploader.UploadFile(context.Background(), "image.png", data)
+	require.NoError(t, err)
+	assert.Equal(t, ipfs.CidV1File(data).String(), cid)
+
+	cid, err = uploader.UploadJson(context.Background(), map[string]string{"name": "test"})
+	require.NoError(t, err)
+	assert.Equal(t, ipfs.CidV1Raw([]byte(`{"name":"test"}`)).String(), cid)
+}
+
+func TestPinataUploader_CidV1Mismatch(t *testing.T) {
+	httpClient := newTestPinata(t, func(data []byte, options string) string {
+		return ipfs.CidV1Raw(append(data, '!')).String()
+	})
+
+	uploader := filestorage.NewPinataUploader(filestorage.PinataUploaderConfig{HttpClient: httpClient, JwtKey: "jwt", CidV1: true})
+	_, err := uploader.UploadFile(context.Background(), "image.png", []byte("image"))
+	assert.ErrorIs(t, err, ipfs.ErrCidMismatch)
+}
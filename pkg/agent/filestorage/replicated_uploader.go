@@ -0,0 +1,433 @@
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+)
+
+const (
+	defaultReplicaRetryInterval = 1 * time.Minute
+	defaultReplicaMaxAttempts   = 10
+	defaultReplicaTimeout       = 5 * time.Minute
+	maxReplicaBackoff           = 1 * time.Hour
+	// defaultMaxPendingBytes bounds the data held, and persisted, for replicas waiting for a retry
+	defaultMaxPendingBytes = 64 << 20
+)
+
+var ErrQuorumNotReached = errors.New("upload quorum not reached")
+
+type ReplicatedUploaderConfig struct {
+	// Uploaders are the replicas by backend name
+	Uploaders map[string]Uploader
+	// Mirrors by backend name receive every upload and are retried like replicas, but do not count toward the quorum.
+	// Backends that return references of their own, such as S3 urls, can only be mirrors.
+	Mirrors map[string]Uploader
+	// Quorum is the number of replicas that must agree on a reference. Defaults to a majority.
+	Quorum        int
+	RetryInterval time.Duration
+	MaxAttempts   int
+	// ReplicaTimeout bounds every upload to a single replica
+	ReplicaTimeout time.Duration
+	// PendingStore persists the replicas waiting for a retry. They are kept in memory only if nil.
+	PendingStore PendingStore
+	// MaxPendingBytes bounds the data of the replicas waiting for a retry; the oldest are dropped beyond it. Defaults
+	// to 64 MiB.
+	MaxPendingBytes int
+}
+
+// ReplicatedUploader uploads to several backends at once and returns once a quorum of them reports the same
+// reference. IPFS cids are compared regardless of their multibase, but replicas only agree if they build the same
+// DAG, so every IPFS backend has to produce the same cid version. Replicas that fail are retried in the background by
+// Run until they succeed, so that content stays available when a single provider drops it. Pending retries are
+// persisted to the PendingStore and resumed by Run after a restart.
+//
+// Mirrors are uploaded to alongside the replicas, so a mirror that fails is retried but never fails an upload.
+type ReplicatedUploader struct {
+	replicas       []replica
+	quorum         int
+	retryInterval  time.Duration
+	maxAttempts    int
+	replicaTimeout time.Duration
+
+	store           PendingStore
+	maxPendingBytes int
+	saveMu          sync.Mutex
+
+	mu           sync.Mutex
+	pending      []*PendingReplica
+	pendingBytes int
+}
+
+var (
+	_ Uploader = (*ReplicatedUploader)(nil)
+	_ Runner   = (*ReplicatedUploader)(nil)
+)
+
+type replica struct {
+	backend  string
+	uploader Uploader
+	mirror   bool
+}
+
+type replicaResult struct {
+	replica   replica
+	reference string
+	err       error
+}
+
+// PendingReplica is an upload to a single backend that failed after the quorum was reached, waiting for a retry.
+type PendingReplica struct {
+	Backend     string    `json:"backend"`
+	FileName    string    `json:"fileName"`
+	Data        []byte    `json:"data"`
+	Reference   string    `json:"reference"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+}
+
+func NewReplicatedUploader(config ReplicatedUploaderConfig) (*ReplicatedUploader, error) {
+	if len(config.Uploaders) == 0 {
+		return nil, errors.New("at least one uploader is required")
+	}
+
+	quorum := config.Quorum
+	if quorum == 0 {
+		quorum = len(config.Uploaders)/2 + 1
+	}
+	if quorum < 1 || quorum > len(config.Uploaders) {
+		return nil, fmt.Errorf("quorum must be between 1 and %d", len(config.Uploaders))
+	}
+
+	retryInterval := config.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = defaultReplicaRetryInterval
+	}
+
+	maxAttempts := config.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultReplicaMaxAttempts
+	}
+
+	replicaTimeout := config.ReplicaTimeout
+	if replicaTimeout == 0 {
+		replicaTimeout = defaultReplicaTimeout
+	}
+
+	maxPendingBytes := config.MaxPendingBytes
+	if maxPendingBytes == 0 {
+		maxPendingBytes = defaultMaxPendingBytes
+	}
+
+	var replicas []replica
+	for backend, uploader := range config.Uploaders {
+		replicas = append(replicas, replica{backend: backend, uploader: uploader})
+	}
+	for backend, uploader := range config.Mirrors {
+		if _, ok := config.Uploaders[backend]; ok {
+			return nil, fmt.Errorf("%s is both an uploader and a mirror", backend)
+		}
+		replicas = append(replicas, replica{backend: backend, uploader: uploader, mirror: true})
+	}
+	sort.Slice(replicas, func(i, j int) bool {
+		return replicas[i].backend < replicas[j].backend
+	})
+
+	return &ReplicatedUploader{
+		replicas:       replicas,
+		quorum:         quorum,
+		retryInterval:  retryInterval,
+		maxAttempts:    maxAttempts,
+		replicaTimeout: replicaTimeout,
+
+		store:           config.PendingStore,
+		maxPendingBytes: maxPendingBytes,
+	}, nil
+}
+
+func (u *ReplicatedUploader) Quorum() int {
+	return u.quorum
+}
+
+// Mirrors returns the backend names of the mirrors.
+func (u *ReplicatedUploader) Mirrors() []string {
+	var mirrors []string
+	for _, r := range u.replicas {
+		if r.mirror {
+			mirrors = append(mirrors, r.backend)
+		}
+	}
+
+	return mirrors
+}
+
+func (u *ReplicatedUploader) UploadFile(ctx context.Context, fileName string, data []byte) (string, error) {
+	return u.replicate(ctx, fileName, data)
+}
+
+// UploadJson encodes the document once and uploads it as a file, so that every replica stores the same bytes.
+func (u *ReplicatedUploader) UploadJson(ctx context.Context, value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal json: %v", err)
+	}
+
+	return u.replicate(ctx, "metadata.json", data)
+}
+
+func (u *ReplicatedUploader) replicate(ctx context.Context, fileName string, data []byte) (string, error) {
+	// replicas keep uploading after the quorum is reached, so they must not be cancelled with the caller
+	replicaCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), u.replicaTimeout)
+
+	results := make(chan replicaResult, len(u.replicas))
+	var wg sync.WaitGroup
+	for _, r := range u.replicas {
+		wg.Add(1)
+		go func(r replica) {
+			defer wg.Done()
+			reference, err := r.uploader.UploadFile(replicaCtx, fileName, data)
+			results <- replicaResult{replica: r, reference: reference, err: err}
+		}(r)
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(results)
+	}()
+
+	votes := make(map[string]int)
+	var received []replicaResult
+	var errs []error
+	for len(received) < len(u.replicas) {
+		var result replicaResult
+		select {
+		case result = <-results:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		received = append(received, result)
+
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.replica.backend, result.err))
+			continue
+		}
+		if result.replica.mirror {
+			continue
+		}
+
+		reference := normalizeReference(result.reference)
+		votes[reference]++
+		if votes[reference] < u.quorum {
+			continue
+		}
+
+		for _, earlier := range received {
+			u.handleReplicaResult(earlier, reference, fileName, data)
+		}
+		go func() {
+			for late := range results {
+				u.handleReplicaResult(late, reference, fileName, data)
+			}
+		}()
+
+		return reference, nil
+	}
+
+	return "", fmt.Errorf("%w: %d replicas needed: %w", ErrQuorumNotReached, u.quorum, errors.Join(errs...))
+}
+
+// handleReplicaResult queues a failed replica of a successful upload for retry.
+func (u *ReplicatedUploader) handleReplicaResult(result replicaResult, reference string, fileName string, data []byte) {
+	if result.err != nil {
+		slog.Warn("failed to upload replica, retrying later", "backend", result.replica.backend, "reference", reference, "error", result.err)
+		u.enqueue(&PendingReplica{
+			Backend:     result.replica.backend,
+			FileName:    fileName,
+			Data:        data,
+			Reference:   reference,
+			NextAttempt: time.Now().Add(u.retryInterval),
+		})
+		u.savePending(context.Background())
+		return
+	}
+
+	if result.replica.mirror {
+		return
+	}
+	if normalized := normalizeReference(result.reference); normalized != reference {
+		slog.Warn("replica stored content under a different reference", "backend", result.replica.backend, "reference", reference, "replicaReference", normalized)
+	}
+}
+
+// enqueue adds a pending replica, dropping the oldest ones once their data exceeds maxPendingBytes.
+func (u *ReplicatedUploader) enqueue(pending *PendingReplica) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.pending = append(u.pending, pending)
+	u.pendingBytes += len(pending.Data)
+
+	for u.pendingBytes > u.maxPendingBytes {
+		dropped := u.pending[0]
+		slog.Error("dropping pending replica", "backend", dropped.Backend, "reference", dropped.Reference, "size", len(dropped.Data))
+		u.pending = u.pending[1:]
+		u.pendingBytes -= len(dropped.Data)
+	}
+}
+
+// Pending returns the number of replicas waiting for a retry.
+func (u *ReplicatedUploader) Pending() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return len(u.pending)
+}
+
+// LoadPending resumes the replicas persisted by an earlier run, ahead of the ones queued since. Replicas of backends
+// that are no longer configured are dropped.
+func (u *ReplicatedUploader) LoadPending(ctx context.Context) error {
+	if u.store == nil {
+		return nil
+	}
+
+	loaded, err := u.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	queued := u.pending
+	u.pending = nil
+	u.pendingBytes = 0
+	u.mu.Unlock()
+
+	for _, pending := range append(loaded, queued...) {
+		if _, ok := u.replica(pending.Backend); !ok {
+			slog.Warn("dropping pending replica of unknown backend", "backend", pending.Backend, "reference", pending.Reference)
+			continue
+		}
+		u.enqueue(pending)
+	}
+
+	slog.Info("loaded pending replicas", "replicas", len(loaded))
+	return nil
+}
+
+func (u *ReplicatedUploader) savePending(ctx context.Context) {
+	if u.store == nil {
+		return
+	}
+
+	u.saveMu.Lock()
+	defer u.saveMu.Unlock()
+
+	u.mu.Lock()
+	pending := make([]*PendingReplica, 0, len(u.pending))
+	for _, p := range u.pending {
+		pendingCopy := *p
+		pending = append(pending, &pendingCopy)
+	}
+	u.mu.Unlock()
+
+	if err := u.store.Save(ctx, pending); err != nil {
+		slog.Error("failed to save pending replicas", "error", err)
+	}
+}
+
+func (u *ReplicatedUploader) replica(backend string) (replica, bool) {
+	for _, r := range u.replicas {
+		if r.backend == backend {
+			return r, true
+		}
+	}
+
+	return replica{}, false
+}
+
+// Run resumes the persisted replicas and retries failed replicas until ctx is done.
+func (u *ReplicatedUploader) Run(ctx context.Context) {
+	if err := u.LoadPending(ctx); err != nil {
+		slog.Error("failed to load pending replicas", "error", err)
+	}
+
+	ticker := time.NewTicker(u.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.RetryPending(ctx)
+		}
+	}
+}
+
+// RetryPending retries the replicas whose backoff has elapsed.
+func (u *ReplicatedUploader) RetryPending(ctx context.Context) {
+	now := time.Now()
+
+	u.mu.Lock()
+	var due []*PendingReplica
+	var waiting []*PendingReplica
+	waitingBytes := 0
+	for _, pending := range u.pending {
+		if now.Before(pending.NextAttempt) {
+			waiting = append(waiting, pending)
+			waitingBytes += len(pending.Data)
+		} else {
+			due = append(due, pending)
+		}
+	}
+	u.pending = waiting
+	u.pendingBytes = waitingBytes
+	u.mu.Unlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	for _, pending := range due {
+		r, _ := u.replica(pending.Backend)
+
+		replicaCtx, cancel := context.WithTimeout(ctx, u.replicaTimeout)
+		reference, err := r.uploader.UploadFile(replicaCtx, pending.FileName, pending.Data)
+		cancel()
+
+		if err == nil {
+			slog.Info("uploaded pending replica", "backend", pending.Backend, "reference", pending.Reference)
+			u.handleReplicaResult(replicaResult{replica: r, reference: reference}, pending.Reference, pending.FileName, pending.Data)
+			continue
+		}
+
+		pending.Attempts++
+		if pending.Attempts >= u.maxAttempts {
+			slog.Error("giving up on replica", "backend", pending.Backend, "reference", pending.Reference, "attempts", pending.Attempts, "error", err)
+			continue
+		}
+
+		backoff := min(u.retryInterval<<pending.Attempts, maxReplicaBackoff)
+		pending.NextAttempt = time.Now().Add(backoff)
+		slog.Warn("failed to upload pending replica", "backend", pending.Backend, "reference", pending.Reference, "attempts", pending.Attempts, "error", err)
+		u.enqueue(pending)
+	}
+
+	u.savePending(context.WithoutCancel(ctx))
+}
+
+// normalizeReference brings cids to their canonical string, so that replicas reporting different multibases agree.
+func normalizeReference(reference string) string {
+	cid, err := ipfs.ParseCid(reference)
+	if err != nil {
+		return reference
+	}
+
+	return cid.String()
+}
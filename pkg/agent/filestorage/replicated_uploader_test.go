@@ -0,0 +1,289 @@
+package filestorage_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+)
+
+type mockUploader struct {
+	mu      sync.Mutex
+	uploads int
+	upload  func(data []byte) (string, error)
+}
+
+func (m *mockUploader) UploadFile(ctx context.Context, fileName string, data []byte) (string, error) {
+	m.mu.Lock()
+	m.uploads++
+	m.mu.Unlock()
+
+	return m.upload(data)
+}
+
+func (m *mockUploader) UploadJson(ctx context.Context, json interface{}) (string, error) {
+	panic("replicas only receive files")
+}
+
+func (m *mockUploader) Uploads() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.uploads
+}
+
+func cidUploader() *mockUploader {
+	return &mockUploader{upload: func(data []byte) (string, error) {
+		return ipfs.CidV1File(data).String(), nil
+	}}
+}
+
+func failingUploader() *mockUploader {
+	return &mockUploader{upload: func(data []byte) (string, error) {
+		return "", assert.AnError
+	}}
+}
+
+func TestNewReplicatedUploader(t *testing.T) {
+	_, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{})
+	assert.Error(t, err)
+
+	_, err = filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders: map[string]filestorage.Uploader{"a": cidUploader()},
+		Quorum:    2,
+	})
+	assert.Error(t, err)
+
+	uploader, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders: map[string]filestorage.Uploader{"a": cidUploader(), "b": cidUploader(), "c": cidUploader()},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, uploader.Quorum())
+}
+
+func TestReplicatedUploader_Quorum(t *testing.T) {
+	data := []byte("image")
+	flaky := failingUploader()
+
+	uploader, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders:     map[string]filestorage.Uploader{"a": cidUploader(), "b": cidUploader(), "flaky": flaky},
+		Quorum:        2,
+		RetryInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	reference, err := uploader.UploadFile(context.Background(), "image.png", data)
+	require.NoError(t, err)
+	assert.Equal(t, ipfs.CidV1File(data).String(), reference)
+	require.Eventually(t, func() bool { return uploader.Pending() == 1 }, time.Second, time.Millisecond)
+
+	// the failed replica is retried until the backend recovers
+	time.Sleep(2 * time.Millisecond)
+	uploader.RetryPending(context.Background())
+	assert.Equal(t, 1, uploader.Pending())
+	assert.Equal(t, 2, flaky.Uploads())
+
+	flaky.upload = cidUploader().upload
+	time.Sleep(5 * time.Millisecond)
+	uploader.RetryPending(context.Background())
+	assert.Equal(t, 0, uploader.Pending())
+	assert.Equal(t, 3, flaky.Uploads())
+}
+
+func TestReplicatedUploader_QuorumNotReached(t *testing.T) {
+	disagreeing := &mockUploader{upload: func(data []byte) (string, error) {
+		return "bzz://" + ipfs.CidV1Raw(data).String(), nil
+	}}
+
+	for name, uploaders := range map[string]map[string]filestorage.Uploader{
+		"failures":     {"a": cidUploader(), "b": failingUploader(), "c": failingUploader()},
+		"disagreement": {"a": cidUploader(), "b": disagreeing},
+	} {
+		t.Run(name, func(t *testing.T) {
+			uploader, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+				Uploaders: uploaders,
+				Quorum:    2,
+			})
+			require.NoError(t, err)
+
+			_, err = uploader.UploadFile(context.Background(), "image.png", []byte("image"))
+			assert.ErrorIs(t, err, filestorage.ErrQuorumNotReached)
+			assert.Equal(t, 0, uploader.Pending())
+		})
+	}
+}
+
+func TestReplicatedUploader_UploadJson(t *testing.T) {
+	a, b := cidUploader(), cidUploader()
+
+	uploader, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders: map[string]filestorage.Uploader{"a": a, "b": b},
+		Quorum:    2,
+	})
+	require.NoError(t, err)
+
+	reference, err := uploader.UploadJson(context.Background(), map[string]string{"name": "test"})
+	require.NoError(t, err)
+	assert.Equal(t, ipfs.CidV1Raw([]byte(`{"name":"test"}`)).String(), reference)
+	assert.Equal(t, 1, a.Uploads())
+	assert.Equal(t, 1, b.Uploads())
+}
+
+func TestReplicatedUploader_Mirrors(t *testing.T) {
+	data := []byte("image")
+	mirror := &mockUploader{upload: func(data []byte) (string, error) {
+		return "https://cdn.example.com/image", nil
+	}}
+	flakyMirror := failingUploader()
+
+	uploader, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders:     map[string]filestorage.Uploader{"a": cidUploader()},
+		Mirrors:       map[string]filestorage.Uploader{"mirror": mirror, "flaky": flakyMirror},
+		RetryInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, uploader.Quorum())
+	assert.Equal(t, []string{"flaky", "mirror"}, uploader.Mirrors())
+
+	reference, err := uploader.UploadFile(context.Background(), "image.png", data)
+	require.NoError(t, err)
+	assert.Equal(t, ipfs.CidV1File(data).String(), reference)
+	require.Eventually(t, func() bool { return mirror.Uploads() == 1 && uploader.Pending() == 1 }, time.Second, time.Millisecond)
+
+	_, err = filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders: map[string]filestorage.Uploader{"a": cidUploader()},
+		Mirrors:   map[string]filestorage.Uploader{"a": mirror},
+	})
+	assert.Error(t, err)
+}
+
+func TestReplicatedUploader_PinataAndKubo(t *testing.T) {
+	data := make([]byte, ipfs.MaxBlockSize+1)
+
+	pinata := filestorage.NewPinataUploader(filestorage.PinataUploaderConfig{
+		HttpClient: newTestPinata(t, func(data []byte, options string) string {
+			require.Equal(t, `{"cidVersion":1}`, options)
+			return ipfs.CidV1File(data).String()
+		}),
+		JwtKey: "jwt",
+		CidV1:  true,
+	})
+	kubo, err := filestorage.NewKuboUploader(filestorage.KuboUploaderConfig{
+		ApiUrl: newTestKubo(t, func(data []byte) string { return ipfs.CidV1File(data).String() }).URL,
+	})
+	require.NoError(t, err)
+
+	uploader, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders: map[string]filestorage.Uploader{filestorage.BackendPinata: pinata, filestorage.BackendKubo: kubo},
+		Quorum:    2,
+	})
+	require.NoError(t, err)
+
+	reference, err := uploader.UploadFile(context.Background(), "image.png", data)
+	require.NoError(t, err)
+	assert.Equal(t, ipfs.CidV1File(data).String(), reference)
+}
+
+type memoryPendingStore struct {
+	mu      sync.Mutex
+	pending []*filestorage.PendingReplica
+	saves   int
+}
+
+func (s *memoryPendingStore) Load(ctx context.Context) ([]*filestorage.PendingReplica, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pending, nil
+}
+
+func (s *memoryPendingStore) Save(ctx context.Context, pending []*filestorage.PendingReplica) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = pending
+	s.saves++
+	return nil
+}
+
+func (s *memoryPendingStore) Saves() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.saves
+}
+
+func (s *memoryPendingStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.pending)
+}
+
+func TestReplicatedUploader_PendingSurvivesRestart(t *testing.T) {
+	data := []byte("image")
+	store := &memoryPendingStore{}
+
+	uploader, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders:     map[string]filestorage.Uploader{"a": cidUploader(), "b": cidUploader(), "flaky": failingUploader()},
+		Quorum:        2,
+		RetryInterval: time.Millisecond,
+		PendingStore:  store,
+	})
+	require.NoError(t, err)
+
+	_, err = uploader.UploadFile(context.Background(), "image.png", data)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return store.Len() == 1 }, time.Second, time.Millisecond)
+
+	// a restarted agent resumes the replica once its backend recovers
+	recovered := cidUploader()
+	restarted, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders:     map[string]filestorage.Uploader{"a": cidUploader(), "b": cidUploader(), "flaky": recovered},
+		Quorum:        2,
+		RetryInterval: time.Millisecond,
+		PendingStore:  store,
+	})
+	require.NoError(t, err)
+	require.NoError(t, restarted.LoadPending(context.Background()))
+	assert.Equal(t, 1, restarted.Pending())
+
+	time.Sleep(2 * time.Millisecond)
+	restarted.RetryPending(context.Background())
+	assert.Equal(t, 0, restarted.Pending())
+	assert.Equal(t, 1, recovered.Uploads())
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestReplicatedUploader_PendingBoundedByBytes(t *testing.T) {
+	store := &memoryPendingStore{}
+
+	uploader, err := filestorage.NewReplicatedUploader(filestorage.ReplicatedUploaderConfig{
+		Uploaders:       map[string]filestorage.Uploader{"a": cidUploader(), "flaky": failingUploader()},
+		Quorum:          1,
+		RetryInterval:   time.Hour,
+		PendingStore:    store,
+		MaxPendingBytes: 10,
+	})
+	require.NoError(t, err)
+
+	// the oldest replicas are dropped once the pending data exceeds the bound
+	for i, expected := range []int{1, 2, 2} {
+		_, err := uploader.UploadFile(context.Background(), "image.png", []byte(fmt.Sprintf("img%d", i)))
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return store.Saves() == i+1 }, time.Second, time.Millisecond)
+		assert.Equal(t, expected, uploader.Pending())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, []byte("img1"), store.pending[0].Data)
+	assert.Equal(t, []byte("img2"), store.pending[1].Data)
+}
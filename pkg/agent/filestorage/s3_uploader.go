@@ -0,0 +1,262 @@
+package filestorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+
+	s3SseAes256 = "AES256"
+	s3SseKms    = "aws:kms"
+
+	awsSigningAlgorithm = "AWS4-HMAC-SHA256"
+	awsDateFormat       = "20060102T150405Z"
+)
+
+type S3Clock interface {
+	Now() time.Time
+}
+
+type defaultS3Clock struct{}
+
+func (defaultS3Clock) Now() time.Time {
+	return time.Now()
+}
+
+type S3UploaderConfig struct {
+	HttpClient *http.Client
+	// Endpoint is the S3 API endpoint, e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000 for MinIO.
+	// Buckets are addressed by path.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	// KeyPrefix is prepended to the content hash object keys
+	KeyPrefix string
+	// PublicBaseUrl serves the bucket's objects, the endpoint is used if empty
+	PublicBaseUrl string
+	// ServerSideEncryption is empty, AES256 or aws:kms
+	ServerSideEncryption string
+	// KmsKeyId selects the key for aws:kms encryption, the bucket default is used if empty
+	KmsKeyId string
+	// Clock dates the request signatures, the system clock is used if nil
+	Clock S3Clock
+}
+
+// S3Uploader stores objects in an S3-compatible bucket under the hex encoded SHA-256 of their content, and returns their
+// public url. Uploading the same content twice overwrites the object with identical bytes.
+type S3Uploader struct {
+	httpClient           *http.Client
+	endpoint             string
+	region               string
+	bucket               string
+	accessKeyId          string
+	secretAccessKey      string
+	keyPrefix            string
+	publicBaseUrl        string
+	serverSideEncryption string
+	kmsKeyId             string
+	clock                S3Clock
+}
+
+var _ Uploader = (*S3Uploader)(nil)
+
+func NewS3Uploader(config S3UploaderConfig) (*S3Uploader, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("s3 endpoint is required")
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+	if config.AccessKeyId == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("s3 credentials are required")
+	}
+	switch config.ServerSideEncryption {
+	case "", s3SseAes256, s3SseKms:
+	default:
+		return nil, fmt.Errorf("unsupported server side encryption %q", config.ServerSideEncryption)
+	}
+	if config.KmsKeyId != "" && config.ServerSideEncryption != s3SseKms {
+		return nil, errors.New("kms key id requires aws:kms server side encryption")
+	}
+
+	httpClient := config.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	region := config.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	clock := config.Clock
+	if clock == nil {
+		clock = defaultS3Clock{}
+	}
+
+	endpoint := strings.TrimSuffix(config.Endpoint, "/")
+	publicBaseUrl := strings.TrimSuffix(config.PublicBaseUrl, "/")
+	if publicBaseUrl == "" {
+		publicBaseUrl = endpoint + "/" + config.Bucket
+	}
+
+	return &S3Uploader{
+		httpClient:           httpClient,
+		endpoint:             endpoint,
+		region:               region,
+		bucket:               config.Bucket,
+		accessKeyId:          config.AccessKeyId,
+		secretAccessKey:      config.SecretAccessKey,
+		keyPrefix:            config.KeyPrefix,
+		publicBaseUrl:        publicBaseUrl,
+		serverSideEncryption: config.ServerSideEncryption,
+		kmsKeyId:             config.KmsKeyId,
+		clock:                clock,
+	}, nil
+}
+
+func (u *S3Uploader) UploadFile(ctx context.Context, fileName string, data []byte) (string, error) {
+	return u.upload(ctx, http.DetectContentType(data), data)
+}
+
+func (u *S3Uploader) UploadJson(ctx context.Context, value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal json: %v", err)
+	}
+
+	return u.upload(ctx, "application/json", data)
+}
+
+func (u *S3Uploader) upload(ctx context.Context, contentType string, data []byte) (string, error) {
+	payloadHash := sha256.Sum256(data)
+	key := u.keyPrefix + hex.EncodeToString(payloadHash[:])
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.endpoint+"/"+u.bucket+"/"+awsUriEncode(key, false), bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if u.serverSideEncryption != "" {
+		req.Header.Set("X-Amz-Server-Side-Encryption", u.serverSideEncryption)
+	}
+	if u.kmsKeyId != "" {
+		req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", u.kmsKeyId)
+	}
+	signAwsV4(req, hex.EncodeToString(payloadHash[:]), u.accessKeyId, u.secretAccessKey, u.region, u.clock.Now())
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file to s3: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// S3-compatible stores answer a PUT with 200, some with 201 or 204
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to upload file to s3: unexpected status code %d", resp.StatusCode)
+	}
+
+	return u.publicBaseUrl + "/" + awsUriEncode(key, false), nil
+}
+
+// signAwsV4 signs a request to S3 with AWS Signature Version 4, covering the host and every header already set.
+func signAwsV4(req *http.Request, payloadHash string, accessKeyId string, secretAccessKey string, region string, now time.Time) {
+	amzDate := now.UTC().Format(awsDateFormat)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsUriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		awsSigningAlgorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+secretAccessKey), date)
+	signingKey = hmacSha256(signingKey, region)
+	signingKey = hmacSha256(signingKey, "s3")
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", awsSigningAlgorithm, accessKeyId, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, awsUriEncode(name, true)+"="+awsUriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// awsUriEncode percent-encodes everything but unreserved characters, and slashes unless encodeSlash is set.
+func awsUriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return encoded.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
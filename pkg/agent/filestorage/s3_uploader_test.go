@@ -0,0 +1,167 @@
+package filestorage_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
+)
+
+type s3Put struct {
+	path          string
+	contentType   string
+	contentSha256 string
+	authorization string
+	sse           string
+	body          []byte
+}
+
+func newTestS3(t *testing.T, puts *[]s3Put) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		*puts = append(*puts, s3Put{
+			path:          r.URL.Path,
+			contentType:   r.Header.Get("Content-Type"),
+			contentSha256: r.Header.Get("X-Amz-Content-Sha256"),
+			authorization: r.Header.Get("Authorization"),
+			sse:           r.Header.Get("X-Amz-Server-Side-Encryption"),
+			body:          body,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newTestS3Config(endpoint string) filestorage.S3UploaderConfig {
+	return filestorage.S3UploaderConfig{
+		Endpoint:        endpoint,
+		Bucket:          "nfts",
+		AccessKeyId:     "minioadmin",
+		SecretAccessKey: "minioadmin",
+	}
+}
+
+func TestNewS3Uploader(t *testing.T) {
+	_, err := filestorage.NewS3Uploader(filestorage.S3UploaderConfig{Bucket: "nfts", AccessKeyId: "a", SecretAccessKey: "b"})
+	assert.Error(t, err)
+
+	config := newTestS3Config("http://localhost:9000")
+	config.ServerSideEncryption = "rot13"
+	_, err = filestorage.NewS3Uploader(config)
+	assert.Error(t, err)
+
+	config = newTestS3Config("http://localhost:9000")
+	config.KmsKeyId = "key"
+	_, err = filestorage.NewS3Uploader(config)
+	assert.Error(t, err)
+
+	_, err = filestorage.NewS3Uploader(newTestS3Config("http://localhost:9000"))
+	assert.NoError(t, err)
+}
+
+func TestS3Uploader_UploadFile(t *testing.T) {
+	var puts []s3Put
+	server := newTestS3(t, &puts)
+
+	config := newTestS3Config(server.URL)
+	config.KeyPrefix = "assets/"
+	config.PublicBaseUrl = "https://cdn.example.com/"
+	config.ServerSideEncryption = "AES256"
+	uploader, err := filestorage.NewS3Uploader(config)
+	require.NoError(t, err)
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	hash := sha256.Sum256(data)
+	key := "assets/" + hex.EncodeToString(hash[:])
+
+	reference, err := uploader.UploadFile(context.Background(), "image.png", data)
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/"+key, reference)
+	assert.Equal(t, reference, filestorage.Uri(reference))
+
+	require.Len(t, puts, 1)
+	assert.Equal(t, "/nfts/"+key, puts[0].path)
+	assert.Equal(t, "image/png", puts[0].contentType)
+	assert.Equal(t, hex.EncodeToString(hash[:]), puts[0].contentSha256)
+	assert.Equal(t, "AES256", puts[0].sse)
+	assert.Equal(t, data, puts[0].body)
+
+	assert.True(t, strings.HasPrefix(puts[0].authorization, "AWS4-HMAC-SHA256 Credential=minioadmin/"))
+	assert.Contains(t, puts[0].authorization, "/us-east-1/s3/aws4_request")
+	assert.Contains(t, puts[0].authorization, "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-server-side-encryption,")
+}
+
+func TestS3Uploader_UploadJson(t *testing.T) {
+	var puts []s3Put
+	server := newTestS3(t, &puts)
+
+	uploader, err := filestorage.NewS3Uploader(newTestS3Config(server.URL))
+	require.NoError(t, err)
+
+	reference, err := uploader.UploadJson(context.Background(), map[string]string{"name": "test"})
+	require.NoError(t, err)
+
+	hash := sha256.Sum256([]byte(`{"name":"test"}`))
+	assert.Equal(t, server.URL+"/nfts/"+hex.EncodeToString(hash[:]), reference)
+
+	require.Len(t, puts, 1)
+	assert.Equal(t, "application/json", puts[0].contentType)
+	assert.Empty(t, puts[0].sse)
+}
+
+func TestS3Uploader_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	uploader, err := filestorage.NewS3Uploader(newTestS3Config(server.URL))
+	require.NoError(t, err)
+
+	_, err = uploader.UploadFile(context.Background(), "image.png", []byte("data"))
+	assert.Error(t, err)
+}
+
+type fixedS3Clock struct {
+	now time.Time
+}
+
+func (c fixedS3Clock) Now() time.Time {
+	return c.now
+}
+
+func TestS3Uploader_ClockAndStatus(t *testing.T) {
+	var amzDate string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		amzDate = r.Header.Get("X-Amz-Date")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	config := newTestS3Config(server.URL)
+	config.Clock = fixedS3Clock{now: time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC)}
+	uploader, err := filestorage.NewS3Uploader(config)
+	require.NoError(t, err)
+
+	_, err = uploader.UploadFile(context.Background(), "image.png", []byte("data"))
+	require.NoError(t, err)
+	assert.Equal(t, "20130524T000000Z", amzDate)
+}
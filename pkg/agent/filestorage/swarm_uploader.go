@@ -0,0 +1,122 @@
+package filestorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	swarmSchemeBzz = "bzz://"
+
+	swarmPostageBatchIdHeader = "Swarm-Postage-Batch-Id"
+	swarmPinHeader            = "Swarm-Pin"
+
+	// swarmReferenceSize is the size of a plain reference, encrypted references are twice as long
+	swarmReferenceSize = 32
+	swarmMaxResponse   = 4096
+)
+
+type SwarmUploaderConfig struct {
+	HttpClient *http.Client
+	// BeeApiUrl is the API endpoint of the Bee node, e.g. http://localhost:1633
+	BeeApiUrl string
+	// PostageBatchId is the hex encoded postage stamp batch paying for the uploads
+	PostageBatchId string
+	// Pin keeps the uploads on the Bee node in addition to the network
+	Pin bool
+}
+
+// SwarmUploader uploads to Swarm through a Bee node. Uploads are wrapped in a manifest so that they are served with
+// their content type, and are returned as bzz:// uris.
+type SwarmUploader struct {
+	httpClient     *http.Client
+	beeApiUrl      string
+	postageBatchId string
+	pin            bool
+}
+
+var _ Uploader = (*SwarmUploader)(nil)
+
+type swarmUploadResponse struct {
+	Reference string `json:"reference"`
+}
+
+func NewSwarmUploader(config SwarmUploaderConfig) (*SwarmUploader, error) {
+	if config.BeeApiUrl == "" {
+		return nil, errors.New("bee api url is required")
+	}
+
+	postageBatchId, err := hex.DecodeString(config.PostageBatchId)
+	if err != nil || len(postageBatchId) != swarmReferenceSize {
+		return nil, errors.New("postage batch id must be 32 hex encoded bytes")
+	}
+
+	httpClient := config.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &SwarmUploader{
+		httpClient:     httpClient,
+		beeApiUrl:      strings.TrimSuffix(config.BeeApiUrl, "/"),
+		postageBatchId: config.PostageBatchId,
+		pin:            config.Pin,
+	}, nil
+}
+
+func (u *SwarmUploader) UploadFile(ctx context.Context, fileName string, data []byte) (string, error) {
+	return u.upload(ctx, fileName, http.DetectContentType(data), data)
+}
+
+func (u *SwarmUploader) UploadJson(ctx context.Context, value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal json: %v", err)
+	}
+
+	return u.upload(ctx, "metadata.json", "application/json", data)
+}
+
+func (u *SwarmUploader) upload(ctx context.Context, fileName string, contentType string, data []byte) (string, error) {
+	endpoint := u.beeApiUrl + "/bzz?" + url.Values{"name": {fileName}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(swarmPostageBatchIdHeader, u.postageBatchId)
+	if u.pin {
+		req.Header.Set(swarmPinHeader, "true")
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file to swarm: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to upload file to swarm: unexpected status code %d", resp.StatusCode)
+	}
+
+	var uploadResponse swarmUploadResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, swarmMaxResponse)).Decode(&uploadResponse); err != nil {
+		return "", fmt.Errorf("failed to decode bee response: %v", err)
+	}
+
+	reference, err := hex.DecodeString(uploadResponse.Reference)
+	if err != nil || (len(reference) != swarmReferenceSize && len(reference) != 2*swarmReferenceSize) {
+		return "", fmt.Errorf("invalid swarm reference %q", uploadResponse.Reference)
+	}
+
+	return swarmSchemeBzz + uploadResponse.Reference, nil
+}
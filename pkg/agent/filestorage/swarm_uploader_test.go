@@ -0,0 +1,134 @@
+package filestorage_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
+)
+
+var (
+	testPostageBatchId = strings.Repeat("ab", 32)
+	testReference      = strings.Repeat("cd", 32)
+)
+
+type beeUpload struct {
+	name        string
+	contentType string
+	batchId     string
+	pin         string
+	body        []byte
+}
+
+func newTestBee(t *testing.T, uploads *[]beeUpload) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/bzz" {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		*uploads = append(*uploads, beeUpload{
+			name:        r.URL.Query().Get("name"),
+			contentType: r.Header.Get("Content-Type"),
+			batchId:     r.Header.Get("Swarm-Postage-Batch-Id"),
+			pin:         r.Header.Get("Swarm-Pin"),
+			body:        body,
+		})
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"reference": testReference})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestNewSwarmUploader(t *testing.T) {
+	_, err := filestorage.NewSwarmUploader(filestorage.SwarmUploaderConfig{PostageBatchId: testPostageBatchId})
+	assert.Error(t, err)
+
+	_, err = filestorage.NewSwarmUploader(filestorage.SwarmUploaderConfig{BeeApiUrl: "http://localhost:1633", PostageBatchId: "abcd"})
+	assert.Error(t, err)
+}
+
+func TestSwarmUploader_UploadFile(t *testing.T) {
+	var uploads []beeUpload
+	bee := newTestBee(t, &uploads)
+
+	uploader, err := filestorage.NewSwarmUploader(filestorage.SwarmUploaderConfig{
+		BeeApiUrl:      bee.URL + "/",
+		PostageBatchId: testPostageBatchId,
+		Pin:            true,
+	})
+	require.NoError(t, err)
+
+	image := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 16)...)
+	reference, err := uploader.UploadFile(context.Background(), "image.png", image)
+	require.NoError(t, err)
+	assert.Equal(t, "bzz://"+testReference, reference)
+	assert.Equal(t, reference, filestorage.Uri(reference))
+
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "image.png", uploads[0].name)
+	assert.Equal(t, "image/png", uploads[0].contentType)
+	assert.Equal(t, testPostageBatchId, uploads[0].batchId)
+	assert.Equal(t, "true", uploads[0].pin)
+	assert.Equal(t, image, uploads[0].body)
+}
+
+func TestSwarmUploader_UploadJson(t *testing.T) {
+	var uploads []beeUpload
+	bee := newTestBee(t, &uploads)
+
+	uploader, err := filestorage.NewSwarmUploader(filestorage.SwarmUploaderConfig{
+		BeeApiUrl:      bee.URL,
+		PostageBatchId: testPostageBatchId,
+	})
+	require.NoError(t, err)
+
+	reference, err := uploader.UploadJson(context.Background(), map[string]string{"name": "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "bzz://"+testReference, reference)
+
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "application/json", uploads[0].contentType)
+	assert.Empty(t, uploads[0].pin)
+	assert.JSONEq(t, `{"name":"test"}`, string(uploads[0].body))
+}
+
+func TestSwarmUploader_Errors(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"status": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusPaymentRequired)
+		},
+		"reference": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"reference":"not-a-reference"}`))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bee := httptest.NewServer(handler)
+			defer bee.Close()
+
+			uploader, err := filestorage.NewSwarmUploader(filestorage.SwarmUploaderConfig{
+				BeeApiUrl:      bee.URL,
+				PostageBatchId: testPostageBatchId,
+			})
+			require.NoError(t, err)
+
+			_, err = uploader.UploadFile(context.Background(), "image.png", []byte("data"))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,8 +1,33 @@
 package filestorage
 
-import "context"
+import (
+	"context"
+	"strings"
+)
 
+const (
+	BackendPinata = "pinata"
+	BackendSwarm  = "swarm"
+	BackendKubo   = "kubo"
+	BackendS3     = "s3"
+)
+
+// Uploader stores files and returns their reference: a bare IPFS CID, or a uri for backends outside of IPFS.
 type Uploader interface {
-	UploadUrl(ctx context.Context, fileUrl string) (string, error)
+	UploadFile(ctx context.Context, fileName string, data []byte) (string, error)
 	UploadJson(ctx context.Context, json interface{}) (string, error)
 }
+
+// Runner is implemented by uploaders with background work, which the agent runs for its lifetime.
+type Runner interface {
+	Run(ctx context.Context)
+}
+
+// Uri returns the uri of a reference returned by an Uploader.
+func Uri(reference string) string {
+	if strings.Contains(reference, "://") {
+		return reference
+	}
+
+	return "ipfs://" + reference
+}
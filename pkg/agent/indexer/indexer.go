@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/alitto/pond/v2"
@@ -29,6 +30,7 @@ type CollectionInfo struct {
 	MetadataInitialized      bool
 
 	CreationTimestamp uint64
+	CreationBlock     uint64
 	CollectionAddress common.Address
 	AuctionDuration   uint64
 	NextAuctionId     uint64
@@ -61,14 +63,21 @@ type IndexerConfig struct {
 	EventPollingInterval   time.Duration
 	AuctionPollingInterval time.Duration
 	Clock                  IndexerClock
+	StateStore             StateStore
+	ConfirmationDepth      uint64
+	SubscribeLogs          bool
 }
 
 type Indexer struct {
 	group                    singleflight.Group
 	initializeCollectionPool pond.Pool
 
+	mu    sync.Mutex
 	cache map[common.Address]*CollectionInfo
 
+	stateStore StateStore
+	saveMu     sync.Mutex
+
 	factoryAbi    *abi.ABI
 	collectionAbi *abi.ABI
 
@@ -78,6 +87,10 @@ type Indexer struct {
 	provider IndexerEthClient
 
 	lastIndexedBlock       uint64
+	recentBlocks           map[uint64]common.Hash
+	recentFinished         []FinishedAuction
+	confirmationDepth      uint64
+	subscribeLogs          bool
 	eventPollingInterval   time.Duration
 	auctionPollingInterval time.Duration
 	clock                  IndexerClock
@@ -107,14 +120,19 @@ func NewIndexer(opts IndexerConfig) (*Indexer, error) {
 
 		cache: make(map[common.Address]*CollectionInfo),
 
+		stateStore: opts.StateStore,
+
 		factoryAbi:    factoryAbi,
 		collectionAbi: collectionAbi,
 
 		factoryAddress: opts.FactoryAddress,
 		factory:        factory,
 
-		lastIndexedBlock: 0,
-		provider:         opts.EthClient,
+		lastIndexedBlock:  0,
+		recentBlocks:      make(map[uint64]common.Hash),
+		confirmationDepth: opts.ConfirmationDepth,
+		subscribeLogs:     opts.SubscribeLogs,
+		provider:          opts.EthClient,
 
 		eventPollingInterval:   opts.EventPollingInterval,
 		auctionPollingInterval: opts.AuctionPollingInterval,
@@ -127,8 +145,14 @@ func NewIndexer(opts IndexerConfig) (*Indexer, error) {
 
 func (i *Indexer) Start(ctx context.Context, auctionEndChan chan<- AuctionEnd) {
 	slog.Info("starting indexer")
+
+	if err := i.loadState(ctx); err != nil {
+		slog.Warn("failed to load indexer state, indexing from scratch", "error", err)
+	}
+
 	i.indexEvents(ctx)
 
+	go i.reconcileAuctions(ctx, auctionEndChan)
 	go i.indexEventsTask(ctx)
 	go i.monitorAuctionsTask(ctx, auctionEndChan)
 	slog.Info("indexer tasks started")
@@ -143,20 +167,12 @@ func (i *Indexer) monitorAuctionsTask(ctx context.Context, auctionEndChan chan<-
 		select {
 		case <-ticker.C:
 			now := uint64(i.clock.Now().Unix())
+			advanced := false
 
-			for addr, info := range i.cache {
-				slog.Info("monitoring auction", "collection", addr, "info", info, "now", now)
-
-				if !info.Initialized() {
-					continue
-				}
-
-				auctionEnd := info.CreationTimestamp + (info.NextAuctionId * info.AuctionDuration)
-				for ; auctionEnd <= now; auctionEnd += info.AuctionDuration {
-					currentAuctionId := info.NextAuctionId - 1
+			for addr, info := range i.collections() {
+				for _, currentAuctionId := range i.advanceEndedAuctions(info, now) {
 					slog.Info("auction ended", "collection", addr, "auctionId", currentAuctionId)
-
-					info.NextAuctionId++
+					advanced = true
 
 					go func() {
 						collection, err := contractYayoiCollection.NewContractYayoiCollection(addr, i.provider)
@@ -189,6 +205,10 @@ func (i *Indexer) monitorAuctionsTask(ctx context.Context, auctionEndChan chan<-
 					}()
 				}
 			}
+
+			if advanced {
+				i.saveState(ctx)
+			}
 		case <-ctx.Done():
 			slog.Info("auction monitor task stopping")
 			return
@@ -196,7 +216,32 @@ func (i *Indexer) monitorAuctionsTask(ctx context.Context, auctionEndChan chan<-
 	}
 }
 
+// advanceEndedAuctions moves a collection past the auctions that ended by now and returns their ids. The info is
+// shared with the event indexer and rollbacks, so it is read and advanced under the lock.
+func (i *Indexer) advanceEndedAuctions(info *CollectionInfo, now uint64) []uint64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	slog.Info("monitoring auction", "collection", info.CollectionAddress, "info", *info, "now", now)
+	if !info.Initialized() || info.AuctionDuration == 0 {
+		return nil
+	}
+
+	var ended []uint64
+	for info.CreationTimestamp+info.NextAuctionId*info.AuctionDuration <= now {
+		ended = append(ended, info.NextAuctionId-1)
+		info.NextAuctionId++
+	}
+
+	return ended
+}
+
 func (i *Indexer) indexEventsTask(ctx context.Context) {
+	if i.subscribeLogs {
+		i.subscribeEventsTask(ctx)
+		return
+	}
+
 	slog.Info("starting event indexing task")
 	ticker := time.NewTicker(i.eventPollingInterval)
 	defer ticker.Stop()
@@ -216,12 +261,35 @@ func (i *Indexer) indexEventsTask(ctx context.Context) {
 }
 
 func (i *Indexer) indexEvents(ctx context.Context) error {
-	targetBlock, err := i.provider.BlockNumber(ctx)
+	headBlock, err := i.provider.BlockNumber(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get current block: %v", err)
 	}
 
-	slog.Info("indexing events", "fromBlock", i.lastIndexedBlock, "toBlock", targetBlock)
+	if headBlock < i.confirmationDepth {
+		return nil
+	}
+	targetBlock := headBlock - i.confirmationDepth
+
+	if err := i.handleReorg(ctx); err != nil {
+		return fmt.Errorf("failed to handle reorg: %w", err)
+	}
+
+	// the last indexed block was already scanned, only the genesis block is scanned before anything is indexed
+	fromBlock := i.getLastIndexedBlock()
+	if fromBlock > 0 {
+		fromBlock++
+	}
+	if fromBlock > targetBlock {
+		return nil
+	}
+
+	hashes, err := i.blockHashes(ctx, fromBlock, targetBlock)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("indexing events", "fromBlock", fromBlock, "toBlock", targetBlock)
 
 	collectionCreatedId := i.factoryAbi.Events["CollectionCreated"].ID
 	promptAuctionFinishedId := i.collectionAbi.Events["PromptAuctionFinished"].ID
@@ -231,7 +299,6 @@ func (i *Indexer) indexEvents(ctx context.Context) error {
 
 	discoveredCollections := []common.Address{}
 
-	fromBlock := i.lastIndexedBlock
 	for fromBlock <= targetBlock {
 		toBlock := fromBlock + indexingLogChunkSize
 		if toBlock > targetBlock {
@@ -244,15 +311,18 @@ func (i *Indexer) indexEvents(ctx context.Context) error {
 		logs, err := i.provider.FilterLogs(ctx, ethereum.FilterQuery{
 			FromBlock: fromBlockBI,
 			ToBlock:   toBlockBI,
-			Topics: [][]common.Hash{{
-				collectionCreatedId,
-				promptAuctionFinishedId,
-			}},
+			Topics:    i.eventTopics(),
 		})
 		if err != nil {
 			return fmt.Errorf("failed to filter logs: %v", err)
 		}
 
+		for _, log := range logs {
+			if hash, ok := hashes[log.BlockNumber]; ok && hash != log.BlockHash {
+				return fmt.Errorf("%w: log from unknown block %s", ErrReorgWhileIndexing, log.BlockHash)
+			}
+		}
+
 		slog.Info("processing logs", "count", len(logs), "fromBlock", fromBlock, "toBlock", toBlock)
 
 		for _, log := range logs {
@@ -266,6 +336,11 @@ func (i *Indexer) indexEvents(ctx context.Context) error {
 
 				slog.Info("new collection created", "collection", event.Collection)
 				i.cacheCollectionKey(event.Collection)
+
+				info := i.getCollectionInfo(event.Collection)
+				i.mu.Lock()
+				info.CreationBlock = log.BlockNumber
+				i.mu.Unlock()
 				discoveredCollections = append(discoveredCollections, event.Collection)
 
 				i.initializeCollectionPool.Submit(func() {
@@ -289,25 +364,34 @@ func (i *Indexer) indexEvents(ctx context.Context) error {
 
 				slog.Info("prompt auction finished", "collection", log.Address, "auctionId", event.AuctionId)
 
+				i.recordFinishedAuction(log.Address, event.AuctionId.Uint64(), log.BlockNumber)
+
 				info := i.getCollectionInfo(log.Address)
+				i.mu.Lock()
 				if !info.NextAuctionIdInitialized {
 					info.NextAuctionId = event.AuctionId.Uint64() + 1
 				}
+				i.mu.Unlock()
 			}
 		}
 
 		fromBlock = toBlock + 1
 	}
 
+	i.mu.Lock()
 	for _, collection := range discoveredCollections {
-		info := i.getCollectionInfo(collection)
+		info := i.cache[collection]
 		info.NextAuctionIdInitialized = true
 		slog.Info("initialized next auction ID", "collection", collection)
 	}
 
 	i.lastIndexedBlock = targetBlock
+	i.mu.Unlock()
+	i.recordBlocks(hashes, targetBlock)
 	slog.Info("finished indexing events", "lastIndexedBlock", targetBlock)
 
+	i.saveState(ctx)
+
 	return nil
 }
 
@@ -330,10 +414,14 @@ func (i *Indexer) initializeCollection(ctx context.Context, collectionAddress co
 		return fmt.Errorf("failed to get auction duration: %v", err)
 	}
 
+	i.mu.Lock()
 	info.MetadataInitialized = true
 	info.CollectionAddress = collectionAddress
 	info.CreationTimestamp = creationTimestamp
 	info.AuctionDuration = auctionDuration
+	i.mu.Unlock()
+
+	i.saveState(ctx)
 
 	slog.Info("collection initialized",
 		"collection", collectionAddress,
@@ -345,6 +433,9 @@ func (i *Indexer) initializeCollection(ctx context.Context, collectionAddress co
 
 func (i *Indexer) getCollectionInfo(collectionAddress common.Address) *CollectionInfo {
 	info, _, _ := i.group.Do(collectionAddress.String(), func() (interface{}, error) {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
 		info, ok := i.cache[collectionAddress]
 		if !ok {
 			info = &CollectionInfo{}
@@ -363,10 +454,117 @@ func (i *Indexer) cacheCollectionKey(collectionAddress common.Address) {
 }
 
 func (i *Indexer) isCollectionKeyCached(collectionAddress common.Address) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	_, ok := i.cache[collectionAddress]
 	return ok
 }
 
+func (i *Indexer) collections() map[common.Address]*CollectionInfo {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	collections := make(map[common.Address]*CollectionInfo, len(i.cache))
+	for addr, info := range i.cache {
+		collections[addr] = info
+	}
+
+	return collections
+}
+
+// Collections returns a snapshot of every indexed collection.
+func (i *Indexer) Collections() []CollectionInfo {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	collections := make([]CollectionInfo, 0, len(i.cache))
+	for _, info := range i.cache {
+		collections = append(collections, *info)
+	}
+
+	return collections
+}
+
+func (i *Indexer) loadState(ctx context.Context) error {
+	if i.stateStore == nil {
+		return nil
+	}
+
+	state, err := i.stateStore.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		slog.Info("no indexer state found")
+		return nil
+	}
+	if state.FactoryAddress != i.factoryAddress {
+		return fmt.Errorf("indexer state belongs to factory %s", state.FactoryAddress)
+	}
+
+	i.mu.Lock()
+	i.lastIndexedBlock = state.LastIndexedBlock
+	for addr, info := range state.Collections {
+		i.cache[addr] = info
+	}
+	for number, hash := range state.RecentBlocks {
+		i.recentBlocks[number] = hash
+	}
+	i.recentFinished = append(i.recentFinished, state.RecentFinished...)
+	i.mu.Unlock()
+
+	for addr, info := range state.Collections {
+		if info.MetadataInitialized {
+			continue
+		}
+
+		i.initializeCollectionPool.Submit(func() {
+			if err := i.initializeCollection(ctx, addr); err != nil {
+				slog.Error("failed to initialize collection", "collection", addr.String(), "error", err)
+			}
+		})
+	}
+
+	slog.Info("loaded indexer state", "lastIndexedBlock", state.LastIndexedBlock, "collections", len(state.Collections))
+	return nil
+}
+
+func (i *Indexer) saveState(ctx context.Context) {
+	if i.stateStore == nil {
+		return
+	}
+
+	i.saveMu.Lock()
+	defer i.saveMu.Unlock()
+
+	if err := i.stateStore.Save(ctx, i.snapshotState()); err != nil {
+		slog.Error("failed to save indexer state", "error", err)
+	}
+}
+
+func (i *Indexer) snapshotState() *State {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	state := &State{
+		FactoryAddress:   i.factoryAddress,
+		LastIndexedBlock: i.lastIndexedBlock,
+		Collections:      make(map[common.Address]*CollectionInfo, len(i.cache)),
+		RecentBlocks:     make(map[uint64]common.Hash, len(i.recentBlocks)),
+		RecentFinished:   append([]FinishedAuction(nil), i.recentFinished...),
+	}
+	for addr, info := range i.cache {
+		infoCopy := *info
+		state.Collections[addr] = &infoCopy
+	}
+	for number, hash := range i.recentBlocks {
+		state.RecentBlocks[number] = hash
+	}
+
+	return state
+}
+
 func unpackLog(contractAbi *abi.ABI, out interface{}, event string, log types.Log) error {
 	if len(log.Data) > 0 {
 		if err := contractAbi.UnpackIntoInterface(out, event, log.Data); err != nil {
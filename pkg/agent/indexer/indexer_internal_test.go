@@ -0,0 +1,405 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	contractYayoiCollection "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiCollection"
+)
+
+var (
+	testFactoryAddress    = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testCollectionAddress = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	testOwnerAddress      = common.HexToAddress("0x3000000000000000000000000000000000000003")
+	testWinnerAddress     = common.HexToAddress("0x4000000000000000000000000000000000000004")
+)
+
+// fakeChain is an in-memory chain serving headers, logs and collection calls. A fork replaces the blocks from a
+// height on, together with their logs, so that every later block gets a new hash.
+type fakeChain struct {
+	mu sync.Mutex
+
+	headers []*types.Header
+	logs    map[uint64][]types.Log
+	forks   uint64
+
+	auctions         map[common.Address][]contractYayoiCollection.YayoiCollectionAuction
+	currentAuctionId map[common.Address]uint64
+
+	filterQueries []ethereum.FilterQuery
+	subscribeErr  error
+	subscriptions []*fakeSubscription
+}
+
+var _ IndexerEthClient = (*fakeChain)(nil)
+
+func newFakeChain(blocks int) *fakeChain {
+	c := &fakeChain{
+		logs:             make(map[uint64][]types.Log),
+		auctions:         make(map[common.Address][]contractYayoiCollection.YayoiCollectionAuction),
+		currentAuctionId: make(map[common.Address]uint64),
+	}
+	c.headers = append(c.headers, &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(0)})
+	c.mine(blocks)
+
+	return c
+}
+
+func (c *fakeChain) mine(blocks int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for n := 0; n < blocks; n++ {
+		parent := c.headers[len(c.headers)-1]
+		c.headers = append(c.headers, &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+			Difficulty: big.NewInt(0),
+			Extra:      new(big.Int).SetUint64(c.forks).Bytes(),
+		})
+	}
+}
+
+// fork drops every block from number on and mines as many new ones in their place.
+func (c *fakeChain) fork(number uint64) {
+	c.mu.Lock()
+	blocks := len(c.headers) - int(number)
+	c.headers = c.headers[:number]
+	for n := range c.logs {
+		if n >= number {
+			delete(c.logs, n)
+		}
+	}
+	c.forks++
+	c.mu.Unlock()
+
+	c.mine(blocks)
+}
+
+func (c *fakeChain) head() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return uint64(len(c.headers) - 1)
+}
+
+func (c *fakeChain) addLog(log types.Log) types.Log {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	log.BlockHash = c.headers[log.BlockNumber].Hash()
+	c.logs[log.BlockNumber] = append(c.logs[log.BlockNumber], log)
+
+	return log
+}
+
+func (c *fakeChain) queries() []ethereum.FilterQuery {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]ethereum.FilterQuery(nil), c.filterQueries...)
+}
+
+func (c *fakeChain) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.head(), nil
+}
+
+func (c *fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if number == nil {
+		return types.CopyHeader(c.headers[len(c.headers)-1]), nil
+	}
+	if number.Uint64() >= uint64(len(c.headers)) {
+		return nil, ethereum.NotFound
+	}
+
+	return types.CopyHeader(c.headers[number.Uint64()]), nil
+}
+
+func (c *fakeChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.filterQueries = append(c.filterQueries, q)
+
+	var logs []types.Log
+	for n := q.FromBlock.Uint64(); n <= q.ToBlock.Uint64() && n < uint64(len(c.headers)); n++ {
+		logs = append(logs, c.logs[n]...)
+	}
+
+	return logs, nil
+}
+
+func (c *fakeChain) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.subscribeErr != nil {
+		return nil, c.subscribeErr
+	}
+
+	sub := &fakeSubscription{logs: ch, err: make(chan error, 1)}
+	c.subscriptions = append(c.subscriptions, sub)
+
+	return sub, nil
+}
+
+func (c *fakeChain) subscription(n int) *fakeSubscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n >= len(c.subscriptions) {
+		return nil
+	}
+
+	return c.subscriptions[n]
+}
+
+func (c *fakeChain) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	collectionAbi, err := contractYayoiCollection.ContractYayoiCollectionMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := collectionAbi.MethodById(call.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch method.Name {
+	case "creationTimestamp":
+		return method.Outputs.Pack(uint64(1000))
+	case "auctionDuration":
+		return method.Outputs.Pack(uint64(100))
+	case "getCurrentAuctionId":
+		return method.Outputs.Pack(new(big.Int).SetUint64(c.currentAuctionId[*call.To]))
+	case "getAuction":
+		args, err := method.Inputs.Unpack(call.Data[4:])
+		if err != nil {
+			return nil, err
+		}
+		auctionId := args[0].(*big.Int).Uint64()
+
+		auction := contractYayoiCollection.YayoiCollectionAuction{HighestBid: big.NewInt(0)}
+		if auctions := c.auctions[*call.To]; auctionId < uint64(len(auctions)) {
+			auction = auctions[auctionId]
+		}
+		return method.Outputs.Pack(auction)
+	}
+
+	return nil, errors.New("unexpected call to " + method.Name)
+}
+
+func (c *fakeChain) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{0x1}, nil
+}
+
+func (c *fakeChain) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return []byte{0x1}, nil
+}
+
+func (c *fakeChain) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return 0, nil
+}
+
+func (c *fakeChain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(0), nil
+}
+
+func (c *fakeChain) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(0), nil
+}
+
+func (c *fakeChain) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 0, nil
+}
+
+func (c *fakeChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return errors.New("read-only chain")
+}
+
+type fakeSubscription struct {
+	logs chan<- types.Log
+	err  chan error
+	once sync.Once
+}
+
+var _ ethereum.Subscription = (*fakeSubscription)(nil)
+
+func (s *fakeSubscription) Err() <-chan error {
+	return s.err
+}
+
+func (s *fakeSubscription) Unsubscribe() {
+	s.once.Do(func() { close(s.err) })
+}
+
+func (s *fakeSubscription) drop() {
+	s.err <- errors.New("connection lost")
+}
+
+type memoryStateStore struct {
+	mu    sync.Mutex
+	state *State
+}
+
+func (m *memoryStateStore) Load(ctx context.Context) (*State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state, nil
+}
+
+func (m *memoryStateStore) Save(ctx context.Context, state *State) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.state = state
+	return nil
+}
+
+func (m *memoryStateStore) saved() *State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state
+}
+
+func newTestIndexer(t *testing.T, chain *fakeChain, store StateStore) *Indexer {
+	i, err := NewIndexer(IndexerConfig{
+		EthClient:              chain,
+		FactoryAddress:         testFactoryAddress,
+		EventPollingInterval:   10 * time.Millisecond,
+		AuctionPollingInterval: time.Hour,
+		Clock:                  testClock{},
+		StateStore:             store,
+	})
+	require.NoError(t, err)
+
+	return i
+}
+
+type testClock struct{}
+
+func (testClock) Now() time.Time {
+	return time.Now()
+}
+
+func collectionCreatedLog(i *Indexer, blockNumber uint64, collection common.Address) types.Log {
+	return types.Log{
+		Address: testFactoryAddress,
+		Topics: []common.Hash{
+			i.factoryAbi.Events["CollectionCreated"].ID,
+			common.BytesToHash(collection.Bytes()),
+			common.BytesToHash(testOwnerAddress.Bytes()),
+		},
+		BlockNumber: blockNumber,
+	}
+}
+
+func promptAuctionFinishedLog(t *testing.T, i *Indexer, blockNumber uint64, collection common.Address, auctionId uint64) types.Log {
+	event := i.collectionAbi.Events["PromptAuctionFinished"]
+	data, err := event.Inputs.NonIndexed().Pack("a watercolor garden")
+	require.NoError(t, err)
+
+	return types.Log{
+		Address: collection,
+		Topics: []common.Hash{
+			event.ID,
+			common.BigToHash(new(big.Int).SetUint64(auctionId)),
+			common.BytesToHash(testWinnerAddress.Bytes()),
+		},
+		Data:        data,
+		BlockNumber: blockNumber,
+	}
+}
+
+func TestFileStateStore_LoadMissing(t *testing.T) {
+	store := NewFileStateStore("", filepath.Join(t.TempDir(), "indexer_state"))
+
+	state, err := store.Load(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestIndexer_SavesState(t *testing.T) {
+	chain := newFakeChain(10)
+	store := &memoryStateStore{}
+	i := newTestIndexer(t, chain, store)
+	chain.addLog(collectionCreatedLog(i, 4, testCollectionAddress))
+
+	require.NoError(t, i.indexEvents(context.Background()))
+
+	require.Eventually(t, func() bool {
+		state := store.saved()
+		return state != nil && state.Collections[testCollectionAddress] != nil && state.Collections[testCollectionAddress].Initialized()
+	}, 2*time.Second, 5*time.Millisecond)
+
+	state := store.saved()
+	assert.Equal(t, testFactoryAddress, state.FactoryAddress)
+	assert.Equal(t, uint64(10), state.LastIndexedBlock)
+	assert.Equal(t, uint64(4), state.Collections[testCollectionAddress].CreationBlock)
+	assert.Equal(t, uint64(1000), state.Collections[testCollectionAddress].CreationTimestamp)
+	assert.Equal(t, uint64(100), state.Collections[testCollectionAddress].AuctionDuration)
+}
+
+func TestIndexer_ResumesFromSavedState(t *testing.T) {
+	chain := newFakeChain(30)
+	store := &memoryStateStore{}
+
+	previous := newTestIndexer(t, chain, store)
+	chain.addLog(collectionCreatedLog(previous, 4, testCollectionAddress))
+	require.NoError(t, previous.indexEvents(context.Background()))
+	require.Eventually(t, func() bool {
+		state := store.saved()
+		return state.Collections[testCollectionAddress] != nil && state.Collections[testCollectionAddress].Initialized()
+	}, 2*time.Second, 5*time.Millisecond)
+
+	chain.mine(5)
+	chain.mu.Lock()
+	chain.filterQueries = nil
+	chain.mu.Unlock()
+
+	i := newTestIndexer(t, chain, store)
+	require.NoError(t, i.loadState(context.Background()))
+	require.NoError(t, i.indexEvents(context.Background()))
+
+	queries := chain.queries()
+	require.Len(t, queries, 1)
+	assert.Equal(t, uint64(31), queries[0].FromBlock.Uint64(), "indexing resumes after the last indexed block")
+	assert.Equal(t, uint64(35), queries[0].ToBlock.Uint64())
+
+	collections := i.Collections()
+	require.Len(t, collections, 1)
+	assert.Equal(t, testCollectionAddress, collections[0].CollectionAddress)
+	assert.True(t, collections[0].Initialized())
+	assert.Equal(t, uint64(35), store.saved().LastIndexedBlock)
+}
+
+func TestIndexer_RejectsStateOfAnotherFactory(t *testing.T) {
+	chain := newFakeChain(10)
+	store := &memoryStateStore{state: &State{
+		FactoryAddress:   common.HexToAddress("0xdead"),
+		LastIndexedBlock: 5,
+	}}
+
+	i := newTestIndexer(t, chain, store)
+	assert.Error(t, i.loadState(context.Background()))
+	assert.Equal(t, uint64(0), i.getLastIndexedBlock())
+}
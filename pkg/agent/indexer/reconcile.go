@@ -0,0 +1,84 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	contractYayoiCollection "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiCollection"
+)
+
+const (
+	reconcileAuctionWindow = 100
+)
+
+// reconcileAuctions queues past auctions that have a winner but were never finished, e.g. because they ended while
+// the agent was down. Auctions that are also reported by the auction monitor are deduplicated by the job queue, which
+// requeues the jobs that went dead before the auction was finished.
+func (i *Indexer) reconcileAuctions(ctx context.Context, auctionEndChan chan<- AuctionEnd) {
+	slog.Info("reconciling past auctions")
+
+	found := 0
+	for addr := range i.collections() {
+		auctionEnds, err := i.unfinishedAuctions(ctx, addr)
+		if err != nil {
+			slog.Error("failed to reconcile collection auctions", "collection", addr, "error", err)
+			continue
+		}
+
+		for _, auctionEnd := range auctionEnds {
+			select {
+			case auctionEndChan <- auctionEnd:
+				found++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	slog.Info("finished reconciling past auctions", "unfinished", found)
+}
+
+func (i *Indexer) unfinishedAuctions(ctx context.Context, collectionAddress common.Address) ([]AuctionEnd, error) {
+	collection, err := contractYayoiCollection.NewContractYayoiCollection(collectionAddress, i.provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %v", err)
+	}
+
+	currentAuctionId, err := collection.GetCurrentAuctionId(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current auction id: %v", err)
+	}
+
+	current := currentAuctionId.Uint64()
+	first := uint64(0)
+	if current > reconcileAuctionWindow {
+		first = current - reconcileAuctionWindow
+	}
+
+	var auctionEnds []AuctionEnd
+	for auctionId := first; auctionId < current; auctionId++ {
+		auction, err := collection.GetAuction(&bind.CallOpts{Context: ctx}, new(big.Int).SetUint64(auctionId))
+		if err != nil {
+			return auctionEnds, fmt.Errorf("failed to get auction %d: %v", auctionId, err)
+		}
+
+		if auction.Finished || auction.HighestBidder == (common.Address{}) {
+			continue
+		}
+
+		slog.Info("found unfinished auction", "collection", collectionAddress, "auctionId", auctionId, "winner", auction.HighestBidder)
+		auctionEnds = append(auctionEnds, AuctionEnd{
+			CollectionAddress: collectionAddress,
+			AuctionId:         auctionId,
+			Prompt:            auction.Prompt,
+			Winner:            auction.HighestBidder,
+		})
+	}
+
+	return auctionEnds, nil
+}
@@ -0,0 +1,101 @@
+package indexer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	contractYayoiCollection "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiCollection"
+)
+
+func TestIndexer_ReconcilesUnfinishedAuctions(t *testing.T) {
+	chain := newFakeChain(10)
+	chain.currentAuctionId[testCollectionAddress] = 3
+	chain.auctions[testCollectionAddress] = []contractYayoiCollection.YayoiCollectionAuction{
+		{Finished: true, HighestBidder: testWinnerAddress, HighestBid: big.NewInt(1), Prompt: "finished"},
+		{HighestBid: big.NewInt(0)},
+		{HighestBidder: testWinnerAddress, HighestBid: big.NewInt(1), Prompt: "unfinished"},
+		// the current auction is still running
+		{HighestBidder: testWinnerAddress, HighestBid: big.NewInt(1), Prompt: "running"},
+	}
+	i := newTestIndexer(t, chain, nil)
+	i.cacheCollectionKey(testCollectionAddress)
+
+	auctionEnds := make(chan AuctionEnd, 10)
+	i.reconcileAuctions(context.Background(), auctionEnds)
+	close(auctionEnds)
+
+	var queued []AuctionEnd
+	for auctionEnd := range auctionEnds {
+		queued = append(queued, auctionEnd)
+	}
+	require.Len(t, queued, 1)
+	assert.Equal(t, AuctionEnd{
+		AuctionId:         2,
+		CollectionAddress: testCollectionAddress,
+		Winner:            testWinnerAddress,
+		Prompt:            "unfinished",
+	}, queued[0])
+}
+
+func TestIndexer_ReconcilesOnlyRecentAuctions(t *testing.T) {
+	chain := newFakeChain(10)
+	chain.currentAuctionId[testCollectionAddress] = reconcileAuctionWindow + 20
+	auctions := make([]contractYayoiCollection.YayoiCollectionAuction, reconcileAuctionWindow+20)
+	for n := range auctions {
+		auctions[n] = contractYayoiCollection.YayoiCollectionAuction{HighestBidder: testWinnerAddress, HighestBid: big.NewInt(1)}
+	}
+	chain.auctions[testCollectionAddress] = auctions
+
+	i := newTestIndexer(t, chain, nil)
+	i.cacheCollectionKey(testCollectionAddress)
+
+	auctionEnds, err := i.unfinishedAuctions(context.Background(), testCollectionAddress)
+	require.NoError(t, err)
+	require.Len(t, auctionEnds, reconcileAuctionWindow)
+	assert.Equal(t, uint64(20), auctionEnds[0].AuctionId)
+	assert.Equal(t, uint64(reconcileAuctionWindow+19), auctionEnds[len(auctionEnds)-1].AuctionId)
+}
+
+func TestIndexer_StartReconcilesPastAuctions(t *testing.T) {
+	chain := newFakeChain(10)
+	chain.currentAuctionId[testCollectionAddress] = 1
+	chain.auctions[testCollectionAddress] = []contractYayoiCollection.YayoiCollectionAuction{
+		{HighestBidder: testWinnerAddress, HighestBid: big.NewInt(1), Prompt: "missed while down"},
+	}
+	store := &memoryStateStore{state: &State{
+		FactoryAddress:   testFactoryAddress,
+		LastIndexedBlock: 10,
+		Collections: map[common.Address]*CollectionInfo{
+			testCollectionAddress: {
+				NextAuctionIdInitialized: true,
+				MetadataInitialized:      true,
+				CollectionAddress:        testCollectionAddress,
+				CreationTimestamp:        uint64(time.Now().Unix()),
+				AuctionDuration:          3600,
+				NextAuctionId:            2,
+			},
+		},
+	}}
+
+	i := newTestIndexer(t, chain, store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	auctionEnds := make(chan AuctionEnd, 10)
+	i.Start(ctx, auctionEnds)
+
+	select {
+	case auctionEnd := <-auctionEnds:
+		assert.Equal(t, uint64(0), auctionEnd.AuctionId)
+		assert.Equal(t, "missed while down", auctionEnd.Prompt)
+	case <-time.After(2 * time.Second):
+		t.Fatal("unfinished auction was not queued")
+	}
+}
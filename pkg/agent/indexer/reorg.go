@@ -0,0 +1,215 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// reorgHistorySize is the minimum number of indexed blocks whose hashes are kept to find a common ancestor
+	reorgHistorySize = 128
+)
+
+var (
+	ErrNoCommonAncestor   = errors.New("no common ancestor within reorg history")
+	ErrReorgWhileIndexing = errors.New("chain reorganized while indexing")
+)
+
+type FinishedAuction struct {
+	CollectionAddress common.Address
+	AuctionId         uint64
+	BlockNumber       uint64
+}
+
+// reorgHistory returns how many of the most recently indexed blocks keep their hash. It is never shorter than the
+// confirmation depth, so that any reorg the node can still report is covered.
+func (i *Indexer) reorgHistory() uint64 {
+	return max(reorgHistorySize, i.confirmationDepth)
+}
+
+// blockHashes returns the canonical hashes of the blocks from fromBlock to toBlock that fall within the reorg history,
+// checking that they extend the last indexed block.
+func (i *Indexer) blockHashes(ctx context.Context, fromBlock uint64, toBlock uint64) (map[uint64]common.Hash, error) {
+	if history := i.reorgHistory(); toBlock >= history && fromBlock < toBlock-history+1 {
+		fromBlock = toBlock - history + 1
+	}
+
+	var parentHash common.Hash
+	checkParent := false
+	if fromBlock > 0 {
+		i.mu.Lock()
+		parentHash, checkParent = i.recentBlocks[fromBlock-1]
+		i.mu.Unlock()
+	}
+
+	hashes := make(map[uint64]common.Hash, toBlock-fromBlock+1)
+	for number := fromBlock; number <= toBlock; number++ {
+		header, err := i.provider.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get header %d: %v", number, err)
+		}
+
+		if checkParent && header.ParentHash != parentHash {
+			return nil, fmt.Errorf("%w: parent hash mismatch at block %d", ErrReorgWhileIndexing, number)
+		}
+
+		parentHash = header.Hash()
+		checkParent = true
+		hashes[number] = parentHash
+	}
+
+	return hashes, nil
+}
+
+// recordBlocks keeps the hashes of newly indexed blocks and forgets blocks and finished auctions that fell out of the
+// reorg history.
+func (i *Indexer) recordBlocks(hashes map[uint64]common.Hash, lastIndexedBlock uint64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for number, hash := range hashes {
+		i.recentBlocks[number] = hash
+	}
+
+	history := i.reorgHistory()
+	if lastIndexedBlock < history {
+		return
+	}
+
+	oldest := lastIndexedBlock - history + 1
+	for n := range i.recentBlocks {
+		if n < oldest {
+			delete(i.recentBlocks, n)
+		}
+	}
+
+	recentFinished := i.recentFinished[:0]
+	for _, finished := range i.recentFinished {
+		if finished.BlockNumber >= oldest {
+			recentFinished = append(recentFinished, finished)
+		}
+	}
+	i.recentFinished = recentFinished
+}
+
+// recordFinishedAuction remembers a PromptAuctionFinished log so that it can be rolled back. Logs that are seen again,
+// e.g. from both the subscription and a polling pass, are recorded once.
+func (i *Indexer) recordFinishedAuction(collectionAddress common.Address, auctionId uint64, blockNumber uint64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	finished := FinishedAuction{
+		CollectionAddress: collectionAddress,
+		AuctionId:         auctionId,
+		BlockNumber:       blockNumber,
+	}
+	for _, recorded := range i.recentFinished {
+		if recorded == finished {
+			return
+		}
+	}
+
+	i.recentFinished = append(i.recentFinished, finished)
+}
+
+// handleReorg walks the recorded hashes back from the last indexed block and, if the canonical chain no longer
+// contains it, rolls the indexer back to the most recent block both chains still agree on. A reorg deeper than the
+// recorded history is returned as ErrNoCommonAncestor and leaves the indexer untouched.
+func (i *Indexer) handleReorg(ctx context.Context) error {
+	i.mu.Lock()
+	lastIndexedBlock := i.lastIndexedBlock
+	recorded := make(map[uint64]common.Hash, len(i.recentBlocks))
+	numbers := make([]uint64, 0, len(i.recentBlocks))
+	for n, hash := range i.recentBlocks {
+		if n <= lastIndexedBlock {
+			recorded[n] = hash
+			numbers = append(numbers, n)
+		}
+	}
+	i.mu.Unlock()
+
+	if _, ok := recorded[lastIndexedBlock]; !ok {
+		return nil
+	}
+
+	sort.Slice(numbers, func(a, b int) bool { return numbers[a] > numbers[b] })
+
+	for _, n := range numbers {
+		canonical, err := i.canonicalHash(ctx, n)
+		if err != nil {
+			return err
+		}
+		if canonical == recorded[n] {
+			if n == lastIndexedBlock {
+				return nil
+			}
+
+			i.rollback(n)
+			i.saveState(ctx)
+			return nil
+		}
+
+		if n == lastIndexedBlock {
+			slog.Warn("chain reorganization detected", "block", n, "expectedHash", recorded[n], "canonicalHash", canonical)
+		}
+	}
+
+	return fmt.Errorf("%w: oldest recorded block is %d", ErrNoCommonAncestor, numbers[len(numbers)-1])
+}
+
+func (i *Indexer) canonicalHash(ctx context.Context, number uint64) (common.Hash, error) {
+	header, err := i.provider.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to get header %d: %v", number, err)
+	}
+
+	return header.Hash(), nil
+}
+
+// rollback discards everything the indexer learned from blocks after the ancestor. Collections created after it are
+// forgotten so they get rediscovered, and auctions whose PromptAuctionFinished log was dropped are monitored again.
+func (i *Indexer) rollback(ancestor uint64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for n := range i.recentBlocks {
+		if n > ancestor {
+			delete(i.recentBlocks, n)
+		}
+	}
+
+	for addr, info := range i.cache {
+		if info.CreationBlock > ancestor {
+			slog.Info("rolling back collection", "collection", addr, "creationBlock", info.CreationBlock)
+			delete(i.cache, addr)
+		}
+	}
+
+	recentFinished := i.recentFinished[:0]
+	for _, finished := range i.recentFinished {
+		if finished.BlockNumber <= ancestor {
+			recentFinished = append(recentFinished, finished)
+			continue
+		}
+
+		info, ok := i.cache[finished.CollectionAddress]
+		if !ok {
+			continue
+		}
+
+		slog.Info("rolling back finished auction", "collection", finished.CollectionAddress, "auctionId", finished.AuctionId)
+		if info.NextAuctionId > finished.AuctionId+1 {
+			info.NextAuctionId = finished.AuctionId + 1
+		}
+	}
+	i.recentFinished = recentFinished
+
+	i.lastIndexedBlock = ancestor
+	slog.Info("rolled back indexer", "lastIndexedBlock", ancestor)
+}
@@ -0,0 +1,95 @@
+package indexer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexer_RollsBackToCommonAncestor(t *testing.T) {
+	chain := newFakeChain(10)
+	store := &memoryStateStore{}
+	i := newTestIndexer(t, chain, store)
+	chain.addLog(collectionCreatedLog(i, 2, testCollectionAddress))
+	chain.addLog(collectionCreatedLog(i, 8, testOwnerAddress))
+	chain.addLog(promptAuctionFinishedLog(t, i, 9, testCollectionAddress, 3))
+
+	require.NoError(t, i.indexEvents(context.Background()))
+	require.Len(t, i.Collections(), 2)
+
+	i.mu.Lock()
+	i.cache[testCollectionAddress].NextAuctionId = 6
+	i.mu.Unlock()
+
+	// blocks 7 to 10 are replaced and the logs in them dropped, then the chain grows past the old head
+	chain.fork(7)
+	chain.mine(2)
+
+	require.NoError(t, i.handleReorg(context.Background()))
+	assert.Equal(t, uint64(6), i.getLastIndexedBlock(), "rolled back to the common ancestor")
+
+	collections := i.Collections()
+	require.Len(t, collections, 1, "the collection created in a dropped block is forgotten")
+	assert.Equal(t, uint64(4), collections[0].NextAuctionId, "the auction finished in a dropped block is monitored again")
+
+	require.NoError(t, i.indexEvents(context.Background()))
+	queries := chain.queries()
+	assert.Equal(t, uint64(7), queries[len(queries)-1].FromBlock.Uint64())
+	assert.Equal(t, uint64(12), i.getLastIndexedBlock())
+	assert.Len(t, i.Collections(), 1)
+	assert.Equal(t, uint64(12), store.saved().LastIndexedBlock)
+}
+
+func TestIndexer_KeepsHashesOfEveryRecentBlock(t *testing.T) {
+	chain := newFakeChain(300)
+	i := newTestIndexer(t, chain, nil)
+	i.confirmationDepth = 200
+
+	require.NoError(t, i.indexEvents(context.Background()))
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	assert.Equal(t, uint64(100), i.lastIndexedBlock)
+	assert.Len(t, i.recentBlocks, 101, "the history is never shorter than the confirmation depth")
+	for n := uint64(0); n <= 100; n++ {
+		assert.Contains(t, i.recentBlocks, n)
+	}
+}
+
+func TestIndexer_FailsWithoutCommonAncestor(t *testing.T) {
+	chain := newFakeChain(200)
+	i := newTestIndexer(t, chain, nil)
+	chain.addLog(collectionCreatedLog(i, 150, testCollectionAddress))
+
+	require.NoError(t, i.indexEvents(context.Background()))
+	require.Len(t, i.Collections(), 1)
+
+	// the fork point lies before the oldest recorded block
+	chain.fork(50)
+
+	assert.ErrorIs(t, i.handleReorg(context.Background()), ErrNoCommonAncestor)
+	assert.ErrorIs(t, i.indexEvents(context.Background()), ErrNoCommonAncestor)
+	assert.Equal(t, uint64(200), i.getLastIndexedBlock(), "the indexer is not rewound to genesis")
+	assert.Len(t, i.Collections(), 1)
+}
+
+func TestIndexer_FailsOnReorgWhileIndexing(t *testing.T) {
+	chain := newFakeChain(10)
+	i := newTestIndexer(t, chain, nil)
+
+	require.NoError(t, i.indexEvents(context.Background()))
+
+	// a log whose block hash does not match the header the indexer just read
+	log := collectionCreatedLog(i, 12, testCollectionAddress)
+	chain.mine(5)
+	chain.addLog(log)
+	chain.mu.Lock()
+	chain.logs[12][0].BlockHash[0] ^= 0xff
+	chain.mu.Unlock()
+
+	assert.ErrorIs(t, i.indexEvents(context.Background()), ErrReorgWhileIndexing)
+	assert.Equal(t, uint64(10), i.getLastIndexedBlock())
+	assert.Empty(t, i.Collections())
+}
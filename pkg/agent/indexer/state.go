@@ -0,0 +1,67 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/sealing"
+)
+
+type State struct {
+	FactoryAddress   common.Address
+	LastIndexedBlock uint64
+	Collections      map[common.Address]*CollectionInfo
+	RecentBlocks     map[uint64]common.Hash
+	RecentFinished   []FinishedAuction
+}
+
+type StateStore interface {
+	Load(ctx context.Context) (*State, error)
+	Save(ctx context.Context, state *State) error
+}
+
+type FileStateStore struct {
+	dstackTappdEndpoint string
+	filePath            string
+}
+
+var _ StateStore = (*FileStateStore)(nil)
+
+func NewFileStateStore(dstackTappdEndpoint string, filePath string) *FileStateStore {
+	return &FileStateStore{
+		dstackTappdEndpoint: dstackTappdEndpoint,
+		filePath:            filePath,
+	}
+}
+
+func (s *FileStateStore) Load(ctx context.Context) (*State, error) {
+	if _, err := os.Stat(s.filePath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	data, err := sealing.ReadSealedFile(ctx, s.dstackTappdEndpoint, s.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexer state: %v", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal indexer state: %v", err)
+	}
+
+	return &state, nil
+}
+
+func (s *FileStateStore) Save(ctx context.Context, state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal indexer state: %v", err)
+	}
+
+	return sealing.WriteSealedFile(ctx, s.dstackTappdEndpoint, s.filePath, data)
+}
@@ -0,0 +1,110 @@
+package indexer
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	subscriptionBufferSize = 100
+)
+
+func (i *Indexer) eventTopics() [][]common.Hash {
+	return [][]common.Hash{{
+		i.factoryAbi.Events["CollectionCreated"].ID,
+		i.collectionAbi.Events["PromptAuctionFinished"].ID,
+	}}
+}
+
+// subscribeEventsTask indexes events whenever the node pushes a matching log. While the subscription is down it
+// polls every eventPollingInterval and keeps trying to resubscribe; every (re)subscription is followed by an
+// indexing pass so that logs emitted during the gap are backfilled.
+func (i *Indexer) subscribeEventsTask(ctx context.Context) {
+	slog.Info("starting event subscription task")
+	ticker := time.NewTicker(i.eventPollingInterval)
+	defer ticker.Stop()
+
+	for {
+		logs := make(chan types.Log, subscriptionBufferSize)
+		sub, err := i.provider.SubscribeFilterLogs(ctx, ethereum.FilterQuery{
+			Topics: i.eventTopics(),
+		}, logs)
+		if err != nil {
+			slog.Warn("failed to subscribe to logs, polling instead", "error", err)
+
+			select {
+			case <-ticker.C:
+				i.indexEventsLogged(ctx)
+				continue
+			case <-ctx.Done():
+				slog.Info("event subscription task stopping")
+				return
+			}
+		}
+
+		slog.Info("subscribed to logs")
+		i.indexEventsLogged(ctx)
+
+		if stopped := i.consumeSubscription(ctx, sub, logs, ticker); stopped {
+			slog.Info("event subscription task stopping")
+			return
+		}
+	}
+}
+
+func (i *Indexer) consumeSubscription(ctx context.Context, sub ethereum.Subscription, logs <-chan types.Log, ticker *time.Ticker) bool {
+	defer sub.Unsubscribe()
+
+	var pendingBlock uint64
+	for {
+		// Logs newer than the confirmation depth can't be indexed yet, keep polling until they are.
+		var tick <-chan time.Time
+		if pendingBlock > i.getLastIndexedBlock() {
+			tick = ticker.C
+		}
+
+		select {
+		case log := <-logs:
+			pendingBlock = max(pendingBlock, log.BlockNumber)
+			pendingBlock = max(pendingBlock, drainLogs(logs))
+			i.indexEventsLogged(ctx)
+		case <-tick:
+			i.indexEventsLogged(ctx)
+		case err := <-sub.Err():
+			slog.Warn("log subscription dropped, falling back to polling", "error", err)
+			return false
+		case <-ctx.Done():
+			return true
+		}
+	}
+}
+
+func drainLogs(logs <-chan types.Log) uint64 {
+	var highest uint64
+	for {
+		select {
+		case log := <-logs:
+			highest = max(highest, log.BlockNumber)
+		default:
+			return highest
+		}
+	}
+}
+
+func (i *Indexer) indexEventsLogged(ctx context.Context) {
+	if err := i.indexEvents(ctx); err != nil {
+		slog.Error("failed to index events", "error", err)
+	}
+}
+
+func (i *Indexer) getLastIndexedBlock() uint64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.lastIndexedBlock
+}
@@ -0,0 +1,109 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runSubscribeEventsTask(t *testing.T, i *Indexer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		i.subscribeEventsTask(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestIndexer_PollsWhenSubscribeFails(t *testing.T) {
+	chain := newFakeChain(10)
+	chain.subscribeErr = errors.New("subscriptions not supported")
+	i := newTestIndexer(t, chain, nil)
+
+	runSubscribeEventsTask(t, i)
+
+	chain.mine(5)
+	chain.addLog(collectionCreatedLog(i, 12, testCollectionAddress))
+
+	require.Eventually(t, func() bool {
+		return i.getLastIndexedBlock() == 15 && len(i.Collections()) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestIndexer_BackfillsAfterSubscriptionDrops(t *testing.T) {
+	chain := newFakeChain(10)
+	i := newTestIndexer(t, chain, nil)
+
+	runSubscribeEventsTask(t, i)
+
+	require.Eventually(t, func() bool {
+		return chain.subscription(0) != nil && i.getLastIndexedBlock() == 10
+	}, 2*time.Second, 5*time.Millisecond)
+
+	chain.mu.Lock()
+	chain.subscribeErr = errors.New("connection refused")
+	chain.mu.Unlock()
+	chain.subscription(0).drop()
+
+	// logs emitted while the subscription is down are picked up by polling
+	chain.mine(5)
+	chain.addLog(collectionCreatedLog(i, 13, testCollectionAddress))
+	require.Eventually(t, func() bool {
+		return i.getLastIndexedBlock() == 15 && len(i.Collections()) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+
+	chain.mu.Lock()
+	chain.subscribeErr = nil
+	chain.mu.Unlock()
+
+	require.Eventually(t, func() bool {
+		return chain.subscription(1) != nil
+	}, 2*time.Second, 5*time.Millisecond, "resubscribes once the node accepts subscriptions again")
+}
+
+func TestIndexer_DeduplicatesLogs(t *testing.T) {
+	chain := newFakeChain(10)
+	i := newTestIndexer(t, chain, nil)
+	chain.addLog(collectionCreatedLog(i, 2, testCollectionAddress))
+
+	runSubscribeEventsTask(t, i)
+
+	require.Eventually(t, func() bool {
+		return chain.subscription(0) != nil && i.getLastIndexedBlock() == 10
+	}, 2*time.Second, 5*time.Millisecond)
+
+	// the same log is pushed by the subscription and found again by the indexing passes that follow
+	chain.mine(1)
+	log := chain.addLog(promptAuctionFinishedLog(t, i, 11, testCollectionAddress, 0))
+	sub := chain.subscription(0)
+	sub.logs <- log
+	sub.logs <- log
+
+	require.Eventually(t, func() bool {
+		return i.getLastIndexedBlock() == 11
+	}, 2*time.Second, 5*time.Millisecond)
+
+	chain.mine(1)
+	sub.logs <- log
+	require.Eventually(t, func() bool {
+		return i.getLastIndexedBlock() == 12
+	}, 2*time.Second, 5*time.Millisecond)
+
+	queries := chain.queries()
+	for n := 1; n < len(queries); n++ {
+		assert.Greater(t, queries[n].FromBlock.Uint64(), queries[n-1].ToBlock.Uint64(), "blocks are scanned once")
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	assert.Len(t, i.recentFinished, 1)
+}
@@ -0,0 +1,200 @@
+package ipfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	CodecRaw   uint64 = 0x55
+	CodecDagPb uint64 = 0x70
+
+	multihashSha256 uint64 = 0x12
+
+	// MaxBlockSize is the chunk size IPFS uses by default, so content up to this size is stored as a single block
+	MaxBlockSize = 256 * 1024
+
+	unixfsTypeFile = 2
+)
+
+var (
+	ErrUnsupportedCid = errors.New("unsupported cid")
+	ErrCidMismatch    = errors.New("content does not match cid")
+)
+
+var base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Cid is a content identifier whose multihash is a SHA-256 digest, which covers what IPFS produces by default.
+type Cid struct {
+	Version int
+	Codec   uint64
+	Digest  []byte
+}
+
+func ParseCid(s string) (*Cid, error) {
+	if len(s) == 46 && strings.HasPrefix(s, "Qm") {
+		multihash, err := base58Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cid: %w", err)
+		}
+
+		digest, err := decodeMultihash(multihash)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Cid{Version: 0, Codec: CodecDagPb, Digest: digest}, nil
+	}
+
+	if s == "" {
+		return nil, errors.New("invalid cid: empty")
+	}
+
+	var data []byte
+	var err error
+	switch s[0] {
+	case 'b':
+		data, err = base32Encoding.DecodeString(strings.ToUpper(s[1:]))
+	case 'z':
+		data, err = base58Decode(s[1:])
+	default:
+		return nil, fmt.Errorf("%w: multibase %q", ErrUnsupportedCid, s[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid cid: %w", err)
+	}
+
+	version, n := binary.Uvarint(data)
+	if n <= 0 || version != 1 {
+		return nil, fmt.Errorf("%w: version %d", ErrUnsupportedCid, version)
+	}
+	data = data[n:]
+
+	codec, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, errors.New("invalid cid: codec")
+	}
+	if codec != CodecRaw && codec != CodecDagPb {
+		return nil, fmt.Errorf("%w: codec 0x%x", ErrUnsupportedCid, codec)
+	}
+
+	digest, err := decodeMultihash(data[n:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cid{Version: 1, Codec: codec, Digest: digest}, nil
+}
+
+// Bytes returns the binary form of the cid, as used in dag-pb links.
+func (c *Cid) Bytes() []byte {
+	multihash := encodeMultihash(c.Digest)
+	if c.Version == 0 {
+		return multihash
+	}
+
+	data := binary.AppendUvarint(nil, 1)
+	data = binary.AppendUvarint(data, c.Codec)
+	return append(data, multihash...)
+}
+
+func (c *Cid) String() string {
+	if c.Version == 0 {
+		return base58Encode(c.Bytes())
+	}
+
+	return "b" + strings.ToLower(base32Encoding.EncodeToString(c.Bytes()))
+}
+
+// Verify checks that data is the content the cid refers to. Content spanning several blocks can only be verified
+// against a version 1 cid, assuming the layout of CidV1File.
+func (c *Cid) Verify(data []byte) error {
+	var digest []byte
+	switch {
+	case c.Codec == CodecRaw:
+		digest = CidV1Raw(data).Digest
+	case c.Codec == CodecDagPb && len(data) <= MaxBlockSize:
+		blockDigest := sha256.Sum256(unixfsFileBlock(data))
+		digest = blockDigest[:]
+	case c.Codec == CodecDagPb && c.Version == 1:
+		digest = CidV1File(data).Digest
+	case c.Codec == CodecDagPb:
+		return fmt.Errorf("%w: content spans several blocks", ErrUnsupportedCid)
+	default:
+		return fmt.Errorf("%w: codec 0x%x", ErrUnsupportedCid, c.Codec)
+	}
+
+	if !bytes.Equal(digest, c.Digest) {
+		return ErrCidMismatch
+	}
+
+	return nil
+}
+
+// CidV0 computes the cid IPFS assigns by default to a file that fits in a single block.
+func CidV0(data []byte) (*Cid, error) {
+	if len(data) > MaxBlockSize {
+		return nil, fmt.Errorf("%w: content spans several blocks", ErrUnsupportedCid)
+	}
+
+	digest := sha256.Sum256(unixfsFileBlock(data))
+	return &Cid{Version: 0, Codec: CodecDagPb, Digest: digest[:]}, nil
+}
+
+// CidV1Raw computes the cid of data stored as a single raw block.
+func CidV1Raw(data []byte) *Cid {
+	digest := sha256.Sum256(data)
+	return &Cid{Version: 1, Codec: CodecRaw, Digest: digest[:]}
+}
+
+// unixfsFileBlock encodes a dag-pb node without links holding a UnixFS file with the data inline.
+func unixfsFileBlock(data []byte) []byte {
+	var unixfs []byte
+	unixfs = protobufVarint(unixfs, 1, unixfsTypeFile)
+	if len(data) > 0 {
+		unixfs = protobufBytes(unixfs, 2, data)
+	}
+	unixfs = protobufVarint(unixfs, 3, uint64(len(data)))
+
+	return protobufBytes(nil, 1, unixfs)
+}
+
+func protobufVarint(buf []byte, field uint64, value uint64) []byte {
+	buf = binary.AppendUvarint(buf, field<<3)
+	return binary.AppendUvarint(buf, value)
+}
+
+func protobufBytes(buf []byte, field uint64, value []byte) []byte {
+	buf = binary.AppendUvarint(buf, field<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func encodeMultihash(digest []byte) []byte {
+	multihash := binary.AppendUvarint(nil, multihashSha256)
+	multihash = binary.AppendUvarint(multihash, uint64(len(digest)))
+	return append(multihash, digest...)
+}
+
+func decodeMultihash(multihash []byte) ([]byte, error) {
+	code, n := binary.Uvarint(multihash)
+	if n <= 0 {
+		return nil, errors.New("invalid multihash")
+	}
+	if code != multihashSha256 {
+		return nil, fmt.Errorf("%w: multihash 0x%x", ErrUnsupportedCid, code)
+	}
+	multihash = multihash[n:]
+
+	length, n := binary.Uvarint(multihash)
+	if n <= 0 || length != sha256.Size || len(multihash[n:]) != sha256.Size {
+		return nil, errors.New("invalid multihash length")
+	}
+
+	return multihash[n:], nil
+}
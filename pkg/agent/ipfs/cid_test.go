@@ -0,0 +1,84 @@
+package ipfs_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+)
+
+var helloWorld = []byte("hello world\n")
+
+func TestCidV0(t *testing.T) {
+	cid, err := ipfs.CidV0(helloWorld)
+	require.NoError(t, err)
+
+	// produced by `echo "hello world" | ipfs add`
+	assert.Equal(t, "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o", cid.String())
+}
+
+func TestCidV1Raw(t *testing.T) {
+	// produced by `echo "hello world" | ipfs add --cid-version 1`
+	assert.Equal(t, "bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4", ipfs.CidV1Raw(helloWorld).String())
+}
+
+func TestParseCid(t *testing.T) {
+	tests := []struct {
+		name    string
+		cid     string
+		wantErr bool
+	}{
+		{name: "v0", cid: "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"},
+		{name: "v1 raw", cid: "bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4"},
+		{name: "empty", cid: "", wantErr: true},
+		{name: "unknown multibase", cid: "xyz", wantErr: true},
+		{name: "malformed", cid: "bafkrei", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cid, err := ipfs.ParseCid(tt.cid)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.cid, cid.String())
+			assert.NoError(t, cid.Verify(helloWorld))
+			assert.ErrorIs(t, cid.Verify([]byte("goodbye world\n")), ipfs.ErrCidMismatch)
+		})
+	}
+}
+
+func TestCidV1File(t *testing.T) {
+	assert.Equal(t, ipfs.CidV1Raw(helloWorld), ipfs.CidV1File(helloWorld))
+
+	small := make([]byte, ipfs.MaxBlockSize)
+	assert.Equal(t, ipfs.CidV1Raw(small), ipfs.CidV1File(small))
+
+	for _, size := range []int{ipfs.MaxBlockSize + 1, 3 * ipfs.MaxBlockSize, 175 * ipfs.MaxBlockSize} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+
+		cid := ipfs.CidV1File(data)
+		assert.Equal(t, 1, cid.Version)
+		assert.Equal(t, ipfs.CodecDagPb, cid.Codec)
+		assert.True(t, strings.HasPrefix(cid.String(), "bafybei"), cid.String())
+		assert.NoError(t, cid.Verify(data))
+
+		data[size-1] ^= 1
+		assert.ErrorIs(t, cid.Verify(data), ipfs.ErrCidMismatch)
+	}
+}
+
+func TestCid_VerifyLargeV0(t *testing.T) {
+	cid, err := ipfs.ParseCid("QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, cid.Verify(make([]byte, ipfs.MaxBlockSize+1)), ipfs.ErrUnsupportedCid)
+}
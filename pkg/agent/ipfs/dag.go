@@ -0,0 +1,80 @@
+package ipfs
+
+import (
+	"crypto/sha256"
+)
+
+// maxLinks is the number of links per node of the balanced layout, matching Kubo's default.
+const maxLinks = 174
+
+type dagLink struct {
+	cid *Cid
+	// tsize is the size of the linked block and all blocks below it
+	tsize uint64
+	// filesize is the size of the file content below the link
+	filesize uint64
+}
+
+// CidV1File computes the cid `ipfs add --cid-version 1` assigns to data with the default importer settings: raw
+// leaves of MaxBlockSize bytes under a balanced tree of dag-pb UnixFS nodes with up to 174 links each. Data fitting
+// in a single block is a raw block.
+func CidV1File(data []byte) *Cid {
+	if len(data) <= MaxBlockSize {
+		return CidV1Raw(data)
+	}
+
+	var level []dagLink
+	for offset := 0; offset < len(data); offset += MaxBlockSize {
+		chunk := data[offset:min(offset+MaxBlockSize, len(data))]
+		level = append(level, dagLink{
+			cid:      CidV1Raw(chunk),
+			tsize:    uint64(len(chunk)),
+			filesize: uint64(len(chunk)),
+		})
+	}
+
+	// the balanced layout fills each subtree before starting the next, which is the same as grouping bottom up
+	for len(level) > 1 {
+		var parents []dagLink
+		for i := 0; i < len(level); i += maxLinks {
+			parents = append(parents, unixfsFileNode(level[i:min(i+maxLinks, len(level))]))
+		}
+		level = parents
+	}
+
+	return level[0].cid
+}
+
+// unixfsFileNode encodes a dag-pb node linking the chunks of a UnixFS file. dag-pb places the links before the data.
+func unixfsFileNode(children []dagLink) dagLink {
+	var filesize, tsize uint64
+	for _, child := range children {
+		filesize += child.filesize
+		tsize += child.tsize
+	}
+
+	var unixfs []byte
+	unixfs = protobufVarint(unixfs, 1, unixfsTypeFile)
+	unixfs = protobufVarint(unixfs, 3, filesize)
+	for _, child := range children {
+		unixfs = protobufVarint(unixfs, 4, child.filesize)
+	}
+
+	var block []byte
+	for _, child := range children {
+		var link []byte
+		link = protobufBytes(link, 1, child.cid.Bytes())
+		// Kubo always sets the name, even though it is empty for file chunks
+		link = protobufBytes(link, 2, nil)
+		link = protobufVarint(link, 3, child.tsize)
+		block = protobufBytes(block, 2, link)
+	}
+	block = protobufBytes(block, 1, unixfs)
+
+	digest := sha256.Sum256(block)
+	return dagLink{
+		cid:      &Cid{Version: 1, Codec: CodecDagPb, Digest: digest[:]},
+		tsize:    uint64(len(block)) + tsize,
+		filesize: filesize,
+	}
+}
@@ -0,0 +1,316 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/sealing"
+	contractYayoiCollection "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiCollection"
+)
+
+const (
+	defaultCollectionLifetime = 30 * 24 * time.Hour
+	expirySweepInterval       = 1 * time.Minute
+	tombstonesFileName        = "tombstones"
+)
+
+// ErrCollectionExpired is returned for collections past their lifetime, whose system prompt is never decrypted again.
+var ErrCollectionExpired = errors.New("collection expired")
+
+// Tombstone records an expired collection. Every system prompt is sealed to the same agent key, so the key cannot be
+// destroyed per collection; instead the sealed tombstone list is checked before any decryption. The system prompt is
+// identified by the hash of its wrapped key as well as by its uri, so that hosting it again elsewhere does not revive
+// it.
+type Tombstone struct {
+	Address         common.Address `json:"address"`
+	SystemPromptUri string         `json:"systemPromptUri"`
+	// KeyHash is the systemPromptKeyHash of the sealed system prompt, empty if it could not be fetched on expiry
+	KeyHash string `json:"keyHash,omitempty"`
+	// ExpiresAt is the end of the collection's lifetime; auctions that ended before it are still served
+	ExpiresAt time.Time `json:"expiresAt"`
+	ExpiredAt time.Time `json:"expiredAt"`
+}
+
+type TombstoneStore interface {
+	Load(ctx context.Context) ([]Tombstone, error)
+	Save(ctx context.Context, tombstones []Tombstone) error
+}
+
+type FileTombstoneStore struct {
+	dstackTappdEndpoint string
+	filePath            string
+}
+
+var _ TombstoneStore = (*FileTombstoneStore)(nil)
+
+func NewFileTombstoneStore(dstackTappdEndpoint string, filePath string) *FileTombstoneStore {
+	return &FileTombstoneStore{
+		dstackTappdEndpoint: dstackTappdEndpoint,
+		filePath:            filePath,
+	}
+}
+
+func (s *FileTombstoneStore) Load(ctx context.Context) ([]Tombstone, error) {
+	if _, err := os.Stat(s.filePath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	data, err := sealing.ReadSealedFile(ctx, s.dstackTappdEndpoint, s.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tombstones: %v", err)
+	}
+
+	var tombstones []Tombstone
+	if err := json.Unmarshal(data, &tombstones); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tombstones: %v", err)
+	}
+
+	return tombstones, nil
+}
+
+func (s *FileTombstoneStore) Save(ctx context.Context, tombstones []Tombstone) error {
+	data, err := json.Marshal(tombstones)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tombstones: %v", err)
+	}
+
+	return sealing.WriteSealedFile(ctx, s.dstackTappdEndpoint, s.filePath, data)
+}
+
+type tombstones struct {
+	mu          sync.Mutex
+	store       TombstoneStore
+	collections map[common.Address]Tombstone
+	// uris and keys map the tombstoned system prompts to the earliest end of lifetime recorded for them
+	uris map[string]time.Time
+	keys map[string]time.Time
+}
+
+func newTombstones(store TombstoneStore) *tombstones {
+	return &tombstones{
+		store:       store,
+		collections: make(map[common.Address]Tombstone),
+		uris:        make(map[string]time.Time),
+		keys:        make(map[string]time.Time),
+	}
+}
+
+func (t *tombstones) load(ctx context.Context) error {
+	if t.store == nil {
+		return nil
+	}
+
+	loaded, err := t.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tombstone := range loaded {
+		t.record(tombstone)
+	}
+
+	return nil
+}
+
+func (t *tombstones) record(tombstone Tombstone) {
+	t.collections[tombstone.Address] = tombstone
+	recordExpiry(t.uris, tombstone.SystemPromptUri, tombstone.ExpiresAt)
+	if tombstone.KeyHash != "" {
+		recordExpiry(t.keys, tombstone.KeyHash, tombstone.ExpiresAt)
+	}
+}
+
+func recordExpiry(expiries map[string]time.Time, key string, expiresAt time.Time) {
+	if current, ok := expiries[key]; !ok || expiresAt.Before(current) {
+		expiries[key] = expiresAt
+	}
+}
+
+// add records the tombstone and persists the whole list. The tombstone stays in effect in memory even if it cannot be
+// persisted.
+func (t *tombstones) add(ctx context.Context, tombstone Tombstone) error {
+	t.mu.Lock()
+	t.record(tombstone)
+
+	all := make([]Tombstone, 0, len(t.collections))
+	for _, tombstone := range t.collections {
+		all = append(all, tombstone)
+	}
+	t.mu.Unlock()
+
+	if t.store == nil {
+		return nil
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Address.Cmp(all[j].Address) < 0 })
+	return t.store.Save(ctx, all)
+}
+
+func (t *tombstones) hasCollection(address common.Address) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.collections[address]
+	return ok
+}
+
+// covers reports whether the system prompt was tombstoned with a lifetime that had ended by at. Tombstones written
+// before the lifetime was recorded cover any time.
+func (t *tombstones) covers(systemPromptUri string, keyHash string, at time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if expiresAt, ok := t.uris[systemPromptUri]; ok && !expiresAt.After(at) {
+		return true
+	}
+	expiresAt, ok := t.keys[keyHash]
+	return ok && !expiresAt.After(at)
+}
+
+type CollectionLifetime struct {
+	Address          common.Address `json:"address"`
+	CreatedAt        time.Time      `json:"createdAt"`
+	ExpiresAt        time.Time      `json:"expiresAt"`
+	RemainingSeconds int64          `json:"remainingSeconds"`
+	Expired          bool           `json:"expired"`
+}
+
+func (a *Agent) collectionLifetime(address common.Address, creationTimestamp uint64) CollectionLifetime {
+	createdAt := time.Unix(int64(creationTimestamp), 0).UTC()
+	expiresAt := createdAt.Add(a.lifetime)
+
+	remaining := expiresAt.Sub(a.clock.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return CollectionLifetime{
+		Address:          address,
+		CreatedAt:        createdAt,
+		ExpiresAt:        expiresAt,
+		RemainingSeconds: int64(remaining / time.Second),
+		Expired:          remaining == 0 || a.tombstones.hasCollection(address),
+	}
+}
+
+// CollectionLifetimes returns the lifetime of every indexed collection.
+func (a *Agent) CollectionLifetimes() []CollectionLifetime {
+	collections := a.indexer.Collections()
+
+	lifetimes := make([]CollectionLifetime, 0, len(collections))
+	for _, info := range collections {
+		if info.CreationTimestamp == 0 {
+			continue
+		}
+		lifetimes = append(lifetimes, a.collectionLifetime(info.CollectionAddress, info.CreationTimestamp))
+	}
+	sort.Slice(lifetimes, func(i, j int) bool { return lifetimes[i].Address.Cmp(lifetimes[j].Address) < 0 })
+
+	return lifetimes
+}
+
+// checkCollectionLifetime fails with ErrCollectionExpired for auctions that ended after the collection's lifetime,
+// expiring the collection if it has not been already. Auctions that ended within the lifetime are served however late
+// their job runs.
+func (a *Agent) checkCollectionLifetime(ctx context.Context, collection *contractYayoiCollection.ContractYayoiCollection, collectionAddress common.Address, auctionEndedAt time.Time) error {
+	creationTimestamp, err := collection.CreationTimestamp(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("failed to get creation timestamp: %w", err)
+	}
+
+	lifetime := a.collectionLifetime(collectionAddress, creationTimestamp)
+	if auctionEndedAt.Before(lifetime.ExpiresAt) {
+		return nil
+	}
+
+	if !a.tombstones.hasCollection(collectionAddress) {
+		if err := a.expireCollection(ctx, collection, collectionAddress, lifetime.ExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrCollectionExpired, collectionAddress)
+}
+
+// expireCollection tombstones the collection and erases its cached system prompt.
+func (a *Agent) expireCollection(ctx context.Context, collection *contractYayoiCollection.ContractYayoiCollection, collectionAddress common.Address, expiresAt time.Time) error {
+	systemPromptUri, err := collection.SystemPromptUri(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("failed to get system prompt uri: %w", err)
+	}
+
+	// The uri alone still tombstones the system prompt if it cannot be fetched
+	var keyHash string
+	if body, err := a.fetchSystemPrompt(ctx, systemPromptUri); err != nil {
+		slog.Warn("failed to fetch system prompt of expired collection", "collection", collectionAddress, "error", err)
+	} else {
+		keyHash = systemPromptKeyHash(body)
+	}
+
+	now := a.clock.Now()
+	err = a.tombstones.add(ctx, Tombstone{
+		Address:         collectionAddress,
+		SystemPromptUri: systemPromptUri,
+		KeyHash:         keyHash,
+		ExpiresAt:       expiresAt,
+		ExpiredAt:       now,
+	})
+	a.systemPromptCache.Remove(collectionAddress.Hex())
+	a.setCollectionStatus(ctx, CollectionStatus{
+		Address:   collectionAddress,
+		Usable:    false,
+		Reason:    ErrCollectionExpired.Error(),
+		CheckedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save tombstone: %w", err)
+	}
+
+	slog.Info("collection expired", "collection", collectionAddress)
+	return nil
+}
+
+func (a *Agent) expireCollectionsTask(ctx context.Context) {
+	ticker := time.NewTicker(expirySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.expireCollections(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *Agent) expireCollections(ctx context.Context) {
+	for _, lifetime := range a.CollectionLifetimes() {
+		if !lifetime.Expired || a.tombstones.hasCollection(lifetime.Address) {
+			continue
+		}
+
+		collection, err := contractYayoiCollection.NewContractYayoiCollection(lifetime.Address, a.ethClient)
+		if err != nil {
+			slog.Error("failed to create collection", "collection", lifetime.Address, "error", err)
+			continue
+		}
+
+		if err := a.expireCollection(ctx, collection, lifetime.Address, lifetime.ExpiresAt); err != nil {
+			slog.Error("failed to expire collection", "collection", lifetime.Address, "error", err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/envelope"
+)
+
+func TestTombstones_Covers(t *testing.T) {
+	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte("a watercolor garden"))
+	require.NoError(t, err)
+	resealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte("a watercolor garden"))
+	require.NoError(t, err)
+
+	expiresAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tombstones := newTombstones(nil)
+	require.NoError(t, tombstones.add(context.Background(), Tombstone{
+		Address:         common.HexToAddress("0x01"),
+		SystemPromptUri: "ipfs://expired",
+		KeyHash:         systemPromptKeyHash(sealed),
+		ExpiresAt:       expiresAt,
+	}))
+	require.NoError(t, tombstones.add(context.Background(), Tombstone{
+		Address:         common.HexToAddress("0x02"),
+		SystemPromptUri: "ipfs://legacy",
+	}))
+
+	before := expiresAt.Add(-time.Second)
+	assert.False(t, tombstones.covers("ipfs://expired", "", before), "auctions that ended within the lifetime are served")
+	assert.True(t, tombstones.covers("ipfs://expired", "", expiresAt))
+	assert.True(t, tombstones.covers("https://mirror.example/prompt", systemPromptKeyHash(sealed), expiresAt), "the same envelope hosted elsewhere")
+	assert.False(t, tombstones.covers("https://mirror.example/prompt", systemPromptKeyHash(resealed), expiresAt), "a newly sealed envelope")
+	assert.True(t, tombstones.covers("ipfs://legacy", "", before), "tombstones without a lifetime cover any time")
+}
@@ -0,0 +1,216 @@
+// Package migration moves an agent's key material to a new enclave. The new agent offers an ephemeral X25519 key bound
+// to its TDX quote, and the old agent only seals its secrets to that key once the quote verifies and the new enclave's
+// measurements are allowlisted. The old agent binds its own ephemeral key to its quote in turn, so the new agent only
+// imports secrets sent by an allowlisted enclave.
+package migration
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+)
+
+const (
+	OfferPath  = "/migration/offer"
+	ImportPath = "/migration/import"
+	ExportPath = "/migration/export"
+
+	domain = "yayoi's garden migration v1"
+	// packageDomain separates the report data of a package's quote from that of an offer
+	packageDomain = domain + " package"
+)
+
+var (
+	ErrInvalidOffer   = errors.New("invalid migration offer")
+	ErrInvalidPackage = errors.New("invalid migration package")
+	// ErrDeliveryUnknown is returned when sending a package failed in a way that does not tell whether the receiver
+	// imported it.
+	ErrDeliveryUnknown = errors.New("migration package delivery unknown")
+)
+
+// Secrets is the key material of an agent. Everything else in its setup comes from the environment of the new agent.
+type Secrets struct {
+	AccountPrivateKeySeed []byte `json:"accountPrivateKeySeed"`
+	// RsaPrivateKey is PKCS #1 DER encoded
+	RsaPrivateKey []byte `json:"rsaPrivateKey"`
+}
+
+func NewSecrets(accountPrivateKeySeed []byte, rsaPrivateKey *rsa.PrivateKey) *Secrets {
+	return &Secrets{
+		AccountPrivateKeySeed: accountPrivateKeySeed,
+		RsaPrivateKey:         x509.MarshalPKCS1PrivateKey(rsaPrivateKey),
+	}
+}
+
+func (s *Secrets) ParseRsaPrivateKey() (*rsa.PrivateKey, error) {
+	rsaPrivateKey, err := x509.ParsePKCS1PrivateKey(s.RsaPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rsa private key: %w", err)
+	}
+
+	return rsaPrivateKey, nil
+}
+
+// Offer is served by the new agent. Its quote commits to PublicKey through OfferReportData.
+type Offer struct {
+	PublicKey []byte `json:"publicKey"`
+	Quote     string `json:"quote"`
+}
+
+// Package is the secrets sealed to an offer by the old agent.
+type Package struct {
+	// PublicKey is the sender's ephemeral X25519 key
+	PublicKey  []byte `json:"publicKey"`
+	Ciphertext []byte `json:"ciphertext"`
+	// Quote is the sender's quote, committing to PublicKey and the offered key through PackageReportData
+	Quote string `json:"quote"`
+}
+
+// OfferReportData returns the report data of an offer's quote, which fills all 64 bytes with a hash of the offered key.
+func OfferReportData(publicKey []byte) []byte {
+	reportData := sha512.Sum512(append([]byte(domain), publicKey...))
+	return reportData[:]
+}
+
+// PackageReportData returns the report data of a package's quote, a hash of the sender's ephemeral key and of the
+// offered key it is sealed to, so that the quote cannot be replayed with another package.
+func PackageReportData(senderPublicKey []byte, recipientPublicKey []byte) []byte {
+	hash := sha512.New()
+	hash.Write([]byte(packageDomain))
+	hash.Write(senderPublicKey)
+	hash.Write(recipientPublicKey)
+	return hash.Sum(nil)
+}
+
+// verifyBoundQuote verifies a quote against the collateral and checks that its report data is reportData.
+func verifyBoundQuote(rawQuote string, reportData []byte, collateral *tdx.Collateral, now time.Time) (*tdx.Quote, error) {
+	quote, err := tdx.ParseQuote([]byte(rawQuote))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := quote.Verify(collateral, now); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(quote.Body.ReportData[:], reportData) {
+		return nil, errors.New("quote does not commit to the migration key")
+	}
+
+	return quote, nil
+}
+
+// checkMeasurement returns the measurement of a quote if it is allowlisted and the quote is not from a debug TD, whose
+// memory the host can read.
+func checkMeasurement(quote *tdx.Quote, allowlist []tdx.Measurement) (tdx.Measurement, error) {
+	measurement := tdx.MeasurementOf(&quote.Body)
+	if quote.Body.Debug() {
+		return measurement, fmt.Errorf("%w: %s is a debug TD", tdx.ErrMeasurementNotAllowed, measurement)
+	}
+	if !measurement.AllowedBy(allowlist) {
+		return measurement, fmt.Errorf("%w: %s", tdx.ErrMeasurementNotAllowed, measurement)
+	}
+
+	return measurement, nil
+}
+
+// Seal encrypts secrets to the X25519 key of an offer.
+func Seal(recipientPublicKey []byte, secrets *Secrets) (*Package, error) {
+	recipient, err := ecdh.X25519().NewPublicKey(recipientPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOffer, err)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	gcm, err := newCipher(ephemeral, recipient, ephemeral.PublicKey().Bytes(), recipientPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to create nonce: %w", err)
+	}
+
+	return &Package{
+		PublicKey:  ephemeral.PublicKey().Bytes(),
+		Ciphertext: gcm.Seal(nonce, nonce, plaintext, nil),
+	}, nil
+}
+
+func open(recipient *ecdh.PrivateKey, pkg *Package) (*Secrets, error) {
+	sender, err := ecdh.X25519().NewPublicKey(pkg.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+
+	gcm, err := newCipher(recipient, sender, pkg.PublicKey, recipient.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkg.Ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrInvalidPackage)
+	}
+
+	nonce, ciphertext := pkg.Ciphertext[:gcm.NonceSize()], pkg.Ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+
+	var secrets Secrets
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+
+	return &secrets, nil
+}
+
+// newCipher derives the AES-256-GCM key of a package from the X25519 shared secret and both public keys.
+func newCipher(privateKey *ecdh.PrivateKey, publicKey *ecdh.PublicKey, senderPublicKey []byte, recipientPublicKey []byte) (cipher.AEAD, error) {
+	shared, err := privateKey.ECDH(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive shared secret: %w", err)
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(domain))
+	hash.Write(shared)
+	hash.Write(senderPublicKey)
+	hash.Write(recipientPublicKey)
+
+	block, err := aes.NewCipher(hash.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return gcm, nil
+}
@@ -0,0 +1,212 @@
+package migration_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dstack-TEE/dstack/sdk/go/tappd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/migration"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx/tdxtest"
+)
+
+// mockTappdClient quotes report data as a TD with the given body, signed by the test chain.
+type mockTappdClient struct {
+	t          *testing.T
+	chain      *tdxtest.Chain
+	body       tdx.Body
+	reportData []byte
+}
+
+func newMockTappdClient(t *testing.T, chain *tdxtest.Chain, mrTd byte) *mockTappdClient {
+	client := &mockTappdClient{t: t, chain: chain}
+	client.body.MrTd[0] = mrTd
+	return client
+}
+
+func (m *mockTappdClient) TdxQuote(ctx context.Context, reportData []byte) (*tappd.TdxQuoteResponse, error) {
+	m.reportData = reportData
+
+	body := m.body
+	copy(body.ReportData[:], reportData)
+	return &tappd.TdxQuoteResponse{Quote: hex.EncodeToString(m.chain.Quote(m.t, body))}, nil
+}
+
+func (m *mockTappdClient) measurement() tdx.Measurement {
+	return tdx.MeasurementOf(&m.body)
+}
+
+func newTestSecrets(t *testing.T, seed byte) *migration.Secrets {
+	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return migration.NewSecrets(bytes.Repeat([]byte{seed}, 32), rsaPrivateKey)
+}
+
+// sealFrom seals secrets to the offer and quotes the package as the sender enclave.
+func sealFrom(t *testing.T, sender *mockTappdClient, offer *migration.Offer, secrets *migration.Secrets) *migration.Package {
+	pkg, err := migration.Seal(offer.PublicKey, secrets)
+	require.NoError(t, err)
+
+	quote, err := sender.TdxQuote(context.Background(), migration.PackageReportData(pkg.PublicKey, offer.PublicKey))
+	require.NoError(t, err)
+	pkg.Quote = quote.Quote
+
+	return pkg
+}
+
+func TestReceiver_Import(t *testing.T) {
+	chain := tdxtest.NewChain(t, time.Now())
+	receiverTappd := newMockTappdClient(t, chain, 1)
+	senderTappd := newMockTappdClient(t, chain, 2)
+
+	authorizedSeed := bytes.Repeat([]byte{1}, 32)
+	receiver, err := migration.NewReceiver(migration.ReceiverConfig{
+		TappdClient:         receiverTappd,
+		Collateral:          chain.Collateral(),
+		AllowedMeasurements: []tdx.Measurement{senderTappd.measurement()},
+		AuthorizeSecrets: func(ctx context.Context, secrets *migration.Secrets) error {
+			if !bytes.Equal(secrets.AccountPrivateKeySeed, authorizedSeed) {
+				return errors.New("not an authorized signer")
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	offer, err := receiver.Offer(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, migration.OfferReportData(offer.PublicKey), receiverTappd.reportData)
+	assert.Len(t, receiverTappd.reportData, 64)
+
+	secrets := newTestSecrets(t, 1)
+
+	t.Run("unquoted package", func(t *testing.T) {
+		pkg, err := migration.Seal(offer.PublicKey, secrets)
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, receiver.Import(context.Background(), pkg), migration.ErrInvalidPackage)
+	})
+
+	t.Run("quote of another package", func(t *testing.T) {
+		pkg := sealFrom(t, senderTappd, offer, secrets)
+		other := sealFrom(t, senderTappd, offer, secrets)
+		pkg.Quote = other.Quote
+
+		assert.ErrorIs(t, receiver.Import(context.Background(), pkg), migration.ErrInvalidPackage)
+	})
+
+	t.Run("sender not allowlisted", func(t *testing.T) {
+		pkg := sealFrom(t, newMockTappdClient(t, chain, 3), offer, secrets)
+
+		assert.ErrorIs(t, receiver.Import(context.Background(), pkg), tdx.ErrMeasurementNotAllowed)
+	})
+
+	t.Run("debug sender", func(t *testing.T) {
+		debugTappd := newMockTappdClient(t, chain, 2)
+		debugTappd.body.TdAttributes[0] = 1
+		pkg := sealFrom(t, debugTappd, offer, secrets)
+
+		assert.ErrorIs(t, receiver.Import(context.Background(), pkg), tdx.ErrMeasurementNotAllowed)
+	})
+
+	t.Run("tampered package", func(t *testing.T) {
+		pkg := sealFrom(t, senderTappd, offer, secrets)
+		pkg.Ciphertext[len(pkg.Ciphertext)-1] ^= 1
+
+		assert.ErrorIs(t, receiver.Import(context.Background(), pkg), migration.ErrInvalidPackage)
+	})
+
+	t.Run("sealed to another key", func(t *testing.T) {
+		other, err := ecdh.X25519().GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		pkg := sealFrom(t, senderTappd, &migration.Offer{PublicKey: other.PublicKey().Bytes()}, secrets)
+
+		assert.ErrorIs(t, receiver.Import(context.Background(), pkg), migration.ErrInvalidPackage)
+	})
+
+	t.Run("unauthorized secrets", func(t *testing.T) {
+		pkg := sealFrom(t, senderTappd, offer, newTestSecrets(t, 2))
+
+		assert.ErrorIs(t, receiver.Import(context.Background(), pkg), migration.ErrInvalidPackage)
+	})
+
+	t.Run("valid package", func(t *testing.T) {
+		require.NoError(t, receiver.Import(context.Background(), sealFrom(t, senderTappd, offer, secrets)))
+
+		imported, err := receiver.Wait(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, secrets, imported)
+
+		rsaPrivateKey, err := imported.ParseRsaPrivateKey()
+		require.NoError(t, err)
+		assert.NoError(t, rsaPrivateKey.Validate())
+	})
+}
+
+func TestSender_Export(t *testing.T) {
+	chain := tdxtest.NewChain(t, time.Now())
+	receiverTappd := newMockTappdClient(t, chain, 1)
+	senderTappd := newMockTappdClient(t, chain, 2)
+
+	_, err := migration.NewSender(migration.SenderConfig{TappdClient: senderTappd})
+	assert.Error(t, err)
+
+	receiver, err := migration.NewReceiver(migration.ReceiverConfig{
+		TappdClient:         receiverTappd,
+		Collateral:          chain.Collateral(),
+		AllowedMeasurements: []tdx.Measurement{senderTappd.measurement()},
+	})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(receiver.Router())
+	defer server.Close()
+
+	newSender := func(allowed tdx.Measurement) *migration.Sender {
+		sender, err := migration.NewSender(migration.SenderConfig{
+			TappdClient:         senderTappd,
+			Collateral:          chain.Collateral(),
+			AllowedMeasurements: []tdx.Measurement{allowed},
+		})
+		require.NoError(t, err)
+		return sender
+	}
+	secrets := newTestSecrets(t, 1)
+
+	t.Run("unverifiable offer", func(t *testing.T) {
+		sender, err := migration.NewSender(migration.SenderConfig{
+			TappdClient:         senderTappd,
+			Collateral:          tdxtest.NewChain(t, time.Now()).Collateral(),
+			AllowedMeasurements: []tdx.Measurement{receiverTappd.measurement()},
+		})
+		require.NoError(t, err)
+
+		err = sender.Export(context.Background(), server.URL, secrets)
+		assert.ErrorIs(t, err, migration.ErrInvalidOffer)
+	})
+
+	t.Run("target not allowlisted", func(t *testing.T) {
+		err := newSender(senderTappd.measurement()).Export(context.Background(), server.URL, secrets)
+		assert.ErrorIs(t, err, tdx.ErrMeasurementNotAllowed)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		require.NoError(t, newSender(receiverTappd.measurement()).Export(context.Background(), server.URL, secrets))
+
+		imported, err := receiver.Wait(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, secrets, imported)
+	})
+}
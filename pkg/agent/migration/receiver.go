@@ -0,0 +1,210 @@
+package migration
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Dstack-TEE/dstack/sdk/go/tappd"
+	"github.com/gin-gonic/gin"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+)
+
+type TappdClient interface {
+	TdxQuote(ctx context.Context, reportData []byte) (*tappd.TdxQuoteResponse, error)
+}
+
+type ReceiverConfig struct {
+	TappdClient TappdClient
+	HttpClient  *http.Client
+	// Collateral is downloaded from Intel on every import if nil
+	Collateral *tdx.Collateral
+	// AllowedMeasurements are the enclaves secrets are imported from
+	AllowedMeasurements []tdx.Measurement
+	// AuthorizeSecrets rejects secrets that must not be imported, such as a wallet the factory does not trust. Every
+	// package from an allowlisted enclave is imported if nil.
+	AuthorizeSecrets func(ctx context.Context, secrets *Secrets) error
+}
+
+// Receiver is the new agent's side of a migration. It holds an ephemeral key for the lifetime of the process, so an
+// offer can only be redeemed by the enclave that made it.
+type Receiver struct {
+	privateKey          *ecdh.PrivateKey
+	tappdClient         TappdClient
+	httpClient          *http.Client
+	collateral          *tdx.Collateral
+	allowedMeasurements []tdx.Measurement
+	authorizeSecrets    func(ctx context.Context, secrets *Secrets) error
+
+	once    sync.Once
+	secrets chan *Secrets
+}
+
+func NewReceiver(config ReceiverConfig) (*Receiver, error) {
+	if config.TappdClient == nil {
+		return nil, errors.New("tappd client is nil")
+	}
+	if len(config.AllowedMeasurements) == 0 {
+		return nil, errors.New("at least one allowed measurement is required")
+	}
+
+	httpClient := config.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate migration key: %w", err)
+	}
+
+	return &Receiver{
+		privateKey:          privateKey,
+		tappdClient:         config.TappdClient,
+		httpClient:          httpClient,
+		collateral:          config.Collateral,
+		allowedMeasurements: config.AllowedMeasurements,
+		authorizeSecrets:    config.AuthorizeSecrets,
+		secrets:             make(chan *Secrets, 1),
+	}, nil
+}
+
+func (r *Receiver) Offer(ctx context.Context) (*Offer, error) {
+	publicKey := r.privateKey.PublicKey().Bytes()
+
+	quote, err := r.tappdClient.TdxQuote(ctx, OfferReportData(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quote: %w", err)
+	}
+
+	return &Offer{
+		PublicKey: publicKey,
+		Quote:     quote.Quote,
+	}, nil
+}
+
+// Import opens a package sealed to the receiver's offer by an allowlisted enclave. Only the first valid package is
+// handed to Wait.
+func (r *Receiver) Import(ctx context.Context, pkg *Package) error {
+	collateral := r.collateral
+	if collateral == nil {
+		var err error
+		collateral, err = tdx.DownloadCollateral(ctx, r.httpClient)
+		if err != nil {
+			return err
+		}
+	}
+
+	quote, err := verifyBoundQuote(pkg.Quote, PackageReportData(pkg.PublicKey, r.privateKey.PublicKey().Bytes()), collateral, time.Now())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+
+	if _, err := checkMeasurement(quote, r.allowedMeasurements); err != nil {
+		return err
+	}
+
+	secrets, err := open(r.privateKey, pkg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := secrets.ParseRsaPrivateKey(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+	if len(secrets.AccountPrivateKeySeed) == 0 {
+		return fmt.Errorf("%w: empty account private key seed", ErrInvalidPackage)
+	}
+
+	if r.authorizeSecrets != nil {
+		if err := r.authorizeSecrets(ctx, secrets); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+		}
+	}
+
+	r.once.Do(func() {
+		r.secrets <- secrets
+	})
+
+	return nil
+}
+
+// Wait blocks until secrets are imported.
+func (r *Receiver) Wait(ctx context.Context) (*Secrets, error) {
+	select {
+	case secrets := <-r.secrets:
+		return secrets, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *Receiver) Router() *gin.Engine {
+	router := gin.Default()
+
+	router.GET(OfferPath, func(c *gin.Context) {
+		offer, err := r.Offer(c.Request.Context())
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, offer)
+	})
+
+	router.POST(ImportPath, func(c *gin.Context) {
+		var pkg Package
+		if err := c.ShouldBindJSON(&pkg); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err := r.Import(c.Request.Context(), &pkg)
+		switch {
+		case errors.Is(err, tdx.ErrMeasurementNotAllowed):
+			c.String(http.StatusForbidden, err.Error())
+			return
+		case err != nil:
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Status(http.StatusOK)
+	})
+
+	return router
+}
+
+// Receive serves the receiver's endpoints on apiIpPort until secrets are imported.
+func (r *Receiver) Receive(ctx context.Context, apiIpPort string) (*Secrets, error) {
+	server := &http.Server{
+		Addr:    apiIpPort,
+		Handler: r.Router(),
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("waiting for migration", "port", apiIpPort)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case secrets := <-r.secrets:
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Warn("failed to shutdown migration server", "error", err)
+		}
+		return secrets, nil
+	case err := <-serverErr:
+		return nil, fmt.Errorf("failed to serve migration: %w", err)
+	case <-ctx.Done():
+		server.Close()
+		return nil, ctx.Err()
+	}
+}
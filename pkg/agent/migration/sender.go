@@ -0,0 +1,168 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+)
+
+const maxResponseSize = 1 << 20
+
+type SenderConfig struct {
+	HttpClient *http.Client
+	// TappdClient quotes the sender's ephemeral key for the receiver to verify
+	TappdClient TappdClient
+	// Collateral is downloaded from Intel on every export if nil
+	Collateral          *tdx.Collateral
+	AllowedMeasurements []tdx.Measurement
+}
+
+// Sender exports the secrets of the old agent to allowlisted enclaves.
+type Sender struct {
+	httpClient          *http.Client
+	tappdClient         TappdClient
+	collateral          *tdx.Collateral
+	allowedMeasurements []tdx.Measurement
+}
+
+func NewSender(config SenderConfig) (*Sender, error) {
+	if config.TappdClient == nil {
+		return nil, errors.New("tappd client is nil")
+	}
+	if len(config.AllowedMeasurements) == 0 {
+		return nil, errors.New("at least one allowed measurement is required")
+	}
+
+	httpClient := config.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Sender{
+		httpClient:          httpClient,
+		tappdClient:         config.TappdClient,
+		collateral:          config.Collateral,
+		allowedMeasurements: config.AllowedMeasurements,
+	}, nil
+}
+
+// VerifyOffer verifies an offer's quote against the collateral and checks that it commits to the offered key. It does
+// not check the measurements.
+func VerifyOffer(offer *Offer, collateral *tdx.Collateral, now time.Time) (*tdx.Quote, error) {
+	quote, err := verifyBoundQuote(offer.Quote, OfferReportData(offer.PublicKey), collateral, now)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOffer, err)
+	}
+
+	return quote, nil
+}
+
+// Export seals secrets to the offer of the agent at targetUrl and sends them together with a quote of the sender, once
+// the offer is verified and the target's measurement is allowlisted.
+func (s *Sender) Export(ctx context.Context, targetUrl string, secrets *Secrets) error {
+	targetUrl = strings.TrimSuffix(targetUrl, "/")
+
+	offer, err := s.fetchOffer(ctx, targetUrl)
+	if err != nil {
+		return err
+	}
+
+	collateral := s.collateral
+	if collateral == nil {
+		collateral, err = tdx.DownloadCollateral(ctx, s.httpClient)
+		if err != nil {
+			return err
+		}
+	}
+
+	quote, err := VerifyOffer(offer, collateral, time.Now())
+	if err != nil {
+		return err
+	}
+
+	measurement, err := checkMeasurement(quote, s.allowedMeasurements)
+	if err != nil {
+		return err
+	}
+
+	pkg, err := Seal(offer.PublicKey, secrets)
+	if err != nil {
+		return err
+	}
+
+	senderQuote, err := s.tappdClient.TdxQuote(ctx, PackageReportData(pkg.PublicKey, offer.PublicKey))
+	if err != nil {
+		return fmt.Errorf("failed to get quote: %w", err)
+	}
+	pkg.Quote = senderQuote.Quote
+
+	if err := s.sendPackage(ctx, targetUrl, pkg); err != nil {
+		return err
+	}
+
+	slog.Info("exported secrets", "target", targetUrl, "measurement", measurement.String())
+
+	return nil
+}
+
+func (s *Sender) AllowedMeasurements() []tdx.Measurement {
+	return s.allowedMeasurements
+}
+
+func (s *Sender) fetchOffer(ctx context.Context, targetUrl string) (*Offer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetUrl+OfferPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch offer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch offer: unexpected status code %d", resp.StatusCode)
+	}
+
+	var offer Offer
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&offer); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOffer, err)
+	}
+
+	return &offer, nil
+}
+
+func (s *Sender) sendPackage(ctx context.Context, targetUrl string, pkg *Package) error {
+	body, err := json.Marshal(pkg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal package: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetUrl+ImportPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDeliveryUnknown, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to send package: unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package nft
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	displayTypeNumber = "number"
+	displayTypeDate   = "date"
+)
+
+// Metadata is an ERC-721 metadata document, extended with the attributes and optional fields marketplaces display.
+type Metadata struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Image is the uri of the image, e.g. ipfs://<cid>
+	Image string `json:"image"`
+	// ImageData is raw SVG image data, for images that are not stored separately
+	ImageData   string      `json:"image_data,omitempty"`
+	ExternalUrl string      `json:"external_url,omitempty"`
+	Attributes  []Attribute `json:"attributes"`
+}
+
+type Attribute struct {
+	TraitType   string      `json:"trait_type"`
+	Value       interface{} `json:"value"`
+	DisplayType string      `json:"display_type,omitempty"`
+}
+
+// Token describes a minted prompt auction, from which its metadata is built.
+type Token struct {
+	CollectionName    string
+	CollectionAddress common.Address
+	AuctionId         uint64
+	Prompt            string
+	WinningBid        *big.Int
+	Winner            common.Address
+	// Generator is the art generator backend and Model the model it used, if known
+	Generator   string
+	Model       string
+	GeneratedAt time.Time
+	// ImageUri is the uri of the uploaded image
+	ImageUri    string
+	ExternalUrl string
+}
+
+// NewMetadata builds the metadata of a token. Attributes that are unknown, such as the generation details of jobs
+// started before they were recorded, are left out.
+func NewMetadata(token Token) *Metadata {
+	attributes := []Attribute{
+		{TraitType: "Collection", Value: token.CollectionAddress.Hex()},
+		{TraitType: "Auction ID", Value: token.AuctionId, DisplayType: displayTypeNumber},
+	}
+	if token.WinningBid != nil {
+		// bids in wei exceed the precision of JSON numbers
+		attributes = append(attributes, Attribute{TraitType: "Winning Bid", Value: token.WinningBid.String()})
+	}
+	attributes = append(attributes, Attribute{TraitType: "Winner", Value: token.Winner.Hex()})
+	if token.Generator != "" {
+		attributes = append(attributes, Attribute{TraitType: "Generator", Value: token.Generator})
+	}
+	if token.Model != "" {
+		attributes = append(attributes, Attribute{TraitType: "Model", Value: token.Model})
+	}
+	if !token.GeneratedAt.IsZero() {
+		attributes = append(attributes, Attribute{TraitType: "Generated At", Value: token.GeneratedAt.Unix(), DisplayType: displayTypeDate})
+	}
+
+	return &Metadata{
+		Name:        token.CollectionName,
+		Description: token.Prompt,
+		Image:       token.ImageUri,
+		ExternalUrl: token.ExternalUrl,
+		Attributes:  attributes,
+	}
+}
@@ -0,0 +1,63 @@
+package nft_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/nft"
+)
+
+func TestNewMetadata(t *testing.T) {
+	metadata := nft.NewMetadata(nft.Token{
+		CollectionName:    "Garden",
+		CollectionAddress: common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		AuctionId:         7,
+		Prompt:            "a watercolor garden",
+		WinningBid:        new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil),
+		Winner:            common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		Generator:         "openai",
+		Model:             "dall-e-3",
+		GeneratedAt:       time.Unix(1700000000, 0),
+		ImageUri:          "ipfs://bafkreiimage",
+	})
+
+	data, err := json.Marshal(metadata)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "Garden",
+		"description": "a watercolor garden",
+		"image": "ipfs://bafkreiimage",
+		"attributes": [
+			{"trait_type": "Collection", "value": "0x1111111111111111111111111111111111111111"},
+			{"trait_type": "Auction ID", "value": 7, "display_type": "number"},
+			{"trait_type": "Winning Bid", "value": "100000000000000000000"},
+			{"trait_type": "Winner", "value": "0x2222222222222222222222222222222222222222"},
+			{"trait_type": "Generator", "value": "openai"},
+			{"trait_type": "Model", "value": "dall-e-3"},
+			{"trait_type": "Generated At", "value": 1700000000, "display_type": "date"}
+		]
+	}`, string(data))
+}
+
+func TestNewMetadata_Optional(t *testing.T) {
+	metadata := nft.NewMetadata(nft.Token{
+		CollectionName: "Garden",
+		ImageUri:       "ipfs://bafkreiimage",
+		ExternalUrl:    "https://example.com/garden/7",
+	})
+	assert.Len(t, metadata.Attributes, 3)
+
+	data, err := json.Marshal(metadata)
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, "https://example.com/garden/7", fields["external_url"])
+	assert.NotContains(t, fields, "image_data")
+}
@@ -3,6 +3,7 @@ package nft
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
 )
@@ -17,20 +18,37 @@ func NewNftUploader(uploader filestorage.Uploader) *NftUploader {
 	}
 }
 
-func (u *NftUploader) UploadUrl(ctx context.Context, name, description, imageUri string) (string, error) {
-	imageIpfsHash, err := u.uploader.UploadUrl(ctx, imageUri)
+// UploadImage uploads an image and returns its uri.
+func (u *NftUploader) UploadImage(ctx context.Context, image []byte) (string, error) {
+	imageReference, err := u.uploader.UploadFile(ctx, "image"+imageExtension(image), image)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to ipfs: %v", err)
 	}
 
-	metadataIpfsHash, err := u.uploader.UploadJson(ctx, map[string]string{
-		"name":        name,
-		"description": description,
-		"image":       imageIpfsHash,
-	})
+	return filestorage.Uri(imageReference), nil
+}
+
+// UploadMetadata uploads the metadata of a token and returns its uri, which is the token uri.
+func (u *NftUploader) UploadMetadata(ctx context.Context, metadata *Metadata) (string, error) {
+	metadataReference, err := u.uploader.UploadJson(ctx, metadata)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to ipfs: %v", err)
 	}
 
-	return metadataIpfsHash, nil
+	return filestorage.Uri(metadataReference), nil
+}
+
+func imageExtension(image []byte) string {
+	switch http.DetectContentType(image) {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ""
+	}
 }
@@ -0,0 +1,256 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/art"
+)
+
+const (
+	previewMaxPromptLength = 1000
+	// previewCooldown is the minimum time between two previews of the same creator
+	previewCooldown = 10 * time.Minute
+	// previewBurst and previewRefillInterval bound the previews of all creators together, as creator addresses are
+	// free to create
+	previewBurst          = 10
+	previewRefillInterval = 1 * time.Minute
+	// previewMaxTracked bounds the previewed system prompts and the creators in cooldown that are remembered
+	previewMaxTracked = 10000
+)
+
+var (
+	ErrInvalidPreviewRequest = errors.New("invalid preview request")
+	// ErrPreviewNotAllowed is returned when the preview request is not signed by the system prompt's creator.
+	ErrPreviewNotAllowed  = errors.New("preview not allowed")
+	ErrPreviewRateLimited = errors.New("preview rate limited")
+)
+
+// previewUriSchemes are the system prompt uris previews are generated for. Content-addressed uris keep the agent from
+// fetching arbitrary urls on behalf of callers.
+var previewUriSchemes = []string{"ipfs://", "ar://"}
+
+type PreviewRequest struct {
+	SystemPromptUri string `json:"systemPromptUri"`
+	Prompt          string `json:"prompt"`
+	// Signature is the creator's personal_sign signature of PreviewMessage
+	Signature string `json:"signature"`
+	// Pin uploads the preview and returns its uri instead of the image
+	Pin bool `json:"pin"`
+}
+
+type Preview struct {
+	Image *art.Image
+	Uri   string
+}
+
+// PreviewMessage is the text a creator signs with their wallet to request a preview.
+func PreviewMessage(systemPromptUri string, prompt string) string {
+	return fmt.Sprintf("Yayoi Garden preview\nSystem prompt: %s\nPrompt: %s", systemPromptUri, prompt)
+}
+
+// previewLimiter allows a single preview per system prompt, spaces the previews of each creator and bounds the
+// previews of all creators with a token bucket. Signers are reserved before the system prompt is fetched, while the
+// prompt is only claimed once its creator is verified, so that other signers cannot use up its preview. The state is
+// kept in memory only, and the oldest previewed prompts are forgotten once previewMaxTracked are remembered.
+type previewLimiter struct {
+	mu       sync.Mutex
+	prompts  map[string]bool
+	order    []string
+	creators map[common.Address]time.Time
+	clock    AgentClock
+	cooldown time.Duration
+	bucket   *tokenBucket
+}
+
+func newPreviewLimiter(clock AgentClock, cooldown time.Duration) *previewLimiter {
+	return &previewLimiter{
+		prompts:  make(map[string]bool),
+		creators: make(map[common.Address]time.Time),
+		clock:    clock,
+		cooldown: cooldown,
+		bucket:   newTokenBucket(clock, previewBurst, previewRefillInterval),
+	}
+}
+
+// reserve takes the signer's cooldown and a token of the bucket. They are not given back when the system prompt fails to
+// load or the signer turns out not to be its creator.
+func (l *previewLimiter) reserve(signer common.Address, systemPromptUri string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.prompts[systemPromptUri] {
+		return fmt.Errorf("%w: system prompt already previewed", ErrPreviewRateLimited)
+	}
+
+	now := l.clock.Now()
+	if last, ok := l.creators[signer]; ok && now.Sub(last) < l.cooldown {
+		return fmt.Errorf("%w: next preview allowed at %s", ErrPreviewRateLimited, last.Add(l.cooldown).Format(time.RFC3339))
+	}
+
+	if len(l.creators) >= previewMaxTracked {
+		for address, last := range l.creators {
+			if now.Sub(last) >= l.cooldown {
+				delete(l.creators, address)
+			}
+		}
+		if len(l.creators) >= previewMaxTracked {
+			return fmt.Errorf("%w: too many creators, try again later", ErrPreviewRateLimited)
+		}
+	}
+
+	if !l.bucket.take() {
+		return fmt.Errorf("%w: too many previews, try again later", ErrPreviewRateLimited)
+	}
+
+	l.creators[signer] = now
+
+	return nil
+}
+
+// claim marks the system prompt as previewed once its creator is verified.
+func (l *previewLimiter) claim(systemPromptUri string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.prompts[systemPromptUri] {
+		return fmt.Errorf("%w: system prompt already previewed", ErrPreviewRateLimited)
+	}
+
+	if len(l.order) >= previewMaxTracked {
+		delete(l.prompts, l.order[0])
+		l.order = l.order[1:]
+	}
+	l.prompts[systemPromptUri] = true
+	l.order = append(l.order, systemPromptUri)
+
+	return nil
+}
+
+// release gives back a reservation whose preview failed to generate.
+func (l *previewLimiter) release(creator common.Address, systemPromptUri string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.prompts[systemPromptUri] {
+		delete(l.prompts, systemPromptUri)
+		for i, uri := range l.order {
+			if uri == systemPromptUri {
+				l.order = append(l.order[:i], l.order[i+1:]...)
+				break
+			}
+		}
+	}
+	delete(l.creators, creator)
+	l.bucket.giveBack()
+}
+
+// GeneratePreview generates a preview image for the creator of a system prompt. The system prompt is decrypted and
+// used inside the enclave only; the caller gets the image or its pinned uri.
+func (a *Agent) GeneratePreview(ctx context.Context, req PreviewRequest) (*Preview, error) {
+	if req.Prompt == "" || len(req.Prompt) > previewMaxPromptLength {
+		return nil, fmt.Errorf("%w: prompt must be between 1 and %d bytes", ErrInvalidPreviewRequest, previewMaxPromptLength)
+	}
+
+	if !hasPreviewUriScheme(req.SystemPromptUri) {
+		return nil, fmt.Errorf("%w: system prompt uri must be one of %s", ErrInvalidPreviewRequest, strings.Join(previewUriSchemes, ", "))
+	}
+
+	signer, err := recoverPersonalSigner(PreviewMessage(req.SystemPromptUri, req.Prompt), req.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPreviewNotAllowed, err)
+	}
+
+	if err := a.previewLimiter.reserve(signer, req.SystemPromptUri); err != nil {
+		return nil, err
+	}
+
+	collectionPrompt, err := a.loadCollectionPrompt(ctx, req.SystemPromptUri, a.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if !common.IsHexAddress(collectionPrompt.Creator) || common.HexToAddress(collectionPrompt.Creator) != signer {
+		return nil, fmt.Errorf("%w: signer is not the system prompt creator", ErrPreviewNotAllowed)
+	}
+
+	if err := a.previewLimiter.claim(req.SystemPromptUri); err != nil {
+		return nil, err
+	}
+
+	preview, err := a.generatePreview(ctx, collectionPrompt, req)
+	if err != nil {
+		a.previewLimiter.release(signer, req.SystemPromptUri)
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+func (a *Agent) generatePreview(ctx context.Context, collectionPrompt *art.CollectionPrompt, req PreviewRequest) (*Preview, error) {
+	image, err := a.artGenerator.Generate(ctx, art.GenerationRequest{
+		SystemPrompt: collectionPrompt.SystemPrompt,
+		Prompt:       req.Prompt,
+		Params:       collectionPrompt.Params,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate preview: %w", err)
+	}
+
+	if !req.Pin {
+		return &Preview{Image: image}, nil
+	}
+
+	imageUri, err := a.nftUploader.UploadImage(ctx, image.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload preview: %w", err)
+	}
+
+	return &Preview{Uri: imageUri}, nil
+}
+
+// recoverPersonalSigner returns the address whose personal_sign signature of message is signature.
+func recoverPersonalSigner(message string, hexSignature string) (common.Address, error) {
+	signature := common.FromHex(hexSignature)
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, errors.New("invalid signature length")
+	}
+
+	// wallets produce a recovery id of 27 or 28
+	signature = append([]byte(nil), signature...)
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := crypto.SigToPub(accounts.TextHash([]byte(message)), signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// validateCreator checks the optional creator of an uploaded system prompt.
+func validateCreator(creator string) error {
+	if creator != "" && !common.IsHexAddress(creator) {
+		return fmt.Errorf("%w: invalid creator address %q", ErrInvalidSystemPrompt, creator)
+	}
+	return nil
+}
+
+func hasPreviewUriScheme(uri string) bool {
+	for _, scheme := range previewUriSchemes {
+		if strings.HasPrefix(uri, scheme) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/art"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/ipfs"
+	"github.com/NethermindEth/yayois-garden/pkg/envelope"
+)
+
+const (
+	systemPromptFileName = "system_prompt"
+	schemeIpfs           = "ipfs://"
+	// UploadSignatureHeader carries the creator's personal_sign signature of UploadMessage
+	UploadSignatureHeader = "X-Creator-Signature"
+	// promptUploadBurst and promptUploadRefillInterval bound the uploads of all creators together, as each upload is
+	// decrypted and pinned by the agent
+	promptUploadBurst          = 10
+	promptUploadRefillInterval = 1 * time.Minute
+)
+
+var (
+	// ErrSystemPromptTooLarge is returned when an uploaded system prompt does not fit the size limit.
+	ErrSystemPromptTooLarge = errors.New("system prompt too large")
+	// ErrUploadNotAllowed is returned when the upload is not signed by the system prompt's creator.
+	ErrUploadNotAllowed  = errors.New("upload not allowed")
+	ErrUploadRateLimited = errors.New("upload rate limited")
+)
+
+// UploadMessage is the text a creator signs with their wallet to upload a sealed system prompt.
+func UploadMessage(sealed []byte) string {
+	hash := sha256.Sum256(sealed)
+	return fmt.Sprintf("Yayoi Garden system prompt upload\nSHA-256: %s", hex.EncodeToString(hash[:]))
+}
+
+type UploadedSystemPrompt struct {
+	Uri string `json:"uri"`
+	// Cid is set for uploads to IPFS
+	Cid string `json:"cid,omitempty"`
+}
+
+// UploadSystemPrompt pins a system prompt sealed to the agent's public key and returns the uri to create the collection
+// with. The prompt is checked the same way it will be when the collection's auctions end, so a collection created
+// with the returned uri is not marked unusable. Only the sealed envelope leaves the enclave. The upload must be signed
+// by the prompt's creator, and uploads of all creators are rate limited before anything is decrypted.
+func (a *Agent) UploadSystemPrompt(ctx context.Context, sealed []byte, signature string) (*UploadedSystemPrompt, error) {
+	if len(sealed) >= systemPromptMaxSize {
+		return nil, fmt.Errorf("%w: size %d exceeds the limit of %d bytes", ErrSystemPromptTooLarge, len(sealed), systemPromptMaxSize)
+	}
+	if !envelope.IsEnvelope(sealed) {
+		return nil, fmt.Errorf("%w: not sealed in an envelope", ErrInvalidSystemPrompt)
+	}
+
+	signer, err := recoverPersonalSigner(UploadMessage(sealed), signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUploadNotAllowed, err)
+	}
+
+	if !a.promptUploadBucket.take() {
+		return nil, fmt.Errorf("%w: too many uploads, try again later", ErrUploadRateLimited)
+	}
+
+	systemPrompt, err := envelope.Open(a.rsaPrivateKey, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decrypt: %w", ErrInvalidSystemPrompt, err)
+	}
+
+	collectionPrompt, err := art.ParseCollectionPrompt(string(systemPrompt))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSystemPrompt, err)
+	}
+	if strings.TrimSpace(collectionPrompt.SystemPrompt) == "" {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidSystemPrompt)
+	}
+	if err := validateCreator(collectionPrompt.Creator); err != nil {
+		return nil, err
+	}
+	if collectionPrompt.Creator == "" || common.HexToAddress(collectionPrompt.Creator) != signer {
+		return nil, fmt.Errorf("%w: signer is not the system prompt creator", ErrUploadNotAllowed)
+	}
+
+	reference, err := a.uploader.UploadFile(ctx, systemPromptFileName, sealed)
+	if err != nil {
+		a.promptUploadBucket.giveBack()
+		return nil, fmt.Errorf("failed to upload system prompt: %w", err)
+	}
+
+	uploaded := &UploadedSystemPrompt{Uri: filestorage.Uri(reference)}
+	if err := a.verifyUploadedSystemPrompt(ctx, uploaded, sealed); err != nil {
+		return nil, fmt.Errorf("failed to verify uploaded system prompt %s: %w", uploaded.Uri, err)
+	}
+
+	return uploaded, nil
+}
+
+// verifyUploadedSystemPrompt checks that the uri resolves to the sealed prompt the way it will when the collection's
+// auctions end. IPFS cids are verified locally, other uris are fetched back.
+func (a *Agent) verifyUploadedSystemPrompt(ctx context.Context, uploaded *UploadedSystemPrompt, sealed []byte) error {
+	if rawCid, ok := strings.CutPrefix(uploaded.Uri, schemeIpfs); ok {
+		cid, err := ipfs.ParseCid(rawCid)
+		if err != nil {
+			return fmt.Errorf("failed to parse uploaded cid: %w", err)
+		}
+		if err := cid.Verify(sealed); err != nil {
+			return err
+		}
+
+		uploaded.Cid = rawCid
+		return nil
+	}
+
+	content, err := a.fetcher.Fetch(ctx, uploaded.Uri)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(content, sealed) {
+		return errors.New("uri serves different content")
+	}
+
+	return nil
+}
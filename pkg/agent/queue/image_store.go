@@ -0,0 +1,87 @@
+package queue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/sealing"
+)
+
+// ImageStore keeps generated images apart from the job queue until they are pinned, keyed by their hex encoded
+// SHA-256, so that checkpointing a job does not rewrite its image.
+type ImageStore interface {
+	Load(ctx context.Context, imageHash string) ([]byte, error)
+	Save(ctx context.Context, imageHash string, image []byte) error
+	Delete(ctx context.Context, imageHash string) error
+}
+
+type FileImageStore struct {
+	dstackTappdEndpoint string
+	dirPath             string
+}
+
+var _ ImageStore = (*FileImageStore)(nil)
+
+func NewFileImageStore(dstackTappdEndpoint string, dirPath string) *FileImageStore {
+	return &FileImageStore{
+		dstackTappdEndpoint: dstackTappdEndpoint,
+		dirPath:             dirPath,
+	}
+}
+
+func (s *FileImageStore) Load(ctx context.Context, imageHash string) ([]byte, error) {
+	filePath, err := s.filePath(imageHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	image, err := sealing.ReadSealedFile(ctx, s.dstackTappdEndpoint, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %v", err)
+	}
+
+	return image, nil
+}
+
+func (s *FileImageStore) Save(ctx context.Context, imageHash string, image []byte) error {
+	filePath, err := s.filePath(imageHash)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dirPath, 0700); err != nil {
+		return fmt.Errorf("failed to create image directory: %v", err)
+	}
+
+	return sealing.WriteSealedFile(ctx, s.dstackTappdEndpoint, filePath, image)
+}
+
+func (s *FileImageStore) Delete(ctx context.Context, imageHash string) error {
+	filePath, err := s.filePath(imageHash)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete image: %v", err)
+	}
+
+	return nil
+}
+
+func (s *FileImageStore) filePath(imageHash string) (string, error) {
+	if hash, err := hex.DecodeString(imageHash); err != nil || len(hash) != sha256.Size {
+		return "", fmt.Errorf("invalid image hash %q", imageHash)
+	}
+
+	return filepath.Join(s.dirPath, imageHash), nil
+}
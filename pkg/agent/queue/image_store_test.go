@@ -0,0 +1,59 @@
+package queue_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/debug"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/queue"
+)
+
+func newTestImageStore(t *testing.T) (*queue.FileImageStore, string) {
+	t.Setenv(debug.DebugPlainSetupKey, "true")
+
+	dirPath := filepath.Join(t.TempDir(), "images")
+	return queue.NewFileImageStore("", dirPath), dirPath
+}
+
+func imageHash(image []byte) string {
+	hash := sha256.Sum256(image)
+	return hex.EncodeToString(hash[:])
+}
+
+func TestFileImageStore_SaveLoadDelete(t *testing.T) {
+	ctx := context.Background()
+	store, dirPath := newTestImageStore(t)
+	image := []byte("generated image")
+	hash := imageHash(image)
+
+	missing, err := store.Load(ctx, hash)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	require.NoError(t, store.Save(ctx, hash, image))
+	assert.FileExists(t, filepath.Join(dirPath, hash))
+
+	loaded, err := store.Load(ctx, hash)
+	require.NoError(t, err)
+	assert.Equal(t, image, loaded)
+
+	require.NoError(t, store.Delete(ctx, hash))
+	_, err = os.Stat(filepath.Join(dirPath, hash))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.NoError(t, store.Delete(ctx, hash), "deleting a missing image is not an error")
+}
+
+func TestFileImageStore_RejectsInvalidHash(t *testing.T) {
+	store, _ := newTestImageStore(t)
+
+	assert.Error(t, store.Save(context.Background(), "../job_queue", []byte("image")))
+	_, err := store.Load(context.Background(), "not-a-hash")
+	assert.Error(t, err)
+}
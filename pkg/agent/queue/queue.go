@@ -0,0 +1,495 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alitto/pond/v2"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/indexer"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusDead    Status = "dead"
+)
+
+// Artifacts are the outputs of the finalization stages completed so far. They are persisted with the job so that a
+// retry resumes from the last finished stage instead of regenerating the artwork.
+type Artifacts struct {
+	// ImageHash is the hex encoded SHA-256 of the generated image, which is kept by Queue.SaveImage until it is pinned
+	ImageHash string
+	// Generator, Model and GeneratedAt describe the generation for the token metadata
+	Generator   string
+	Model       string
+	GeneratedAt time.Time
+	// ImageCid holds the uri of the uploaded image. Jobs queued by earlier versions hold a bare cid.
+	ImageCid string
+	// TokenUri is the uri of the uploaded metadata, which is signed and submitted as the token's uri
+	TokenUri     string
+	Signature    []byte
+	TxHash       common.Hash
+	ReceiptBlock uint64
+
+	// legacyImage is the image of a job persisted by an earlier version, which is moved to the image store on load
+	legacyImage []byte
+}
+
+// UnmarshalJSON converts the artifacts of jobs persisted by earlier versions, which held the metadata reference as
+// MetadataCid and the unpinned image itself. A bare cid becomes an ipfs uri unless it has already been signed, since
+// the signed value is what gets submitted. Saving the job stores the TokenUri, so the conversion happens once.
+func (a *Artifacts) UnmarshalJSON(data []byte) error {
+	type artifacts Artifacts
+	var decoded struct {
+		artifacts
+		MetadataCid string
+		Image       []byte
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*a = Artifacts(decoded.artifacts)
+	a.legacyImage = decoded.Image
+	if a.TokenUri == "" && decoded.MetadataCid != "" {
+		a.TokenUri = decoded.MetadataCid
+		if len(a.Signature) == 0 {
+			a.TokenUri = filestorage.Uri(decoded.MetadataCid)
+		}
+	}
+
+	return nil
+}
+
+type Job struct {
+	Id         string
+	AuctionEnd indexer.AuctionEnd
+	Artifacts  Artifacts
+
+	Status        Status
+	Stage         string
+	Attempts      int
+	TotalAttempts int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func JobId(auctionEnd indexer.AuctionEnd) string {
+	return fmt.Sprintf("%s-%d", auctionEnd.CollectionAddress.Hex(), auctionEnd.AuctionId)
+}
+
+// Handler runs a job to completion. It owns the job for the duration of the call and records progress by updating
+// job.Stage and job.Artifacts; attempts are counted per stage, so a failure after moving to a new stage starts a fresh
+// retry budget. Progress is persisted when the handler returns, or earlier through Queue.Checkpoint.
+type Handler func(ctx context.Context, job *Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error that retrying cannot fix, so the job is moved to the dead letter state right away.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var permanentErr *permanentError
+	return errors.As(err, &permanentErr)
+}
+
+type QueueClock interface {
+	Now() time.Time
+}
+
+type defaultQueueClock struct{}
+
+func (defaultQueueClock) Now() time.Time {
+	return time.Now()
+}
+
+type QueueConfig struct {
+	Store        Store
+	Handler      Handler
+	Workers      int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	Retention    time.Duration
+	Clock        QueueClock
+	ImageStore   ImageStore
+}
+
+type Queue struct {
+	mu     sync.Mutex
+	jobs   map[string]*Job
+	saveMu sync.Mutex
+
+	store      Store
+	imageStore ImageStore
+	// images holds the unpinned images when there is no image store
+	images  map[string][]byte
+	handler Handler
+	pool    pond.Pool
+	wake    chan struct{}
+
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	retention    time.Duration
+	clock        QueueClock
+}
+
+func NewQueue(config QueueConfig) (*Queue, error) {
+	if config.Handler == nil {
+		return nil, errors.New("handler is nil")
+	}
+	if config.Workers <= 0 {
+		return nil, errors.New("workers must be positive")
+	}
+	if config.MaxAttempts <= 0 {
+		return nil, errors.New("max attempts must be positive")
+	}
+
+	clock := config.Clock
+	if clock == nil {
+		clock = defaultQueueClock{}
+	}
+
+	return &Queue{
+		jobs: make(map[string]*Job),
+
+		store:      config.Store,
+		imageStore: config.ImageStore,
+		images:     make(map[string][]byte),
+		handler:    config.Handler,
+		pool:       pond.NewPool(config.Workers),
+		wake:       make(chan struct{}, 1),
+
+		maxAttempts:  config.MaxAttempts,
+		baseBackoff:  config.BaseBackoff,
+		maxBackoff:   config.MaxBackoff,
+		pollInterval: config.PollInterval,
+		retention:    config.Retention,
+		clock:        clock,
+	}, nil
+}
+
+func (q *Queue) Load(ctx context.Context) error {
+	if q.store == nil {
+		return nil
+	}
+
+	jobs, err := q.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrated := false
+	for _, job := range jobs {
+		if job.Artifacts.legacyImage == nil {
+			continue
+		}
+
+		if job.Artifacts.ImageCid == "" {
+			// an image that cannot be moved is generated again
+			if err := q.SaveImage(ctx, job.Artifacts.ImageHash, job.Artifacts.legacyImage); err != nil {
+				slog.Error("failed to move image of job", "job", job.Id, "error", err)
+			}
+		}
+		job.Artifacts.legacyImage = nil
+		migrated = true
+	}
+
+	q.mu.Lock()
+	for _, job := range jobs {
+		if job.Status == StatusRunning {
+			job.Status = StatusPending
+		}
+		q.jobs[job.Id] = job
+	}
+	q.mu.Unlock()
+
+	slog.Info("loaded job queue", "jobs", len(jobs))
+
+	if migrated {
+		return q.save(ctx)
+	}
+
+	return nil
+}
+
+// Enqueue adds a job for the auction unless one already exists. A dead job for the auction is requeued with a fresh
+// retry budget instead, keeping the artifacts it checkpointed, since the auction is still waiting to be finished. It
+// reports whether a job was created or requeued.
+func (q *Queue) Enqueue(ctx context.Context, auctionEnd indexer.AuctionEnd) (bool, error) {
+	id := JobId(auctionEnd)
+	now := q.clock.Now()
+
+	q.mu.Lock()
+	if job, ok := q.jobs[id]; ok {
+		if job.Status != StatusDead {
+			q.mu.Unlock()
+			return false, nil
+		}
+
+		job.Status = StatusPending
+		job.Attempts = 0
+		job.NextAttemptAt = now
+		job.UpdatedAt = now
+		stage := job.Stage
+		q.mu.Unlock()
+
+		slog.Info("requeued dead job", "job", id, "stage", stage)
+	} else {
+		q.jobs[id] = &Job{
+			Id:            id,
+			AuctionEnd:    auctionEnd,
+			Status:        StatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		q.mu.Unlock()
+
+		slog.Info("enqueued job", "job", id)
+	}
+
+	if err := q.save(ctx); err != nil {
+		return true, err
+	}
+
+	q.notify()
+	return true, nil
+}
+
+// Checkpoint persists the progress recorded on a running job.
+func (q *Queue) Checkpoint(ctx context.Context, job *Job) error {
+	q.mu.Lock()
+	jobCopy := *job
+	jobCopy.UpdatedAt = q.clock.Now()
+	q.jobs[job.Id] = &jobCopy
+	q.mu.Unlock()
+
+	return q.save(ctx)
+}
+
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+
+	return jobs
+}
+
+func (q *Queue) Start(ctx context.Context) {
+	go q.run(ctx)
+}
+
+func (q *Queue) run(ctx context.Context) {
+	slog.Info("starting job queue")
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.dispatch(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		case <-ctx.Done():
+			slog.Info("job queue stopping")
+			return
+		}
+	}
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) dispatch(ctx context.Context) {
+	now := q.clock.Now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, job := range q.jobs {
+		if job.Status != StatusPending || job.NextAttemptAt.After(now) {
+			continue
+		}
+
+		job.Status = StatusRunning
+		jobCopy := *job
+		q.pool.Submit(func() {
+			q.process(ctx, &jobCopy)
+		})
+	}
+}
+
+func (q *Queue) process(ctx context.Context, job *Job) {
+	slog.Info("processing job", "job", job.Id, "stage", job.Stage, "attempts", job.Attempts)
+
+	previousStage := job.Stage
+	err := q.handler(ctx, job)
+	now := q.clock.Now()
+
+	job.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		job.TotalAttempts++
+		job.Status = StatusDone
+		job.LastError = ""
+		slog.Info("job done", "job", job.Id)
+	case ctx.Err() != nil:
+		job.Status = StatusPending
+	default:
+		if job.Stage != previousStage {
+			job.Attempts = 0
+		}
+		job.Attempts++
+		job.TotalAttempts++
+		job.LastError = err.Error()
+
+		if job.Attempts >= q.maxAttempts || IsPermanent(err) {
+			job.Status = StatusDead
+			slog.Error("job failed permanently", "job", job.Id, "stage", job.Stage, "attempts", job.Attempts, "error", err)
+		} else {
+			job.Status = StatusPending
+			job.NextAttemptAt = now.Add(q.backoff(job.Attempts))
+			slog.Warn("job failed, retrying", "job", job.Id, "stage", job.Stage, "attempts", job.Attempts, "nextAttemptAt", job.NextAttemptAt, "error", err)
+		}
+	}
+
+	q.mu.Lock()
+	q.jobs[job.Id] = job
+	pruned := q.pruneLocked(now)
+	q.mu.Unlock()
+
+	if err := q.save(context.WithoutCancel(ctx)); err != nil {
+		slog.Error("failed to save job queue", "error", err)
+	}
+
+	for _, prunedJob := range pruned {
+		if prunedJob.Artifacts.ImageHash == "" {
+			continue
+		}
+		if err := q.DeleteImage(context.WithoutCancel(ctx), prunedJob.Artifacts.ImageHash); err != nil {
+			slog.Error("failed to delete image of pruned job", "job", prunedJob.Id, "error", err)
+		}
+	}
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	backoff := q.baseBackoff
+	for i := 1; i < attempts && backoff < q.maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, q.maxBackoff)
+}
+
+func (q *Queue) pruneLocked(now time.Time) []*Job {
+	if q.retention <= 0 {
+		return nil
+	}
+
+	var pruned []*Job
+	for id, job := range q.jobs {
+		if (job.Status == StatusDone || job.Status == StatusDead) && now.Sub(job.UpdatedAt) > q.retention {
+			delete(q.jobs, id)
+			pruned = append(pruned, job)
+		}
+	}
+
+	return pruned
+}
+
+// SaveImage keeps a generated image until it is pinned. Without an image store the image is kept in memory.
+func (q *Queue) SaveImage(ctx context.Context, imageHash string, image []byte) error {
+	if q.imageStore == nil {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		q.images[imageHash] = image
+		return nil
+	}
+
+	return q.imageStore.Save(ctx, imageHash, image)
+}
+
+// LoadImage returns the image saved under the hash, or nil if there is none.
+func (q *Queue) LoadImage(ctx context.Context, imageHash string) ([]byte, error) {
+	if q.imageStore == nil {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		return q.images[imageHash], nil
+	}
+
+	return q.imageStore.Load(ctx, imageHash)
+}
+
+// DeleteImage drops the image saved under the hash once it is pinned or its job is pruned.
+func (q *Queue) DeleteImage(ctx context.Context, imageHash string) error {
+	if q.imageStore == nil {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		delete(q.images, imageHash)
+		return nil
+	}
+
+	return q.imageStore.Delete(ctx, imageHash)
+}
+
+func (q *Queue) save(ctx context.Context) error {
+	if q.store == nil {
+		return nil
+	}
+
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+
+	q.mu.Lock()
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobCopy := *job
+		jobs = append(jobs, &jobCopy)
+	}
+	q.mu.Unlock()
+
+	return q.store.Save(ctx, jobs)
+}
@@ -0,0 +1,418 @@
+package queue_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/indexer"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/queue"
+)
+
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs []*queue.Job
+}
+
+func (m *memoryStore) Load(ctx context.Context) ([]*queue.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.jobs, nil
+}
+
+func (m *memoryStore) Save(ctx context.Context, jobs []*queue.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs = jobs
+	return nil
+}
+
+var testAuctionEnd = indexer.AuctionEnd{
+	AuctionId:         1,
+	CollectionAddress: common.HexToAddress("0x1234567890123456789012345678901234567890"),
+	Winner:            common.HexToAddress("0x0987654321098765432109876543210987654321"),
+	Prompt:            "test prompt",
+}
+
+func newTestQueue(t *testing.T, store queue.Store, handler queue.Handler) *queue.Queue {
+	q, err := queue.NewQueue(queue.QueueConfig{
+		Store:        store,
+		Handler:      handler,
+		Workers:      1,
+		MaxAttempts:  3,
+		BaseBackoff:  time.Millisecond,
+		MaxBackoff:   10 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	return q
+}
+
+func waitForStatus(t *testing.T, q *queue.Queue, status queue.Status) queue.Job {
+	var job queue.Job
+	require.Eventually(t, func() bool {
+		jobs := q.Jobs()
+		if len(jobs) != 1 {
+			return false
+		}
+		job = jobs[0]
+		return job.Status == status
+	}, 2*time.Second, 5*time.Millisecond)
+
+	return job
+}
+
+func TestQueue_RetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	q := newTestQueue(t, &memoryStore{}, func(ctx context.Context, job *queue.Job) error {
+		calls++
+		if calls < 3 {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	created, err := q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	created, err = q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	job := waitForStatus(t, q, queue.StatusDone)
+	assert.Equal(t, 3, job.TotalAttempts)
+	assert.Empty(t, job.LastError)
+}
+
+func TestQueue_DeadLetter(t *testing.T) {
+	q := newTestQueue(t, &memoryStore{}, func(ctx context.Context, job *queue.Job) error {
+		job.Stage = "generate"
+		return assert.AnError
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	_, err := q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+
+	job := waitForStatus(t, q, queue.StatusDead)
+	assert.Equal(t, 3, job.Attempts)
+	assert.Equal(t, "generate", job.Stage)
+	assert.Equal(t, assert.AnError.Error(), job.LastError)
+}
+
+func TestQueue_AttemptsArePerStage(t *testing.T) {
+	stages := []string{"generate", "generate", "upload", "upload", "upload"}
+	calls := 0
+	q := newTestQueue(t, &memoryStore{}, func(ctx context.Context, job *queue.Job) error {
+		job.Stage = stages[calls]
+		calls++
+		return assert.AnError
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	_, err := q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+
+	job := waitForStatus(t, q, queue.StatusDead)
+	assert.Equal(t, "upload", job.Stage)
+	assert.Equal(t, 3, job.Attempts)
+	assert.Equal(t, 5, job.TotalAttempts)
+}
+
+func TestQueue_ResumesInterruptedJobs(t *testing.T) {
+	store := &memoryStore{
+		jobs: []*queue.Job{{
+			Id:         queue.JobId(testAuctionEnd),
+			AuctionEnd: testAuctionEnd,
+			Status:     queue.StatusRunning,
+			Stage:      "upload",
+		}},
+	}
+
+	var resumedStage string
+	q := newTestQueue(t, store, func(ctx context.Context, job *queue.Job) error {
+		resumedStage = job.Stage
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, q.Load(ctx))
+	q.Start(ctx)
+
+	waitForStatus(t, q, queue.StatusDone)
+	assert.Equal(t, "upload", resumedStage)
+}
+
+func TestQueue_CheckpointKeepsArtifactsAcrossRetries(t *testing.T) {
+	store := &memoryStore{}
+	generated := 0
+	var q *queue.Queue
+	q = newTestQueue(t, store, func(ctx context.Context, job *queue.Job) error {
+		if job.Artifacts.ImageHash == "" {
+			generated++
+			job.Stage = "generate"
+			job.Artifacts.ImageHash = "test-image-hash"
+			require.NoError(t, q.Checkpoint(ctx, job))
+
+			store.mu.Lock()
+			assert.Equal(t, "test-image-hash", store.jobs[0].Artifacts.ImageHash)
+			store.mu.Unlock()
+
+			return assert.AnError
+		}
+
+		job.Stage = "upload"
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	_, err := q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+
+	job := waitForStatus(t, q, queue.StatusDone)
+	assert.Equal(t, 1, generated)
+	assert.Equal(t, "test-image-hash", job.Artifacts.ImageHash)
+	assert.Equal(t, 2, job.TotalAttempts)
+}
+
+func TestQueue_PermanentErrorSkipsRetries(t *testing.T) {
+	q := newTestQueue(t, &memoryStore{}, func(ctx context.Context, job *queue.Job) error {
+		return fmt.Errorf("wrapped: %w", queue.Permanent(assert.AnError))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	_, err := q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+
+	job := waitForStatus(t, q, queue.StatusDead)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestArtifacts_UnmarshalLegacyMetadataCid(t *testing.T) {
+	var unsigned queue.Artifacts
+	require.NoError(t, json.Unmarshal([]byte(`{"MetadataCid":"bafkreitest"}`), &unsigned))
+	assert.Equal(t, "ipfs://bafkreitest", unsigned.TokenUri)
+
+	// a signed bare cid is submitted as signed
+	var signed queue.Artifacts
+	require.NoError(t, json.Unmarshal([]byte(`{"MetadataCid":"bafkreitest","Signature":"AQI="}`), &signed))
+	assert.Equal(t, "bafkreitest", signed.TokenUri)
+	assert.Equal(t, []byte{1, 2}, signed.Signature)
+
+	data, err := json.Marshal(unsigned)
+	require.NoError(t, err)
+	var reloaded queue.Artifacts
+	require.NoError(t, json.Unmarshal(data, &reloaded))
+	assert.Equal(t, unsigned, reloaded)
+	assert.NotContains(t, string(data), "MetadataCid")
+}
+
+func TestQueue_EnqueueRequeuesDeadJobs(t *testing.T) {
+	store := &memoryStore{}
+	fail := true
+	var mu sync.Mutex
+	q := newTestQueue(t, store, func(ctx context.Context, job *queue.Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		job.Stage = "upload"
+		job.Artifacts.ImageHash = "test-image-hash"
+		if fail {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	_, err := q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+	waitForStatus(t, q, queue.StatusDead)
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+
+	requeued, err := q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+	assert.True(t, requeued)
+
+	job := waitForStatus(t, q, queue.StatusDone)
+	assert.Equal(t, "test-image-hash", job.Artifacts.ImageHash)
+	assert.Equal(t, 0, job.Attempts)
+	assert.Equal(t, 4, job.TotalAttempts)
+}
+
+func TestQueue_PrunesDeadJobs(t *testing.T) {
+	store := &memoryStore{}
+	clock := &testClock{now: time.Now()}
+	q, err := queue.NewQueue(queue.QueueConfig{
+		Store: store,
+		Handler: func(ctx context.Context, job *queue.Job) error {
+			return queue.Permanent(assert.AnError)
+		},
+		Workers:      1,
+		MaxAttempts:  3,
+		PollInterval: 5 * time.Millisecond,
+		Retention:    time.Hour,
+		Clock:        clock,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	_, err = q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+	waitForStatus(t, q, queue.StatusDead)
+
+	clock.advance(2 * time.Hour)
+	other := testAuctionEnd
+	other.AuctionId = 2
+	_, err = q.Enqueue(ctx, other)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		jobs := q.Jobs()
+		return len(jobs) == 1 && jobs[0].Id == queue.JobId(other) && jobs[0].Status == queue.StatusDead
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestQueue_MovesLegacyImagesToImageStore(t *testing.T) {
+	image := []byte("generated image")
+	hash := imageHash(image)
+	data, err := json.Marshal(map[string]interface{}{"Image": image, "ImageHash": hash})
+	require.NoError(t, err)
+
+	var artifacts queue.Artifacts
+	require.NoError(t, json.Unmarshal(data, &artifacts))
+
+	store := &memoryStore{jobs: []*queue.Job{{
+		Id:         queue.JobId(testAuctionEnd),
+		AuctionEnd: testAuctionEnd,
+		Artifacts:  artifacts,
+		Status:     queue.StatusPending,
+	}}}
+	imageStore, _ := newTestImageStore(t)
+	q, err := queue.NewQueue(queue.QueueConfig{
+		Store:        store,
+		Handler:      func(ctx context.Context, job *queue.Job) error { return nil },
+		Workers:      1,
+		MaxAttempts:  3,
+		PollInterval: 5 * time.Millisecond,
+		ImageStore:   imageStore,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, q.Load(context.Background()))
+
+	loaded, err := q.LoadImage(context.Background(), hash)
+	require.NoError(t, err)
+	assert.Equal(t, image, loaded)
+
+	saved, err := json.Marshal(store.jobs)
+	require.NoError(t, err)
+	assert.NotContains(t, string(saved), `"Image"`)
+	assert.Contains(t, string(saved), hash)
+}
+
+func TestQueue_PruningDeletesImages(t *testing.T) {
+	image := []byte("generated image")
+	hash := imageHash(image)
+	imageStore, _ := newTestImageStore(t)
+	clock := &testClock{now: time.Now()}
+	var q *queue.Queue
+	q, err := queue.NewQueue(queue.QueueConfig{
+		Store: &memoryStore{},
+		Handler: func(ctx context.Context, job *queue.Job) error {
+			if job.AuctionEnd.AuctionId != testAuctionEnd.AuctionId {
+				return nil
+			}
+
+			require.NoError(t, q.SaveImage(ctx, hash, image))
+			job.Artifacts.ImageHash = hash
+			return queue.Permanent(assert.AnError)
+		},
+		Workers:      1,
+		MaxAttempts:  3,
+		PollInterval: 5 * time.Millisecond,
+		Retention:    time.Hour,
+		Clock:        clock,
+		ImageStore:   imageStore,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	_, err = q.Enqueue(ctx, testAuctionEnd)
+	require.NoError(t, err)
+	waitForStatus(t, q, queue.StatusDead)
+
+	loaded, err := imageStore.Load(ctx, hash)
+	require.NoError(t, err)
+	assert.Equal(t, image, loaded, "the image of a dead job is kept for a retry")
+
+	clock.advance(2 * time.Hour)
+	other := testAuctionEnd
+	other.AuctionId = 2
+	_, err = q.Enqueue(ctx, other)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		loaded, err := imageStore.Load(ctx, hash)
+		return err == nil && loaded == nil
+	}, 2*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/sealing"
+)
+
+type Store interface {
+	Load(ctx context.Context) ([]*Job, error)
+	Save(ctx context.Context, jobs []*Job) error
+}
+
+type FileStore struct {
+	dstackTappdEndpoint string
+	filePath            string
+}
+
+var _ Store = (*FileStore)(nil)
+
+func NewFileStore(dstackTappdEndpoint string, filePath string) *FileStore {
+	return &FileStore{
+		dstackTappdEndpoint: dstackTappdEndpoint,
+		filePath:            filePath,
+	}
+}
+
+func (s *FileStore) Load(ctx context.Context) ([]*Job, error) {
+	if _, err := os.Stat(s.filePath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	data, err := sealing.ReadSealedFile(ctx, s.dstackTappdEndpoint, s.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job queue: %v", err)
+	}
+
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job queue: %v", err)
+	}
+
+	return jobs, nil
+}
+
+func (s *FileStore) Save(ctx context.Context, jobs []*Job) error {
+	data, err := json.Marshal(jobs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job queue: %v", err)
+	}
+
+	return sealing.WriteSealedFile(ctx, s.dstackTappdEndpoint, s.filePath, data)
+}
@@ -1,39 +1,50 @@
 package agent
 
 import (
-	"bytes"
-	"encoding/binary"
+	"crypto/rsa"
 	"encoding/json"
+	"fmt"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
-)
 
-type ReportData struct {
-	Address         common.Address
-	ContractAddress common.Address
-}
+	"github.com/NethermindEth/yayois-garden/pkg/attestation"
+)
 
-func (r *ReportData) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]string{
-		"address":  r.Address.String(),
-		"contract": r.ContractAddress.String(),
-	})
+// FreshQuote is a quote committing to a caller's nonce, with the report data it was generated for.
+type FreshQuote struct {
+	Quote      string                  `json:"quote"`
+	ReportData *attestation.ReportData `json:"reportData"`
 }
 
-func (r *ReportData) MarshalBinary() ([]byte, error) {
-	writer := bytes.NewBuffer([]byte{})
+// newReportData commits the quotes to the agent's wallet, RSA public key and configuration, returning the report data
+// together with the configuration document it commits to.
+func newReportData(address common.Address, factoryAddress common.Address, chainId *big.Int, rsaPublicKey *rsa.PublicKey, config attestation.Config) (*attestation.ReportData, []byte, error) {
+	rsaPublicKeyHash, err := attestation.HashRsaPublicKey(rsaPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	binary.Write(writer, binary.BigEndian, r.Address.Bytes())
-	binary.Write(writer, binary.BigEndian, r.ContractAddress.Bytes())
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal attested config: %w", err)
+	}
 
-	return writer.Bytes(), nil
+	return &attestation.ReportData{
+		Version:          attestation.ReportDataVersion1,
+		Address:          address,
+		FactoryAddress:   factoryAddress,
+		ChainId:          chainId,
+		RsaPublicKeyHash: rsaPublicKeyHash,
+		ConfigHash:       attestation.HashConfig(configJson),
+	}, configJson, nil
 }
 
-func generateReportDataBytes(address common.Address, contractAddress common.Address) ([]byte, error) {
-	reportData := &ReportData{
-		Address:         address,
-		ContractAddress: contractAddress,
-	}
+func (a *Agent) ReportData() *attestation.ReportData {
+	return a.reportData
+}
 
-	return reportData.MarshalBinary()
+// AttestedConfig returns the configuration document whose hash is committed to by the report data.
+func (a *Agent) AttestedConfig() []byte {
+	return a.attestedConfig
 }
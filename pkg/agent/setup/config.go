@@ -2,7 +2,19 @@ package setup
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/art"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+)
+
+const (
+	defaultConfirmationDepth = 3
+	defaultArtGenerator      = art.BackendOpenAi
 )
 
 type Config struct {
@@ -14,9 +26,73 @@ type Config struct {
 	OpenAiModel         string
 	PinataJwtKey        string
 	ApiIpPort           string
+	ConfirmationDepth   uint64
+
+	ArtGenerator         string
+	StableDiffusionUrl   string
+	StableDiffusionModel string
+	ReplicateApiToken    string
+	ReplicateModel       string
+
+	// Uploaders are the storage backends every upload is replicated to, UploaderQuorum of which must agree
+	Uploaders      []string
+	UploaderQuorum uint64
+	// UploaderMirrors receive every upload without taking part in the quorum
+	UploaderMirrors     []string
+	BeeApiUrl           string
+	SwarmPostageBatchId string
+	KuboApiUrl          string
+	S3Endpoint          string
+	S3Region            string
+	S3Bucket            string
+	S3AccessKeyId       string
+	S3SecretAccessKey   string
+	S3KeyPrefix         string
+	S3PublicBaseUrl     string
+	S3Sse               string
+	S3KmsKeyId          string
+
+	AllowUnencryptedSystemPrompts bool
+	IpfsGateways                  []string
+	ArweaveGateways               []string
+
+	// MigrationImport waits for the secrets of an old agent instead of generating new ones when there is no setup
+	MigrationImport bool
+	// MigrationAllowedMeasurements are the enclaves this agent exports its secrets to, and imports them from
+	MigrationAllowedMeasurements []tdx.Measurement
 }
 
 func NewConfigFromEnv() (*Config, error) {
+	confirmationDepth, err := getEnvUint64(EnvConfirmationDepth, defaultConfirmationDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	allowUnencryptedSystemPrompts, err := getEnvBool(EnvAllowUnencryptedSystemPrompts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationImport, err := getEnvBool(EnvMigrationImport, false)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationAllowedMeasurements, err := getEnvMeasurements(EnvMigrationAllowedMeasurements)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaderQuorum, err := getEnvUint64(EnvUploaderQuorum, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaders := getEnvList(EnvUploader)
+	if len(uploaders) == 0 {
+		uploaders = []string{filestorage.BackendPinata}
+	}
+
 	config := &Config{
 		DstackTappdEndpoint: os.Getenv(EnvDstackTappdEndpoint),
 		EthereumRpcUrl:      os.Getenv(EnvEthereumRpcUrl),
@@ -26,9 +102,39 @@ func NewConfigFromEnv() (*Config, error) {
 		OpenAiModel:         os.Getenv(EnvOpenAiModel),
 		PinataJwtKey:        os.Getenv(EnvPinataJwtKey),
 		ApiIpPort:           os.Getenv(EnvApiIpPort),
+		ConfirmationDepth:   confirmationDepth,
+
+		ArtGenerator:         getEnvString(EnvArtGenerator, defaultArtGenerator),
+		StableDiffusionUrl:   os.Getenv(EnvStableDiffusionUrl),
+		StableDiffusionModel: os.Getenv(EnvStableDiffusionModel),
+		ReplicateApiToken:    os.Getenv(EnvReplicateApiToken),
+		ReplicateModel:       os.Getenv(EnvReplicateModel),
+
+		Uploaders:           uploaders,
+		UploaderQuorum:      uploaderQuorum,
+		UploaderMirrors:     getEnvList(EnvUploaderMirrors),
+		BeeApiUrl:           os.Getenv(EnvBeeApiUrl),
+		SwarmPostageBatchId: os.Getenv(EnvSwarmPostageBatchId),
+		KuboApiUrl:          os.Getenv(EnvKuboApiUrl),
+		S3Endpoint:          os.Getenv(EnvS3Endpoint),
+		S3Region:            os.Getenv(EnvS3Region),
+		S3Bucket:            os.Getenv(EnvS3Bucket),
+		S3AccessKeyId:       os.Getenv(EnvS3AccessKeyId),
+		S3SecretAccessKey:   os.Getenv(EnvS3SecretAccessKey),
+		S3KeyPrefix:         os.Getenv(EnvS3KeyPrefix),
+		S3PublicBaseUrl:     os.Getenv(EnvS3PublicBaseUrl),
+		S3Sse:               os.Getenv(EnvS3Sse),
+		S3KmsKeyId:          os.Getenv(EnvS3KmsKeyId),
+
+		AllowUnencryptedSystemPrompts: allowUnencryptedSystemPrompts,
+		IpfsGateways:                  getEnvList(EnvIpfsGateways),
+		ArweaveGateways:               getEnvList(EnvArweaveGateways),
+
+		MigrationImport:              migrationImport,
+		MigrationAllowedMeasurements: migrationAllowedMeasurements,
 	}
 
-	err := config.Validate()
+	err = config.Validate()
 	if err != nil {
 		return nil, err
 	}
@@ -49,17 +155,192 @@ func (c *Config) Validate() error {
 	if c.SecureFile == "" {
 		return errors.New(EnvSecureFile + " is required")
 	}
-	if c.OpenAiApiKey == "" {
-		return errors.New(EnvOpenAiApiKey + " is required")
+	if err := c.validateArtGenerator(); err != nil {
+		return err
 	}
-	if c.OpenAiModel == "" {
-		return errors.New(EnvOpenAiModel + " is required")
-	}
-	if c.PinataJwtKey == "" {
-		return errors.New(EnvPinataJwtKey + " is required")
+	if err := c.validateUploaders(); err != nil {
+		return err
 	}
 	if c.ApiIpPort == "" {
 		return errors.New(EnvApiIpPort + " is required")
 	}
+	if err := validateGateways(EnvIpfsGateways, c.IpfsGateways); err != nil {
+		return err
+	}
+	if err := validateGateways(EnvArweaveGateways, c.ArweaveGateways); err != nil {
+		return err
+	}
+	if c.MigrationImport && len(c.MigrationAllowedMeasurements) == 0 {
+		return errors.New(EnvMigrationAllowedMeasurements + " is required to import secrets")
+	}
 	return nil
 }
+
+func (c *Config) validateArtGenerator() error {
+	switch c.ArtGenerator {
+	case art.BackendOpenAi:
+		if c.OpenAiApiKey == "" {
+			return errors.New(EnvOpenAiApiKey + " is required")
+		}
+		if c.OpenAiModel == "" {
+			return errors.New(EnvOpenAiModel + " is required")
+		}
+	case art.BackendStableDiffusion:
+		if c.StableDiffusionUrl == "" {
+			return errors.New(EnvStableDiffusionUrl + " is required")
+		}
+	case art.BackendReplicate:
+		if c.ReplicateApiToken == "" {
+			return errors.New(EnvReplicateApiToken + " is required")
+		}
+		if c.ReplicateModel == "" {
+			return errors.New(EnvReplicateModel + " is required")
+		}
+	case art.BackendLocal:
+	default:
+		return fmt.Errorf("%s is invalid: unknown generator %q", EnvArtGenerator, c.ArtGenerator)
+	}
+	return nil
+}
+
+func (c *Config) validateUploaders() error {
+	if c.UploaderQuorum > uint64(len(c.Uploaders)) {
+		return fmt.Errorf("%s is invalid: quorum exceeds the %d uploaders", EnvUploaderQuorum, len(c.Uploaders))
+	}
+
+	seen := make(map[string]bool)
+	for _, uploader := range c.Uploaders {
+		if seen[uploader] {
+			return fmt.Errorf("%s is invalid: duplicate uploader %q", EnvUploader, uploader)
+		}
+		seen[uploader] = true
+
+		if err := c.validateUploader(uploader); err != nil {
+			return err
+		}
+	}
+	if len(c.Uploaders) > 1 && seen[filestorage.BackendS3] {
+		return fmt.Errorf("%s is invalid: %s returns its own urls and can only replicate as one of %s", EnvUploader, filestorage.BackendS3, EnvUploaderMirrors)
+	}
+	// replicas only reach a quorum on the same reference, and swarm references are never ipfs cids
+	if len(c.Uploaders) > 1 && seen[filestorage.BackendSwarm] {
+		return fmt.Errorf("%s is invalid: %s references never match ipfs cids, so it can only replicate as one of %s", EnvUploader, filestorage.BackendSwarm, EnvUploaderMirrors)
+	}
+
+	for _, mirror := range c.UploaderMirrors {
+		if seen[mirror] {
+			return fmt.Errorf("%s is invalid: duplicate uploader %q", EnvUploaderMirrors, mirror)
+		}
+		seen[mirror] = true
+
+		if err := c.validateUploader(mirror); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateUploader(uploader string) error {
+	switch uploader {
+	case filestorage.BackendPinata:
+		if c.PinataJwtKey == "" {
+			return errors.New(EnvPinataJwtKey + " is required")
+		}
+	case filestorage.BackendSwarm:
+		if c.BeeApiUrl == "" {
+			return errors.New(EnvBeeApiUrl + " is required")
+		}
+		if c.SwarmPostageBatchId == "" {
+			return errors.New(EnvSwarmPostageBatchId + " is required")
+		}
+	case filestorage.BackendKubo:
+		if c.KuboApiUrl == "" {
+			return errors.New(EnvKuboApiUrl + " is required")
+		}
+	case filestorage.BackendS3:
+		if c.S3Endpoint == "" {
+			return errors.New(EnvS3Endpoint + " is required")
+		}
+		if c.S3Bucket == "" {
+			return errors.New(EnvS3Bucket + " is required")
+		}
+		if c.S3AccessKeyId == "" || c.S3SecretAccessKey == "" {
+			return errors.New(EnvS3AccessKeyId + " and " + EnvS3SecretAccessKey + " are required")
+		}
+	default:
+		return fmt.Errorf("%s is invalid: unknown uploader %q", EnvUploader, uploader)
+	}
+	return nil
+}
+
+func validateGateways(key string, gateways []string) error {
+	for _, gateway := range gateways {
+		if !strings.HasPrefix(gateway, "https://") && !strings.HasPrefix(gateway, "http://") {
+			return fmt.Errorf("%s is invalid: gateway %q is not an http(s) url", key, gateway)
+		}
+	}
+	return nil
+}
+
+func getEnvString(key string, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+// getEnvList splits a comma-separated variable, returning nil when it is unset so that defaults apply.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func getEnvMeasurements(key string) ([]tdx.Measurement, error) {
+	var measurements []tdx.Measurement
+	for _, value := range getEnvList(key) {
+		measurement, err := tdx.ParseMeasurement(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s is invalid: %v", key, err)
+		}
+		measurements = append(measurements, measurement)
+	}
+
+	return measurements, nil
+}
+
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s is invalid: %v", key, err)
+	}
+
+	return parsed, nil
+}
+
+func getEnvUint64(key string, defaultValue uint64) (uint64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is invalid: %v", key, err)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,40 @@
+package setup_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/art"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/filestorage"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/setup"
+)
+
+func TestConfig_ValidateUploaders(t *testing.T) {
+	newConfig := func(uploaders []string, mirrors []string) *setup.Config {
+		return &setup.Config{
+			DstackTappdEndpoint: "http://localhost:8090",
+			EthereumRpcUrl:      "http://localhost:8545",
+			FactoryAddress:      "0x1234567890123456789012345678901234567890",
+			SecureFile:          "/data/secure",
+			ApiIpPort:           ":8080",
+			ArtGenerator:        art.BackendLocal,
+			Uploaders:           uploaders,
+			UploaderMirrors:     mirrors,
+			PinataJwtKey:        "jwt",
+			KuboApiUrl:          "http://localhost:5001",
+			BeeApiUrl:           "http://localhost:1633",
+			SwarmPostageBatchId: "batch",
+		}
+	}
+
+	// pinata and kubo replicas build the same CIDv1 DAG
+	assert.NoError(t, newConfig([]string{filestorage.BackendPinata, filestorage.BackendKubo}, nil).Validate())
+	assert.NoError(t, newConfig([]string{filestorage.BackendKubo}, []string{filestorage.BackendSwarm}).Validate())
+
+	// swarm references never agree with ipfs cids, so such a quorum is never reached
+	err := newConfig([]string{filestorage.BackendPinata, filestorage.BackendSwarm}, nil).Validate()
+	assert.ErrorContains(t, err, setup.EnvUploader+" is invalid")
+	err = newConfig([]string{filestorage.BackendKubo, filestorage.BackendSwarm, filestorage.BackendPinata}, nil).Validate()
+	assert.ErrorContains(t, err, setup.EnvUploader+" is invalid")
+}
@@ -1,12 +1,40 @@
 package setup
 
 const (
-	EnvDstackTappdEndpoint = "DSTACK_TAPPD_ENDPOINT"
-	EnvEthereumRpcUrl      = "ETHEREUM_RPC_URL"
-	EnvFactoryAddress      = "FACTORY_ADDRESS"
-	EnvSecureFile          = "SECURE_FILE"
-	EnvOpenAiApiKey        = "OPENAI_API_KEY"
-	EnvOpenAiModel         = "OPENAI_MODEL"
-	EnvPinataJwtKey        = "PINATA_JWT_KEY"
-	EnvApiIpPort           = "API_IP_PORT"
+	EnvDstackTappdEndpoint  = "DSTACK_TAPPD_ENDPOINT"
+	EnvEthereumRpcUrl       = "ETHEREUM_RPC_URL"
+	EnvFactoryAddress       = "FACTORY_ADDRESS"
+	EnvSecureFile           = "SECURE_FILE"
+	EnvOpenAiApiKey         = "OPENAI_API_KEY"
+	EnvOpenAiModel          = "OPENAI_MODEL"
+	EnvPinataJwtKey         = "PINATA_JWT_KEY"
+	EnvUploader             = "UPLOADER"
+	EnvUploaderQuorum       = "UPLOADER_QUORUM"
+	EnvUploaderMirrors      = "UPLOADER_MIRRORS"
+	EnvBeeApiUrl            = "BEE_API_URL"
+	EnvSwarmPostageBatchId  = "SWARM_POSTAGE_BATCH_ID"
+	EnvKuboApiUrl           = "KUBO_API_URL"
+	EnvS3Endpoint           = "S3_ENDPOINT"
+	EnvS3Region             = "S3_REGION"
+	EnvS3Bucket             = "S3_BUCKET"
+	EnvS3AccessKeyId        = "S3_ACCESS_KEY_ID"
+	EnvS3SecretAccessKey    = "S3_SECRET_ACCESS_KEY"
+	EnvS3KeyPrefix          = "S3_KEY_PREFIX"
+	EnvS3PublicBaseUrl      = "S3_PUBLIC_BASE_URL"
+	EnvS3Sse                = "S3_SERVER_SIDE_ENCRYPTION"
+	EnvS3KmsKeyId           = "S3_KMS_KEY_ID"
+	EnvApiIpPort            = "API_IP_PORT"
+	EnvConfirmationDepth    = "CONFIRMATION_DEPTH"
+	EnvArtGenerator         = "ART_GENERATOR"
+	EnvStableDiffusionUrl   = "STABLE_DIFFUSION_URL"
+	EnvStableDiffusionModel = "STABLE_DIFFUSION_MODEL"
+	EnvReplicateApiToken    = "REPLICATE_API_TOKEN"
+	EnvReplicateModel       = "REPLICATE_MODEL"
+
+	EnvAllowUnencryptedSystemPrompts = "ALLOW_UNENCRYPTED_SYSTEM_PROMPTS"
+	EnvIpfsGateways                  = "IPFS_GATEWAYS"
+	EnvArweaveGateways               = "ARWEAVE_GATEWAYS"
+
+	EnvMigrationImport              = "MIGRATION_IMPORT"
+	EnvMigrationAllowedMeasurements = "MIGRATION_ALLOWED_MEASUREMENTS"
 )
@@ -5,14 +5,22 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 
+	"github.com/Dstack-TEE/dstack/sdk/go/tappd"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
 
 	"github.com/NethermindEth/yayois-garden/pkg/agent/debug"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/migration"
 	"github.com/NethermindEth/yayois-garden/pkg/agent/sealing"
+	"github.com/NethermindEth/yayois-garden/pkg/agent/wallet"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+	contractYayoiFactory "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiFactory"
 )
 
 type SetupResult struct {
@@ -24,6 +32,42 @@ type SetupResult struct {
 	OpenAiModel           string
 	PinataJwtKey          string
 	ApiIpPort             string
+	ConfirmationDepth     uint64
+	ArtGenerator          string
+	StableDiffusionUrl    string
+	StableDiffusionModel  string
+	ReplicateApiToken     string
+	ReplicateModel        string
+	Uploaders             []string
+	UploaderQuorum        uint64
+	BeeApiUrl             string
+	SwarmPostageBatchId   string
+	KuboApiUrl            string
+	UploaderMirrors       []string
+	S3Endpoint            string
+	S3Region              string
+	S3Bucket              string
+	S3AccessKeyId         string
+	S3SecretAccessKey     string
+	S3KeyPrefix           string
+	S3PublicBaseUrl       string
+	S3Sse                 string
+	S3KmsKeyId            string
+	AccountPrivateKeySeed []byte
+	RsaPrivateKey         *rsa.PrivateKey
+
+	AllowUnencryptedSystemPrompts bool
+	IpfsGateways                  []string
+	ArweaveGateways               []string
+
+	// MigrationAllowedMeasurements are the enclaves secrets are exported to and imported from
+	MigrationAllowedMeasurements []tdx.Measurement
+}
+
+// sealedSetup is the part of the setup kept in the sealed file: the key material, which cannot be recreated. Every
+// other setting is read from the environment on every start, so that changing it takes effect without regenerating the
+// agent's keys. Its fields keep the names they have in SetupResult, which older sealed files hold in full.
+type sealedSetup struct {
 	AccountPrivateKeySeed []byte
 	RsaPrivateKey         *rsa.PrivateKey
 }
@@ -51,7 +95,7 @@ func Setup(ctx context.Context) (*SetupResult, error) {
 	return setupResult, nil
 }
 
-func generateSetup(config *Config) (*SetupResult, error) {
+func generateSecrets() (*migration.Secrets, error) {
 	accountPrivateKeySeed := make([]byte, 32)
 	if _, err := io.ReadFull(rand.Reader, accountPrivateKeySeed); err != nil {
 		return nil, fmt.Errorf("failed to generate private key seed: %v", err)
@@ -62,6 +106,51 @@ func generateSetup(config *Config) (*SetupResult, error) {
 		return nil, fmt.Errorf("failed to generate rsa private key: %w", err)
 	}
 
+	return migration.NewSecrets(accountPrivateKeySeed, rsaPrivateKey), nil
+}
+
+// importSecrets serves the migration endpoints until an allowlisted old agent exports the secrets of a wallet the
+// factory trusts to this enclave.
+func importSecrets(ctx context.Context, config *Config) (*migration.Secrets, error) {
+	ethClient, err := ethclient.DialContext(ctx, config.EthereumRpcUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial ethereum client: %w", err)
+	}
+	defer ethClient.Close()
+
+	factory, err := contractYayoiFactory.NewContractYayoiFactoryCaller(common.HexToAddress(config.FactoryAddress), ethClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create factory: %w", err)
+	}
+
+	receiver, err := migration.NewReceiver(migration.ReceiverConfig{
+		TappdClient:         tappd.NewTappdClient(tappd.WithEndpoint(config.DstackTappdEndpoint)),
+		AllowedMeasurements: config.MigrationAllowedMeasurements,
+		AuthorizeSecrets: func(ctx context.Context, secrets *migration.Secrets) error {
+			address, err := wallet.AddressFromSeed(secrets.AccountPrivateKeySeed)
+			if err != nil {
+				return err
+			}
+
+			authorized, err := factory.IsAuthorizedSigner(&bind.CallOpts{Context: ctx}, address)
+			if err != nil {
+				return fmt.Errorf("failed to check authorized signer: %w", err)
+			}
+			if !authorized {
+				return fmt.Errorf("wallet %s is not an authorized signer of factory %s", address, config.FactoryAddress)
+			}
+
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return receiver.Receive(ctx, config.ApiIpPort)
+}
+
+func newSetupResult(config *Config, sealed *sealedSetup) *SetupResult {
 	return &SetupResult{
 		DstackTappdEndpoint:   config.DstackTappdEndpoint,
 		EthereumRpcUrl:        config.EthereumRpcUrl,
@@ -71,39 +160,87 @@ func generateSetup(config *Config) (*SetupResult, error) {
 		OpenAiModel:           config.OpenAiModel,
 		PinataJwtKey:          config.PinataJwtKey,
 		ApiIpPort:             config.ApiIpPort,
-		AccountPrivateKeySeed: accountPrivateKeySeed,
-		RsaPrivateKey:         rsaPrivateKey,
-	}, nil
+		ConfirmationDepth:     config.ConfirmationDepth,
+		ArtGenerator:          config.ArtGenerator,
+		StableDiffusionUrl:    config.StableDiffusionUrl,
+		StableDiffusionModel:  config.StableDiffusionModel,
+		ReplicateApiToken:     config.ReplicateApiToken,
+		ReplicateModel:        config.ReplicateModel,
+		Uploaders:             config.Uploaders,
+		UploaderQuorum:        config.UploaderQuorum,
+		BeeApiUrl:             config.BeeApiUrl,
+		SwarmPostageBatchId:   config.SwarmPostageBatchId,
+		KuboApiUrl:            config.KuboApiUrl,
+		UploaderMirrors:       config.UploaderMirrors,
+		S3Endpoint:            config.S3Endpoint,
+		S3Region:              config.S3Region,
+		S3Bucket:              config.S3Bucket,
+		S3AccessKeyId:         config.S3AccessKeyId,
+		S3SecretAccessKey:     config.S3SecretAccessKey,
+		S3KeyPrefix:           config.S3KeyPrefix,
+		S3PublicBaseUrl:       config.S3PublicBaseUrl,
+		S3Sse:                 config.S3Sse,
+		S3KmsKeyId:            config.S3KmsKeyId,
+		AccountPrivateKeySeed: sealed.AccountPrivateKeySeed,
+		RsaPrivateKey:         sealed.RsaPrivateKey,
+
+		AllowUnencryptedSystemPrompts: config.AllowUnencryptedSystemPrompts,
+		IpfsGateways:                  config.IpfsGateways,
+		ArweaveGateways:               config.ArweaveGateways,
+
+		MigrationAllowedMeasurements: config.MigrationAllowedMeasurements,
+	}
 }
 
 func initializeSetup(ctx context.Context, config *Config) (*SetupResult, error) {
-	setupResult, err := generateSetup(config)
+	var secrets *migration.Secrets
+	var err error
+	if config.MigrationImport {
+		secrets, err = importSecrets(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import secrets: %v", err)
+		}
+
+		slog.Info("imported secrets from migration")
+	} else {
+		secrets, err = generateSecrets()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate secrets: %v", err)
+		}
+	}
+
+	rsaPrivateKey, err := secrets.ParseRsaPrivateKey()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate setup: %v", err)
 	}
 
-	if err := writeSetupResult(ctx, config, setupResult); err != nil {
+	sealed := &sealedSetup{
+		AccountPrivateKeySeed: secrets.AccountPrivateKeySeed,
+		RsaPrivateKey:         rsaPrivateKey,
+	}
+
+	if err := writeSealedSetup(ctx, config, sealed); err != nil {
 		return nil, fmt.Errorf("failed to write setup output: %v", err)
 	}
 
 	slog.Info("wrote encrypted setup output")
 
-	return setupResult, nil
+	return newSetupResult(config, sealed), nil
 }
 
 func loadSetup(ctx context.Context, config *Config) (*SetupResult, error) {
-	setupResult, err := readSetupResult(ctx, config)
+	sealed, err := readSealedSetup(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 
 	slog.Info("loaded decrypted setup output")
 
-	return setupResult, nil
+	return newSetupResult(config, sealed), nil
 }
 
-func writeSetupResult(ctx context.Context, config *Config, setupResult *SetupResult) error {
-	data, err := json.Marshal(setupResult)
+func writeSealedSetup(ctx context.Context, config *Config, sealed *sealedSetup) error {
+	data, err := json.Marshal(sealed)
 	if err != nil {
 		return fmt.Errorf("failed to marshal setup result: %v", err)
 	}
@@ -111,16 +248,19 @@ func writeSetupResult(ctx context.Context, config *Config, setupResult *SetupRes
 	return sealing.WriteSealedFile(ctx, config.DstackTappdEndpoint, config.SecureFile, data)
 }
 
-func readSetupResult(ctx context.Context, config *Config) (*SetupResult, error) {
+func readSealedSetup(ctx context.Context, config *Config) (*sealedSetup, error) {
 	data, err := sealing.ReadSealedFile(ctx, config.DstackTappdEndpoint, config.SecureFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read sealed file: %v", err)
 	}
 
-	var setupResult SetupResult
-	if err := json.Unmarshal(data, &setupResult); err != nil {
+	var sealed sealedSetup
+	if err := json.Unmarshal(data, &sealed); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal setup result: %v", err)
 	}
+	if len(sealed.AccountPrivateKeySeed) == 0 || sealed.RsaPrivateKey == nil {
+		return nil, errors.New("sealed setup is missing key material")
+	}
 
-	return &setupResult, nil
+	return &sealed, nil
 }
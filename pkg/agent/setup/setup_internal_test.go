@@ -0,0 +1,44 @@
+package setup
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSetupResult_ReadsSettingsFromConfig(t *testing.T) {
+	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// older agents sealed the whole setup result, including the settings of the environment they started in
+	legacy, err := json.Marshal(&SetupResult{
+		ArtGenerator:          "openai",
+		Uploaders:             []string{"pinata"},
+		ConfirmationDepth:     1,
+		IpfsGateways:          []string{"https://old.example/ipfs/"},
+		AccountPrivateKeySeed: []byte("seed"),
+		RsaPrivateKey:         rsaPrivateKey,
+	})
+	require.NoError(t, err)
+
+	var sealed sealedSetup
+	require.NoError(t, json.Unmarshal(legacy, &sealed))
+
+	setupResult := newSetupResult(&Config{
+		ArtGenerator:      "replicate",
+		Uploaders:         []string{"kubo"},
+		ConfirmationDepth: 12,
+		IpfsGateways:      []string{"https://new.example/ipfs/"},
+	}, &sealed)
+
+	assert.Equal(t, "replicate", setupResult.ArtGenerator)
+	assert.Equal(t, []string{"kubo"}, setupResult.Uploaders)
+	assert.Equal(t, uint64(12), setupResult.ConfirmationDepth)
+	assert.Equal(t, []string{"https://new.example/ipfs/"}, setupResult.IpfsGateways)
+	assert.Equal(t, []byte("seed"), setupResult.AccountPrivateKeySeed)
+	assert.True(t, rsaPrivateKey.Equal(setupResult.RsaPrivateKey))
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket bounds an operation across all callers. It holds up to burst tokens and regains one every
+// refillInterval.
+type tokenBucket struct {
+	mu             sync.Mutex
+	clock          AgentClock
+	burst          float64
+	refillInterval time.Duration
+
+	tokens     float64
+	refilledAt time.Time
+}
+
+func newTokenBucket(clock AgentClock, burst int, refillInterval time.Duration) *tokenBucket {
+	return &tokenBucket{
+		clock:          clock,
+		burst:          float64(burst),
+		refillInterval: refillInterval,
+		tokens:         float64(burst),
+		refilledAt:     clock.Now(),
+	}
+}
+
+// take removes a token and reports whether there was one.
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	if elapsed := now.Sub(b.refilledAt); elapsed > 0 {
+		b.tokens = min(b.tokens+float64(elapsed)/float64(b.refillInterval), b.burst)
+		b.refilledAt = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
+
+// giveBack returns a token taken for an operation that did not go through.
+func (b *tokenBucket) giveBack() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.tokens+1, b.burst)
+}
@@ -0,0 +1,374 @@
+package txmanager
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type TxManagerEthClient interface {
+	bind.ContractBackend
+	ethereum.TransactionReader
+}
+
+// BuildFunc creates a signed transaction from the given options without sending it, e.g. by calling a contract
+// binding method. The options carry the nonce and fees assigned by the manager and have NoSend set.
+type BuildFunc func(opts *bind.TransactOpts) (*types.Transaction, error)
+
+// RevertError is returned when a transaction is mined but its execution failed.
+type RevertError struct {
+	TxHash      common.Hash
+	BlockNumber *big.Int
+	Reason      string
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("transaction %s reverted in block %s", e.TxHash, e.BlockNumber)
+	}
+	return fmt.Sprintf("transaction %s reverted in block %s: %s", e.TxHash, e.BlockNumber, e.Reason)
+}
+
+type TxManagerConfig struct {
+	EthClient      TxManagerEthClient
+	Auth           *bind.TransactOpts
+	PollInterval   time.Duration
+	BumpInterval   time.Duration
+	ReceiptTimeout time.Duration
+	// MaxGasFeeCap bounds fee bumping. Zero or nil means no bound.
+	MaxGasFeeCap *big.Int
+}
+
+type TxManager struct {
+	mu    sync.Mutex
+	nonce *uint64
+
+	ethClient TxManagerEthClient
+	auth      *bind.TransactOpts
+
+	pollInterval   time.Duration
+	bumpInterval   time.Duration
+	receiptTimeout time.Duration
+	maxGasFeeCap   *big.Int
+}
+
+const (
+	// Nodes require replacements to raise both fees by at least 10%
+	bumpNumerator   = 1125
+	bumpDenominator = 1000
+
+	baseFeeMultiplier = 2
+)
+
+func NewTxManager(config TxManagerConfig) (*TxManager, error) {
+	if config.EthClient == nil {
+		return nil, errors.New("eth client is nil")
+	}
+	if config.Auth == nil {
+		return nil, errors.New("auth is nil")
+	}
+	if config.PollInterval <= 0 {
+		return nil, errors.New("poll interval must be positive")
+	}
+
+	return &TxManager{
+		ethClient: config.EthClient,
+		auth:      config.Auth,
+
+		pollInterval:   config.PollInterval,
+		bumpInterval:   config.BumpInterval,
+		receiptTimeout: config.ReceiptTimeout,
+		maxGasFeeCap:   config.MaxGasFeeCap,
+	}, nil
+}
+
+func (m *TxManager) From() common.Address {
+	return m.auth.From
+}
+
+// Send builds a transaction with the next local nonce and current fees and broadcasts it. The nonce is only consumed
+// when the node accepts the transaction; otherwise it is resynchronized from the pending state on the next call.
+func (m *TxManager) Send(ctx context.Context, build BuildFunc) (*types.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.sendLocked(ctx, build)
+	if err != nil && isNonceTooLow(err) {
+		slog.Warn("nonce too low, resyncing", "from", m.auth.From, "error", err)
+		m.nonce = nil
+		tx, err = m.sendLocked(ctx, build)
+	}
+	if err != nil {
+		m.nonce = nil
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// Resync drops the local nonce, so that the next Send reads it from the pending state. It must be called when a sent
+// transaction was dropped by the network, as its nonce would otherwise leave a gap that blocks every later transaction.
+func (m *TxManager) Resync() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nonce = nil
+}
+
+func (m *TxManager) sendLocked(ctx context.Context, build BuildFunc) (*types.Transaction, error) {
+	nonce, err := m.nextNonceLocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := *m.auth
+	opts.Context = ctx
+	opts.Nonce = new(big.Int).SetUint64(nonce)
+	opts.NoSend = true
+
+	if err := m.setFees(ctx, &opts); err != nil {
+		return nil, err
+	}
+
+	tx, err := build(&opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build transaction: %w", err)
+	}
+
+	if err := m.ethClient.SendTransaction(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+
+	*m.nonce = nonce + 1
+	slog.Info("sent transaction", "tx", tx.Hash(), "nonce", nonce)
+
+	return tx, nil
+}
+
+func (m *TxManager) nextNonceLocked(ctx context.Context) (uint64, error) {
+	if m.nonce != nil {
+		return *m.nonce, nil
+	}
+
+	nonce, err := m.ethClient.PendingNonceAt(ctx, m.auth.From)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pending nonce: %w", err)
+	}
+
+	m.nonce = &nonce
+	return nonce, nil
+}
+
+func (m *TxManager) setFees(ctx context.Context, opts *bind.TransactOpts) error {
+	head, err := m.ethClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	if head.BaseFee == nil {
+		gasPrice, err := m.ethClient.SuggestGasPrice(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to suggest gas price: %w", err)
+		}
+		opts.GasPrice = gasPrice
+		return nil
+	}
+
+	gasTipCap, err := m.ethClient.SuggestGasTipCap(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to suggest gas tip cap: %w", err)
+	}
+
+	opts.GasTipCap = gasTipCap
+	opts.GasFeeCap = new(big.Int).Add(gasTipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(baseFeeMultiplier)))
+	return nil
+}
+
+// WaitMined waits for the transaction, or one of the replacements sent for it, to be mined. If it is not mined within
+// the bump interval, it is resent with the same nonce and higher fees, and onReplace is called with the replacement so
+// that callers can record its hash. A mined transaction that failed is reported as a *RevertError.
+func (m *TxManager) WaitMined(ctx context.Context, tx *types.Transaction, onReplace func(tx *types.Transaction)) (*types.Receipt, error) {
+	if m.receiptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.receiptTimeout)
+		defer cancel()
+	}
+
+	sent := []*types.Transaction{tx}
+	lastSentAt := time.Now()
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, sentTx := range sent {
+			receipt, err := m.ethClient.TransactionReceipt(ctx, sentTx.Hash())
+			if err == nil {
+				if receipt.Status != types.ReceiptStatusSuccessful {
+					return receipt, m.revertError(ctx, sentTx, receipt)
+				}
+				return receipt, nil
+			}
+			if !errors.Is(err, ethereum.NotFound) {
+				slog.Warn("failed to get transaction receipt", "tx", sentTx.Hash(), "error", err)
+			}
+		}
+
+		if m.bumpInterval > 0 && time.Since(lastSentAt) >= m.bumpInterval {
+			replacement, err := m.bump(ctx, sent[len(sent)-1])
+			switch {
+			case err != nil:
+				slog.Warn("failed to bump transaction fees", "tx", sent[len(sent)-1].Hash(), "error", err)
+			case replacement != nil:
+				sent = append(sent, replacement)
+				if onReplace != nil {
+					onReplace(replacement)
+				}
+			}
+			lastSentAt = time.Now()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// bump resends the transaction with the same nonce and raised fees. It returns nil if the fees are already at the
+// configured maximum.
+func (m *TxManager) bump(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	opts := bind.TransactOpts{}
+	if err := m.setFees(ctx, &opts); err != nil {
+		return nil, err
+	}
+
+	var txData types.TxData
+	if tx.Type() == types.LegacyTxType {
+		gasPrice := maxBig(bumpFee(tx.GasPrice()), opts.GasPrice)
+		if m.exceedsMaxFee(gasPrice) {
+			return nil, nil
+		}
+
+		txData = &types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: gasPrice,
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		}
+	} else {
+		gasTipCap := maxBig(bumpFee(tx.GasTipCap()), opts.GasTipCap)
+		gasFeeCap := maxBig(bumpFee(tx.GasFeeCap()), opts.GasFeeCap)
+		if m.exceedsMaxFee(gasFeeCap) {
+			return nil, nil
+		}
+
+		txData = &types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  gasTipCap,
+			GasFeeCap:  gasFeeCap,
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		}
+	}
+
+	replacement, err := m.auth.Signer(m.auth.From, types.NewTx(txData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign replacement: %w", err)
+	}
+
+	if err := m.ethClient.SendTransaction(ctx, replacement); err != nil {
+		if isNonceTooLow(err) {
+			// One of the already sent transactions was mined; the next receipt poll picks it up
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to send replacement: %w", err)
+	}
+
+	slog.Info("bumped transaction fees", "tx", tx.Hash(), "replacement", replacement.Hash(), "nonce", tx.Nonce())
+	return replacement, nil
+}
+
+func (m *TxManager) exceedsMaxFee(fee *big.Int) bool {
+	return m.maxGasFeeCap != nil && m.maxGasFeeCap.Sign() > 0 && fee.Cmp(m.maxGasFeeCap) > 0
+}
+
+// revertError replays the transaction on the state of the block before the one it was mined in to recover the revert
+// reason. The state of the mined block already includes the effects of the block, so the call could pass there.
+func (m *TxManager) revertError(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+	revertErr := &RevertError{
+		TxHash:      receipt.TxHash,
+		BlockNumber: receipt.BlockNumber,
+	}
+
+	replayBlock := new(big.Int).Set(receipt.BlockNumber)
+	if replayBlock.Sign() > 0 {
+		replayBlock.Sub(replayBlock, big.NewInt(1))
+	}
+
+	_, err := m.ethClient.CallContract(ctx, ethereum.CallMsg{
+		From:     m.auth.From,
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+		GasPrice: receipt.EffectiveGasPrice,
+	}, replayBlock)
+	if err != nil {
+		revertErr.Reason = decodeRevertReason(err)
+	}
+
+	return revertErr
+}
+
+func decodeRevertReason(err error) string {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			if revertData, decodeErr := hex.DecodeString(strings.TrimPrefix(data, "0x")); decodeErr == nil {
+				if reason, unpackErr := abi.UnpackRevert(revertData); unpackErr == nil {
+					return reason
+				}
+			}
+		}
+	}
+
+	return err.Error()
+}
+
+func isNonceTooLow(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
+
+func bumpFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(bumpNumerator))
+	bumped.Add(bumped, big.NewInt(bumpDenominator-1))
+	return bumped.Div(bumped, big.NewInt(bumpDenominator))
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if b == nil || a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,131 @@
+package txmanager_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/agent/txmanager"
+)
+
+var senderAccount, _ = crypto.GenerateKey()
+var senderAddress = crypto.PubkeyToAddress(senderAccount.PublicKey)
+var senderAuth, _ = bind.NewKeyedTransactorWithChainID(senderAccount, big.NewInt(1337))
+
+var recipientAddress = common.HexToAddress("0x1234567890123456789012345678901234567890")
+
+func newTestTxManager(t *testing.T, bumpInterval time.Duration) (*txmanager.TxManager, *simulated.Backend) {
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		senderAddress: {Balance: big.NewInt(1000000000000000000)},
+	})
+	t.Cleanup(func() { backend.Close() })
+
+	m, err := txmanager.NewTxManager(txmanager.TxManagerConfig{
+		EthClient:      backend.Client(),
+		Auth:           senderAuth,
+		PollInterval:   10 * time.Millisecond,
+		BumpInterval:   bumpInterval,
+		ReceiptTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	return m, backend
+}
+
+func transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Nonce:     opts.Nonce.Uint64(),
+		GasTipCap: opts.GasTipCap,
+		GasFeeCap: opts.GasFeeCap,
+		Gas:       21000,
+		To:        &recipientAddress,
+		Value:     big.NewInt(1),
+	})
+	return opts.Signer(opts.From, tx)
+}
+
+func TestTxManager_AssignsSequentialNonces(t *testing.T) {
+	m, backend := newTestTxManager(t, 0)
+	ctx := context.Background()
+
+	tx1, err := m.Send(ctx, transfer)
+	require.NoError(t, err)
+	tx2, err := m.Send(ctx, transfer)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(0), tx1.Nonce())
+	assert.Equal(t, uint64(1), tx2.Nonce())
+
+	backend.Commit()
+
+	receipt, err := m.WaitMined(ctx, tx2, nil)
+	require.NoError(t, err)
+	assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	assert.Equal(t, tx2.Hash(), receipt.TxHash)
+}
+
+func TestTxManager_BumpsStuckTransaction(t *testing.T) {
+	m, backend := newTestTxManager(t, 20*time.Millisecond)
+	ctx := context.Background()
+
+	tx, err := m.Send(ctx, transfer)
+	require.NoError(t, err)
+
+	var replacements []*types.Transaction
+	firstReplacement := make(chan struct{})
+	go func() {
+		<-firstReplacement
+		backend.Commit()
+	}()
+
+	receipt, err := m.WaitMined(ctx, tx, func(replacement *types.Transaction) {
+		replacements = append(replacements, replacement)
+		if len(replacements) == 1 {
+			close(firstReplacement)
+		}
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, replacements)
+
+	replacement := replacements[0]
+	assert.Equal(t, tx.Nonce(), replacement.Nonce())
+	assert.Equal(t, 1, replacement.GasFeeCap().Cmp(tx.GasFeeCap()))
+	assert.Equal(t, 1, replacement.GasTipCap().Cmp(tx.GasTipCap()))
+	assert.NotEqual(t, tx.Hash(), receipt.TxHash)
+}
+
+func TestTxManager_ResyncAfterDroppedTransaction(t *testing.T) {
+	m, backend := newTestTxManager(t, 0)
+	ctx := context.Background()
+
+	dropped, err := m.Send(ctx, transfer)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), dropped.Nonce())
+
+	// discards the pending transaction, as a node evicting it from its pool would
+	backend.Rollback()
+	_, _, err = backend.Client().TransactionByHash(ctx, dropped.Hash())
+	require.ErrorIs(t, err, ethereum.NotFound)
+
+	m.Resync()
+	tx, err := m.Send(ctx, transfer)
+	require.NoError(t, err)
+	assert.Equal(t, dropped.Nonce(), tx.Nonce())
+
+	backend.Commit()
+
+	receipt, err := m.WaitMined(ctx, tx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+}
@@ -32,6 +32,10 @@ type EIP712Domain struct {
 }
 
 func (w *Wallet) SignMintMessage(to common.Address, uri string, domain EIP712Domain) ([]byte, error) {
+	if w.SigningDisabled() {
+		return nil, ErrSigningDisabled
+	}
+
 	signer := beecrypto.NewDefaultSigner(w.privateKey)
 
 	address, err := signer.EthereumAddress()
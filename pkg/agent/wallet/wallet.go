@@ -2,21 +2,29 @@ package wallet
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"math/big"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// ErrSigningDisabled is returned instead of a signature once the wallet's keys have been handed to another agent.
+var ErrSigningDisabled = errors.New("signing is disabled")
+
 type Wallet struct {
 	privateKey *ecdsa.PrivateKey
 	seed       []byte
 	auth       *bind.TransactOpts
+
+	signingDisabled atomic.Bool
 }
 
 func NewWallet(seed []byte, chainID *big.Int) (*Wallet, error) {
-	privateKey, err := crypto.ToECDSA(crypto.Keccak256(seed))
+	privateKey, err := privateKeyFromSeed(seed)
 	if err != nil {
 		return nil, err
 	}
@@ -26,11 +34,35 @@ func NewWallet(seed []byte, chainID *big.Int) (*Wallet, error) {
 		return nil, err
 	}
 
-	return &Wallet{
+	w := &Wallet{
 		privateKey: privateKey,
 		seed:       seed,
-		auth:       auth,
-	}, nil
+	}
+
+	signTx := auth.Signer
+	auth.Signer = func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if w.SigningDisabled() {
+			return nil, ErrSigningDisabled
+		}
+		return signTx(address, tx)
+	}
+	w.auth = auth
+
+	return w, nil
+}
+
+// AddressFromSeed returns the address of the wallet created from seed.
+func AddressFromSeed(seed []byte) (common.Address, error) {
+	privateKey, err := privateKeyFromSeed(seed)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(privateKey.PublicKey), nil
+}
+
+func privateKeyFromSeed(seed []byte) (*ecdsa.PrivateKey, error) {
+	return crypto.ToECDSA(crypto.Keccak256(seed))
 }
 
 func (w *Wallet) PrivateKey() *ecdsa.PrivateKey {
@@ -48,3 +80,12 @@ func (w *Wallet) Auth() *bind.TransactOpts {
 func (w *Wallet) Seed() []byte {
 	return w.seed
 }
+
+// SetSigningDisabled stops or resumes signing of both mint messages and transactions.
+func (w *Wallet) SetSigningDisabled(disabled bool) {
+	w.signingDisabled.Store(disabled)
+}
+
+func (w *Wallet) SigningDisabled() bool {
+	return w.signingDisabled.Load()
+}
@@ -0,0 +1,181 @@
+// Package attestation defines what the agent binds into the report data of its TDX quotes, so that clients can check
+// the keys and configuration they are served against the quote.
+package attestation
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	ReportDataVersion1 = 1
+	// ReportDataVersion2 additionally commits to a caller supplied nonce, proving that the quote is fresh
+	ReportDataVersion2 = 2
+	// ReportDataSize is the size of the report data field of a TDX quote
+	ReportDataSize = 64
+	MaxNonceSize   = 64
+
+	chainIdSize = 32
+)
+
+// ReportData is the agent's identity and configuration committed to by its TDX quotes.
+//
+// Report data is laid out as:
+//
+//	[0]      version, 1 or 2
+//	[1:21]   agent wallet address
+//	[21:53]  SHA-256 of the commitment preimage
+//	[53:64]  zero
+//
+// The commitment preimage is the concatenation of:
+//
+//	agent wallet address           20 bytes
+//	factory address                20 bytes
+//	chain id                       32 bytes, big endian
+//	SHA-256 of the RSA public key  32 bytes, over its PKIX DER encoding
+//	SHA-256 of the configuration   32 bytes, over the JSON document served by the agent
+//	SHA-256 of the nonce           32 bytes, version 2 only
+type ReportData struct {
+	Version          uint8
+	Address          common.Address
+	FactoryAddress   common.Address
+	ChainId          *big.Int
+	RsaPublicKeyHash [32]byte
+	ConfigHash       [32]byte
+	// Nonce is the caller's challenge, set for version 2 only
+	Nonce []byte
+}
+
+// Config is the generator and storage configuration of an agent. Its hash is committed to by the report data.
+type Config struct {
+	ArtGenerator                  string   `json:"artGenerator"`
+	OpenAiModel                   string   `json:"openAiModel,omitempty"`
+	StableDiffusionUrl            string   `json:"stableDiffusionUrl,omitempty"`
+	StableDiffusionModel          string   `json:"stableDiffusionModel,omitempty"`
+	ReplicateModel                string   `json:"replicateModel,omitempty"`
+	Uploader                      string   `json:"uploader"`
+	UploaderQuorum                int      `json:"uploaderQuorum,omitempty"`
+	UploaderMirrors               []string `json:"uploaderMirrors,omitempty"`
+	IpfsGateways                  []string `json:"ipfsGateways,omitempty"`
+	ArweaveGateways               []string `json:"arweaveGateways,omitempty"`
+	AllowUnencryptedSystemPrompts bool     `json:"allowUnencryptedSystemPrompts"`
+	CollectionLifetimeSeconds     int64    `json:"collectionLifetimeSeconds"`
+	// MigrationAllowedMeasurements are the enclaves the agent may export its keys to
+	MigrationAllowedMeasurements []string `json:"migrationAllowedMeasurements,omitempty"`
+}
+
+func HashRsaPublicKey(publicKey *rsa.PublicKey) ([32]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("failed to marshal rsa public key: %w", err)
+	}
+
+	return sha256.Sum256(der), nil
+}
+
+// HashConfig hashes a configuration document exactly as served, so clients do not need to re-encode it.
+func HashConfig(configJson []byte) [32]byte {
+	return sha256.Sum256(configJson)
+}
+
+// WithNonce returns a version 2 copy of the report data committing to nonce.
+func (r *ReportData) WithNonce(nonce []byte) *ReportData {
+	fresh := *r
+	fresh.Version = ReportDataVersion2
+	fresh.Nonce = append([]byte(nil), nonce...)
+	return &fresh
+}
+
+// Preimage returns the commitment preimage.
+func (r *ReportData) Preimage() ([]byte, error) {
+	switch r.Version {
+	case ReportDataVersion1:
+		if len(r.Nonce) != 0 {
+			return nil, errors.New("version 1 report data does not commit to a nonce")
+		}
+	case ReportDataVersion2:
+		if len(r.Nonce) == 0 || len(r.Nonce) > MaxNonceSize {
+			return nil, fmt.Errorf("nonce must be between 1 and %d bytes", MaxNonceSize)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported report data version %d", r.Version)
+	}
+
+	if r.ChainId == nil || r.ChainId.Sign() < 0 || r.ChainId.BitLen() > chainIdSize*8 {
+		return nil, errors.New("invalid chain id")
+	}
+
+	var preimage bytes.Buffer
+	preimage.Write(r.Address.Bytes())
+	preimage.Write(r.FactoryAddress.Bytes())
+	preimage.Write(r.ChainId.FillBytes(make([]byte, chainIdSize)))
+	preimage.Write(r.RsaPublicKeyHash[:])
+	preimage.Write(r.ConfigHash[:])
+	if r.Version == ReportDataVersion2 {
+		nonceHash := sha256.Sum256(r.Nonce)
+		preimage.Write(nonceHash[:])
+	}
+
+	return preimage.Bytes(), nil
+}
+
+// Commitment returns the SHA-256 of the commitment preimage.
+func (r *ReportData) Commitment() ([32]byte, error) {
+	preimage, err := r.Preimage()
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	return sha256.Sum256(preimage), nil
+}
+
+func (r *ReportData) MarshalBinary() ([]byte, error) {
+	commitment, err := r.Commitment()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, ReportDataSize)
+	data[0] = r.Version
+	copy(data[1:21], r.Address.Bytes())
+	copy(data[21:53], commitment[:])
+
+	return data, nil
+}
+
+func (r *ReportData) MarshalJSON() ([]byte, error) {
+	preimage, err := r.Preimage()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := r.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{
+		"version":          r.Version,
+		"address":          r.Address.String(),
+		"factory":          r.FactoryAddress.String(),
+		"chainId":          r.ChainId.String(),
+		"rsaPublicKeyHash": hex.EncodeToString(r.RsaPublicKeyHash[:]),
+		"configHash":       hex.EncodeToString(r.ConfigHash[:]),
+		"preimage":         hex.EncodeToString(preimage),
+		"reportData":       hex.EncodeToString(data),
+	}
+	if r.Version == ReportDataVersion2 {
+		fields["nonce"] = hex.EncodeToString(r.Nonce)
+	}
+
+	return json.Marshal(fields)
+}
@@ -0,0 +1,102 @@
+package attestation_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation"
+)
+
+func TestReportData_MarshalBinary(t *testing.T) {
+	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPublicKeyHash, err := attestation.HashRsaPublicKey(&rsaPrivateKey.PublicKey)
+	require.NoError(t, err)
+
+	reportData := &attestation.ReportData{
+		Version:          attestation.ReportDataVersion1,
+		Address:          common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		FactoryAddress:   common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		ChainId:          big.NewInt(8453),
+		RsaPublicKeyHash: rsaPublicKeyHash,
+		ConfigHash:       attestation.HashConfig([]byte(`{"artGenerator":"openai"}`)),
+	}
+
+	data, err := reportData.MarshalBinary()
+	require.NoError(t, err)
+	require.Len(t, data, attestation.ReportDataSize)
+
+	var preimage []byte
+	preimage = append(preimage, reportData.Address.Bytes()...)
+	preimage = append(preimage, reportData.FactoryAddress.Bytes()...)
+	preimage = append(preimage, common.LeftPadBytes(reportData.ChainId.Bytes(), 32)...)
+	preimage = append(preimage, rsaPublicKeyHash[:]...)
+	preimage = append(preimage, reportData.ConfigHash[:]...)
+	commitment := sha256.Sum256(preimage)
+
+	assert.Equal(t, byte(attestation.ReportDataVersion1), data[0])
+	assert.Equal(t, reportData.Address.Bytes(), data[1:21])
+	assert.Equal(t, commitment[:], data[21:53])
+	assert.Equal(t, make([]byte, 11), data[53:])
+
+	reportData.ConfigHash = attestation.HashConfig([]byte(`{"artGenerator":"local"}`))
+	changed, err := reportData.MarshalBinary()
+	require.NoError(t, err)
+	assert.NotEqual(t, data, changed)
+}
+
+func TestReportData_UnsupportedVersion(t *testing.T) {
+	reportData := &attestation.ReportData{Version: 2, ChainId: big.NewInt(1)}
+
+	_, err := reportData.MarshalBinary()
+	assert.Error(t, err)
+}
+
+func TestReportData_WithNonce(t *testing.T) {
+	reportData := &attestation.ReportData{
+		Version:        attestation.ReportDataVersion1,
+		Address:        common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		FactoryAddress: common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		ChainId:        big.NewInt(8453),
+	}
+
+	static, err := reportData.MarshalBinary()
+	require.NoError(t, err)
+	staticPreimage, err := reportData.Preimage()
+	require.NoError(t, err)
+
+	nonce := []byte("challenge")
+	fresh := reportData.WithNonce(nonce)
+	assert.Equal(t, uint8(attestation.ReportDataVersion2), fresh.Version)
+	assert.Equal(t, uint8(attestation.ReportDataVersion1), reportData.Version)
+
+	preimage, err := fresh.Preimage()
+	require.NoError(t, err)
+	nonceHash := sha256.Sum256(nonce)
+	assert.Equal(t, append(staticPreimage, nonceHash[:]...), preimage)
+
+	data, err := fresh.MarshalBinary()
+	require.NoError(t, err)
+	commitment := sha256.Sum256(preimage)
+	assert.Equal(t, byte(attestation.ReportDataVersion2), data[0])
+	assert.Equal(t, commitment[:], data[21:53])
+	assert.NotEqual(t, static, data)
+
+	other, err := reportData.WithNonce([]byte("other")).MarshalBinary()
+	require.NoError(t, err)
+	assert.NotEqual(t, data, other)
+
+	_, err = reportData.WithNonce(make([]byte, attestation.MaxNonceSize+1)).MarshalBinary()
+	assert.Error(t, err)
+
+	reportData.Nonce = nonce
+	_, err = reportData.MarshalBinary()
+	assert.Error(t, err)
+}
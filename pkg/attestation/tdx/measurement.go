@@ -0,0 +1,78 @@
+package tdx
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const measurementRegisters = 5
+
+// ErrMeasurementNotAllowed is returned when a quote comes from a TD whose measurement is not allowlisted.
+var ErrMeasurementNotAllowed = errors.New("measurement is not allowed")
+
+// Measurement identifies the code running in a TD: its MRTD and all four runtime measurement registers. RTMR3 holds
+// the dstack app compose hash, so an allowlisted measurement pins the agent image and not just the guest OS.
+type Measurement struct {
+	MrTd  [48]byte
+	Rtmr0 [48]byte
+	Rtmr1 [48]byte
+	Rtmr2 [48]byte
+	Rtmr3 [48]byte
+}
+
+func MeasurementOf(body *Body) Measurement {
+	return Measurement{
+		MrTd:  body.MrTd,
+		Rtmr0: body.Rtmr0,
+		Rtmr1: body.Rtmr1,
+		Rtmr2: body.Rtmr2,
+		Rtmr3: body.Rtmr3,
+	}
+}
+
+// ParseMeasurement parses a measurement in the format of String: the hex encoded MRTD and RTMR0 to RTMR3 separated
+// by colons.
+func ParseMeasurement(value string) (Measurement, error) {
+	var measurement Measurement
+
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != measurementRegisters {
+		return measurement, fmt.Errorf("measurement must have %d registers, got %d", measurementRegisters, len(parts))
+	}
+
+	for i, register := range measurement.registers() {
+		decoded, err := hex.DecodeString(parts[i])
+		if err != nil || len(decoded) != len(register) {
+			return measurement, fmt.Errorf("register %d of measurement is not 48 hex encoded bytes", i)
+		}
+		copy(register, decoded)
+	}
+
+	return measurement, nil
+}
+
+// AllowedBy reports whether the measurement is one of allowlist.
+func (m Measurement) AllowedBy(allowlist []Measurement) bool {
+	for _, allowed := range allowlist {
+		if allowed == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m Measurement) String() string {
+	var parts []string
+	for _, register := range m.registers() {
+		parts = append(parts, hex.EncodeToString(register))
+	}
+
+	return strings.Join(parts, ":")
+}
+
+func (m *Measurement) registers() [][]byte {
+	return [][]byte{m.MrTd[:], m.Rtmr0[:], m.Rtmr1[:], m.Rtmr2[:], m.Rtmr3[:]}
+}
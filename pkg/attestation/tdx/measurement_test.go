@@ -0,0 +1,39 @@
+package tdx_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+)
+
+func TestParseMeasurement(t *testing.T) {
+	registers := []string{
+		strings.Repeat("01", 48),
+		strings.Repeat("02", 48),
+		strings.Repeat("03", 48),
+		strings.Repeat("04", 48),
+		strings.Repeat("05", 48),
+	}
+	value := strings.Join(registers, ":")
+
+	measurement, err := tdx.ParseMeasurement(value)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{1}, 48), measurement.MrTd[:])
+	assert.Equal(t, bytes.Repeat([]byte{5}, 48), measurement.Rtmr3[:])
+	assert.Equal(t, value, measurement.String())
+
+	for _, invalid := range []string{
+		"",
+		strings.Join(registers[:4], ":"),
+		strings.Join(append(registers[:4:4], "zz"), ":"),
+		strings.Join(append(registers[:4:4], "0102"), ":"),
+	} {
+		_, err := tdx.ParseMeasurement(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,267 @@
+// Package tdx parses version 4 TDX quotes and verifies their signature chain up to the Intel SGX root CA.
+package tdx
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	QuoteVersion4 = 4
+	TeeTypeTdx    = 0x81
+
+	attestationKeyTypeEcdsaP256 = 2
+
+	headerSize      = 48
+	bodySize        = 584
+	reportDataSize  = 64
+	signatureSize   = 64
+	publicKeySize   = 64
+	qeReportSize    = 384
+	qeReportDataOff = 320
+
+	certificationDataQeReport     = 6
+	certificationDataPckCertChain = 5
+
+	tdAttributeDebug = 1 << 0
+)
+
+var ErrMalformedQuote = errors.New("malformed quote")
+
+type Header struct {
+	Version            uint16
+	AttestationKeyType uint16
+	TeeType            uint32
+	QeVendorId         [16]byte
+	UserData           [20]byte
+}
+
+// Body is the TD 1.0 report body of a quote.
+type Body struct {
+	TeeTcbSvn      [16]byte
+	MrSeam         [48]byte
+	MrSignerSeam   [48]byte
+	SeamAttributes [8]byte
+	TdAttributes   [8]byte
+	Xfam           [8]byte
+	MrTd           [48]byte
+	MrConfigId     [48]byte
+	MrOwner        [48]byte
+	MrOwnerConfig  [48]byte
+	Rtmr0          [48]byte
+	Rtmr1          [48]byte
+	Rtmr2          [48]byte
+	Rtmr3          [48]byte
+	ReportData     [64]byte
+}
+
+// Debug reports whether the TD runs in debug mode, bit 0 of its attributes. The host can read and modify the memory of
+// a debug TD, so its quote proves nothing about the code running in it.
+func (b *Body) Debug() bool {
+	return b.TdAttributes[0]&tdAttributeDebug != 0
+}
+
+type Quote struct {
+	Header Header
+	Body   Body
+
+	// Signature is the ECDSA signature of the header and body by AttestationKey
+	Signature      []byte
+	AttestationKey []byte
+	// QeReport is the SGX report of the quoting enclave, signed by the PCK certificate
+	QeReport          []byte
+	QeReportSignature []byte
+	QeAuthData        []byte
+	// PckCertChain is the PEM certificate chain of the platform's PCK certificate
+	PckCertChain []byte
+
+	signedData []byte
+}
+
+// ParseQuote parses a binary quote. Hex encoded quotes, as returned by the dstack tappd API, are accepted as well.
+func ParseQuote(raw []byte) (*Quote, error) {
+	if decoded, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(raw)), "0x")); err == nil {
+		raw = decoded
+	}
+
+	r := &reader{data: raw}
+	quote := &Quote{}
+
+	quote.Header.Version = r.uint16()
+	quote.Header.AttestationKeyType = r.uint16()
+	quote.Header.TeeType = r.uint32()
+	r.skip(4)
+	r.copy(quote.Header.QeVendorId[:])
+	r.copy(quote.Header.UserData[:])
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if quote.Header.Version != QuoteVersion4 {
+		return nil, fmt.Errorf("unsupported quote version %d", quote.Header.Version)
+	}
+	if quote.Header.TeeType != TeeTypeTdx {
+		return nil, fmt.Errorf("unsupported tee type 0x%x", quote.Header.TeeType)
+	}
+	if quote.Header.AttestationKeyType != attestationKeyTypeEcdsaP256 {
+		return nil, fmt.Errorf("unsupported attestation key type %d", quote.Header.AttestationKeyType)
+	}
+
+	body := &quote.Body
+	for _, field := range [][]byte{
+		body.TeeTcbSvn[:], body.MrSeam[:], body.MrSignerSeam[:], body.SeamAttributes[:], body.TdAttributes[:],
+		body.Xfam[:], body.MrTd[:], body.MrConfigId[:], body.MrOwner[:], body.MrOwnerConfig[:],
+		body.Rtmr0[:], body.Rtmr1[:], body.Rtmr2[:], body.Rtmr3[:], body.ReportData[:],
+	} {
+		r.copy(field)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	quote.signedData = raw[:headerSize+bodySize]
+
+	signatureData := &reader{data: r.bytes(int(r.uint32()))}
+	quote.Signature = signatureData.bytes(signatureSize)
+	quote.AttestationKey = signatureData.bytes(publicKeySize)
+
+	certificationType := signatureData.uint16()
+	certification := &reader{data: signatureData.bytes(int(signatureData.uint32()))}
+	if signatureData.err != nil {
+		return nil, signatureData.err
+	}
+	if certificationType != certificationDataQeReport {
+		return nil, fmt.Errorf("unsupported certification data type %d", certificationType)
+	}
+
+	quote.QeReport = certification.bytes(qeReportSize)
+	quote.QeReportSignature = certification.bytes(signatureSize)
+	quote.QeAuthData = certification.bytes(int(certification.uint16()))
+
+	pckCertificationType := certification.uint16()
+	quote.PckCertChain = certification.bytes(int(certification.uint32()))
+	if certification.err != nil {
+		return nil, certification.err
+	}
+	if pckCertificationType != certificationDataPckCertChain {
+		return nil, fmt.Errorf("unsupported pck certification data type %d", pckCertificationType)
+	}
+
+	return quote, nil
+}
+
+// verifyQuoteSignature checks the quote against its attestation key and the attestation key against the quoting
+// enclave report. The report itself is checked against the PCK certificate by Verify.
+func (q *Quote) verifyQuoteSignature() error {
+	attestationKey, err := parseP256PublicKey(q.AttestationKey)
+	if err != nil {
+		return fmt.Errorf("invalid attestation key: %w", err)
+	}
+	if !verifyP256(attestationKey, q.signedData, q.Signature) {
+		return errors.New("invalid quote signature")
+	}
+
+	expected := sha256.Sum256(append(append([]byte(nil), q.AttestationKey...), q.QeAuthData...))
+	qeReportData := q.QeReport[qeReportDataOff : qeReportDataOff+reportDataSize]
+	if !bytes.Equal(qeReportData[:sha256.Size], expected[:]) || !bytes.Equal(qeReportData[sha256.Size:], make([]byte, reportDataSize-sha256.Size)) {
+		return errors.New("attestation key is not bound to the quoting enclave report")
+	}
+
+	return nil
+}
+
+func (q *Quote) pckCertificates() ([][]byte, error) {
+	var certificates [][]byte
+	rest := q.PckCertChain
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		certificates = append(certificates, block.Bytes)
+	}
+	if len(certificates) == 0 {
+		return nil, errors.New("empty pck certificate chain")
+	}
+
+	return certificates, nil
+}
+
+func parseP256PublicKey(raw []byte) (*ecdsa.PublicKey, error) {
+	if len(raw) != publicKeySize {
+		return nil, errors.New("invalid public key size")
+	}
+
+	publicKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(raw[:32]),
+		Y:     new(big.Int).SetBytes(raw[32:]),
+	}
+	if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+		return nil, errors.New("public key is not on the curve")
+	}
+
+	return publicKey, nil
+}
+
+// verifyP256 checks a raw r||s signature over the SHA-256 of data.
+func verifyP256(publicKey *ecdsa.PublicKey, data []byte, signature []byte) bool {
+	if len(signature) != signatureSize {
+		return false
+	}
+
+	digest := sha256.Sum256(data)
+	return ecdsa.Verify(publicKey, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:]))
+}
+
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = ErrMalformedQuote
+		return nil
+	}
+
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *reader) copy(dst []byte) {
+	copy(dst, r.bytes(len(dst)))
+}
+
+func (r *reader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *reader) uint16() uint16 {
+	value := r.bytes(2)
+	if value == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(value)
+}
+
+func (r *reader) uint32() uint32 {
+	value := r.bytes(4)
+	if value == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(value)
+}
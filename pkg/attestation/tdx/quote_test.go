@@ -0,0 +1,122 @@
+package tdx_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx/tdxtest"
+)
+
+var testNow = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestParseQuote(t *testing.T) {
+	chain := tdxtest.NewChain(t, testNow)
+	reportData := bytes.Repeat([]byte{0xab}, 64)
+	body := tdx.Body{}
+	copy(body.ReportData[:], reportData)
+	raw := chain.Quote(t, body)
+
+	for name, encoded := range map[string][]byte{
+		"binary": raw,
+		"hex":    []byte(hex.EncodeToString(raw)),
+		"0x hex": []byte("0x" + hex.EncodeToString(raw)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			quote, err := tdx.ParseQuote(encoded)
+			require.NoError(t, err)
+
+			assert.Equal(t, uint16(tdx.QuoteVersion4), quote.Header.Version)
+			assert.Equal(t, uint32(tdx.TeeTypeTdx), quote.Header.TeeType)
+			assert.Equal(t, reportData, quote.Body.ReportData[:])
+			assert.Equal(t, []byte("qe auth data"), quote.QeAuthData)
+		})
+	}
+
+	_, err := tdx.ParseQuote(raw[:len(raw)-1])
+	assert.ErrorIs(t, err, tdx.ErrMalformedQuote)
+
+	_, err = tdx.ParseQuote(raw[:100])
+	assert.ErrorIs(t, err, tdx.ErrMalformedQuote)
+}
+
+func TestBody_Debug(t *testing.T) {
+	var body tdx.Body
+	assert.False(t, body.Debug())
+
+	body.TdAttributes[0] = 1
+	assert.True(t, body.Debug())
+}
+
+func TestQuote_Verify(t *testing.T) {
+	chain := tdxtest.NewChain(t, testNow)
+	raw := chain.Quote(t, tdx.Body{})
+	collateral := chain.Collateral()
+
+	t.Run("valid", func(t *testing.T) {
+		quote, err := tdx.ParseQuote(raw)
+		require.NoError(t, err)
+
+		assert.NoError(t, quote.Verify(collateral, testNow))
+		assert.NoError(t, quote.Verify(&tdx.Collateral{RootCa: chain.Root.Der}, testNow))
+		assert.NoError(t, quote.Verify(&tdx.Collateral{
+			RootCa: chain.Root.Pem(),
+			Crls:   [][]byte{chain.Root.Crl(t), chain.Intermediate.Crl(t)},
+		}, testNow))
+	})
+
+	t.Run("tampered report data", func(t *testing.T) {
+		tampered := append([]byte(nil), raw...)
+		tampered[48+520] ^= 1
+
+		quote, err := tdx.ParseQuote(tampered)
+		require.NoError(t, err)
+		assert.ErrorContains(t, quote.Verify(collateral, testNow), "invalid quote signature")
+	})
+
+	t.Run("missing root ca", func(t *testing.T) {
+		quote, err := tdx.ParseQuote(raw)
+		require.NoError(t, err)
+		assert.Error(t, quote.Verify(nil, testNow))
+	})
+
+	t.Run("wrong root ca", func(t *testing.T) {
+		quote, err := tdx.ParseQuote(raw)
+		require.NoError(t, err)
+
+		other := tdxtest.NewChain(t, testNow)
+		assert.ErrorContains(t, quote.Verify(other.Collateral(), testNow), "failed to verify pck certificate chain")
+	})
+
+	t.Run("expired chain", func(t *testing.T) {
+		quote, err := tdx.ParseQuote(raw)
+		require.NoError(t, err)
+		assert.Error(t, quote.Verify(collateral, testNow.Add(2*time.Hour)))
+	})
+
+	t.Run("revoked pck certificate", func(t *testing.T) {
+		quote, err := tdx.ParseQuote(raw)
+		require.NoError(t, err)
+
+		err = quote.Verify(&tdx.Collateral{
+			RootCa: chain.Root.Pem(),
+			Crls:   [][]byte{chain.Intermediate.Crl(t, chain.Pck)},
+		}, testNow)
+		assert.ErrorContains(t, err, "certificate pck is revoked")
+	})
+
+	t.Run("invalid qe report signature", func(t *testing.T) {
+		tampered := append([]byte(nil), raw...)
+		// header, body, signature data size, quote signature, attestation key, certification type and size, qe report
+		tampered[48+584+4+64+64+2+4+384] ^= 1
+
+		quote, err := tdx.ParseQuote(tampered)
+		require.NoError(t, err)
+		assert.ErrorContains(t, quote.Verify(collateral, testNow), "invalid quoting enclave report signature")
+	})
+}
@@ -0,0 +1,171 @@
+// Package tdxtest builds TDX quotes signed by a throwaway certificate chain, for testing code that verifies quotes.
+package tdxtest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+)
+
+// Ca is a certificate of the chain together with its key.
+type Ca struct {
+	Key         *ecdsa.PrivateKey
+	Certificate *x509.Certificate
+	Der         []byte
+
+	now time.Time
+}
+
+func newCa(t testing.TB, name string, serial int64, issuer *Ca, now time.Time) *Ca {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.Certificate, issuer.Key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &Ca{Key: key, Certificate: certificate, Der: der, now: now}
+}
+
+func (c *Ca) Pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Der})
+}
+
+// Crl returns a revocation list issued by the certificate, revoking the given ones.
+func (c *Ca) Crl(t testing.TB, revoked ...*Ca) []byte {
+	var entries []x509.RevocationListEntry
+	for _, certificate := range revoked {
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   certificate.Certificate.SerialNumber,
+			RevocationTime: c.now.Add(-time.Minute),
+		})
+	}
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                c.now.Add(-time.Hour),
+		NextUpdate:                c.now.Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, c.Certificate, c.Key)
+	require.NoError(t, err)
+
+	return crl
+}
+
+// Chain stands in for the Intel SGX root CA, the platform CA and a PCK certificate. Its certificates are valid for an
+// hour around the time it was created for.
+type Chain struct {
+	Root, Intermediate, Pck *Ca
+}
+
+func NewChain(t testing.TB, now time.Time) *Chain {
+	root := newCa(t, "root", 1, nil, now)
+	intermediate := newCa(t, "intermediate", 2, root, now)
+	pck := newCa(t, "pck", 3, intermediate, now)
+
+	return &Chain{Root: root, Intermediate: intermediate, Pck: pck}
+}
+
+// Collateral returns collateral with the chain's root CA.
+func (c *Chain) Collateral() *tdx.Collateral {
+	return &tdx.Collateral{RootCa: c.Root.Pem()}
+}
+
+// Quote returns a version 4 quote of body, signed by a fresh attestation key certified by the chain's PCK certificate.
+func (c *Chain) Quote(t testing.TB, body tdx.Body) []byte {
+	attestationKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rawAttestationKey := make([]byte, 64)
+	attestationKey.X.FillBytes(rawAttestationKey[:32])
+	attestationKey.Y.FillBytes(rawAttestationKey[32:])
+
+	var quote bytes.Buffer
+	quote.Write(le16(tdx.QuoteVersion4))
+	quote.Write(le16(2))
+	quote.Write(le32(tdx.TeeTypeTdx))
+	quote.Write(make([]byte, 4+16+20))
+
+	for _, field := range [][]byte{
+		body.TeeTcbSvn[:], body.MrSeam[:], body.MrSignerSeam[:], body.SeamAttributes[:], body.TdAttributes[:],
+		body.Xfam[:], body.MrTd[:], body.MrConfigId[:], body.MrOwner[:], body.MrOwnerConfig[:],
+		body.Rtmr0[:], body.Rtmr1[:], body.Rtmr2[:], body.Rtmr3[:], body.ReportData[:],
+	} {
+		quote.Write(field)
+	}
+
+	qeAuthData := []byte("qe auth data")
+	qeReport := make([]byte, 384)
+	binding := sha256.Sum256(append(append([]byte(nil), rawAttestationKey...), qeAuthData...))
+	copy(qeReport[320:], binding[:])
+
+	pckChain := append(append(c.Pck.Pem(), c.Intermediate.Pem()...), c.Root.Pem()...)
+
+	var certification bytes.Buffer
+	certification.Write(qeReport)
+	certification.Write(sign(t, c.Pck.Key, qeReport))
+	certification.Write(le16(len(qeAuthData)))
+	certification.Write(qeAuthData)
+	certification.Write(le16(5))
+	certification.Write(le32(len(pckChain)))
+	certification.Write(pckChain)
+
+	var signatureData bytes.Buffer
+	signatureData.Write(sign(t, attestationKey, quote.Bytes()))
+	signatureData.Write(rawAttestationKey)
+	signatureData.Write(le16(6))
+	signatureData.Write(le32(certification.Len()))
+	signatureData.Write(certification.Bytes())
+
+	quote.Write(le32(signatureData.Len()))
+	quote.Write(signatureData.Bytes())
+
+	return quote.Bytes()
+}
+
+func sign(t testing.TB, key *ecdsa.PrivateKey, data []byte) []byte {
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signature
+}
+
+func le16(value int) []byte {
+	return binary.LittleEndian.AppendUint16(nil, uint16(value))
+}
+
+func le32(value int) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(value))
+}
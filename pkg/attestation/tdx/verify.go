@@ -0,0 +1,166 @@
+package tdx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const maxRootCaSize = 64 * 1024
+
+// IntelRootCaUrl serves the Intel SGX provisioning certification root CA, which issues every PCK certificate chain.
+const IntelRootCaUrl = "https://certificates.trustedservices.intel.com/Intel_SGX_Provisioning_Certification_RootCA.der"
+
+// Collateral is what a quote is verified against. TCB info and QE identity are not evaluated, so a valid quote may
+// still come from a platform whose TCB is out of date.
+type Collateral struct {
+	// RootCa is the PEM or DER encoded Intel SGX root CA certificate
+	RootCa []byte `json:"rootCa"`
+	// Crls are PEM or DER encoded revocation lists of the root and PCK CAs
+	Crls [][]byte `json:"crls"`
+}
+
+// LoadCollateral reads collateral from a JSON file with base64 encoded rootCa and crls fields.
+func LoadCollateral(path string) (*Collateral, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collateral: %w", err)
+	}
+
+	var collateral Collateral
+	if err := json.Unmarshal(data, &collateral); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal collateral: %w", err)
+	}
+
+	return &collateral, nil
+}
+
+// DownloadCollateral fetches the Intel root CA from IntelRootCaUrl, without revocation lists.
+func DownloadCollateral(ctx context.Context, httpClient *http.Client) (*Collateral, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, IntelRootCaUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download root ca: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download root ca: unexpected status code %d", resp.StatusCode)
+	}
+
+	rootCa, err := io.ReadAll(io.LimitReader(resp.Body, maxRootCaSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download root ca: %w", err)
+	}
+
+	return &Collateral{RootCa: rootCa}, nil
+}
+
+// Verify checks the quote signature, its binding to the quoting enclave, and the PCK certificate chain up to the
+// collateral's root CA, rejecting revoked certificates.
+func (q *Quote) Verify(collateral *Collateral, now time.Time) error {
+	if collateral == nil || len(collateral.RootCa) == 0 {
+		return errors.New("root ca is required")
+	}
+
+	if err := q.verifyQuoteSignature(); err != nil {
+		return err
+	}
+
+	root, err := x509.ParseCertificate(decodePem(collateral.RootCa))
+	if err != nil {
+		return fmt.Errorf("failed to parse root ca: %w", err)
+	}
+
+	chain, err := q.pckCertificates()
+	if err != nil {
+		return err
+	}
+
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse pck certificate: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	for _, der := range chain[1:] {
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("failed to parse pck certificate chain: %w", err)
+		}
+		intermediates.AddCert(certificate)
+	}
+
+	verifiedChains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify pck certificate chain: %w", err)
+	}
+
+	if err := checkRevocation(verifiedChains[0], collateral.Crls, now); err != nil {
+		return err
+	}
+
+	pckKey, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("pck certificate does not hold an ecdsa key")
+	}
+	if !verifyP256(pckKey, q.QeReport, q.QeReportSignature) {
+		return errors.New("invalid quoting enclave report signature")
+	}
+
+	return nil
+}
+
+// checkRevocation rejects the chain if a certificate is listed by a valid revocation list of its issuer.
+func checkRevocation(chain []*x509.Certificate, crls [][]byte, now time.Time) error {
+	for _, raw := range crls {
+		crl, err := x509.ParseRevocationList(decodePem(raw))
+		if err != nil {
+			return fmt.Errorf("failed to parse crl: %w", err)
+		}
+
+		for i := 0; i+1 < len(chain); i++ {
+			issuer := chain[i+1]
+			if crl.CheckSignatureFrom(issuer) != nil {
+				continue
+			}
+			if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+				return fmt.Errorf("crl of %s is outdated", issuer.Subject.CommonName)
+			}
+
+			for _, revoked := range crl.RevokedCertificateEntries {
+				if revoked.SerialNumber.Cmp(chain[i].SerialNumber) == 0 {
+					return fmt.Errorf("certificate %s is revoked", chain[i].Subject.CommonName)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func decodePem(data []byte) []byte {
+	if block, _ := pem.Decode(data); block != nil {
+		return block.Bytes
+	}
+	return data
+}
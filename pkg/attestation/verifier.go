@@ -0,0 +1,273 @@
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+	contractYayoiFactory "github.com/NethermindEth/yayois-garden/pkg/bindings/YayoiFactory"
+)
+
+const (
+	maxResponseSize = 1 << 20
+	nonceSize       = 32
+)
+
+type VerifierEthClient interface {
+	bind.ContractCaller
+	ethereum.ChainIDReader
+}
+
+type VerifierConfig struct {
+	HttpClient *http.Client
+	// EthClient checks that the agent is an authorized signer of the factory. Verification skips the check if nil.
+	EthClient      VerifierEthClient
+	FactoryAddress common.Address
+	// ChainId is read from EthClient if nil
+	ChainId    *big.Int
+	Collateral *tdx.Collateral
+	// AllowedMeasurements are the agent builds a quote may come from. At least one is required, as a genuine quote
+	// alone only proves that some code runs in some TD.
+	AllowedMeasurements []tdx.Measurement
+}
+
+// AgentInfo is what an agent serves next to its quote: its wallet address, the RSA key prompts are sealed to and its
+// configuration document.
+type AgentInfo struct {
+	Address      common.Address
+	RsaPublicKey *rsa.PublicKey
+	Config       []byte
+}
+
+type Result struct {
+	Quote            *tdx.Quote
+	Measurement      tdx.Measurement
+	ReportData       *ReportData
+	AuthorizedSigner bool
+}
+
+// Verifier checks that a quote is genuine and commits to the keys and configuration an agent serves.
+type Verifier struct {
+	httpClient     *http.Client
+	ethClient      VerifierEthClient
+	factoryAddress common.Address
+	chainId        *big.Int
+	collateral     *tdx.Collateral
+
+	allowedMeasurements []tdx.Measurement
+}
+
+func NewVerifier(config VerifierConfig) (*Verifier, error) {
+	if config.ChainId == nil && config.EthClient == nil {
+		return nil, errors.New("chain id or eth client is required")
+	}
+	if len(config.AllowedMeasurements) == 0 {
+		return nil, errors.New("at least one allowed measurement is required")
+	}
+
+	httpClient := config.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Verifier{
+		httpClient:     httpClient,
+		ethClient:      config.EthClient,
+		factoryAddress: config.FactoryAddress,
+		chainId:        config.ChainId,
+		collateral:     config.Collateral,
+
+		allowedMeasurements: config.AllowedMeasurements,
+	}, nil
+}
+
+// VerifyAgent requests a quote for a random nonce from a running agent and verifies it together with the agent info,
+// proving that the agent is live rather than replaying an old quote.
+func (v *Verifier) VerifyAgent(ctx context.Context, agentUrl string) (*Result, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	rawQuote, err := v.get(ctx, agentUrl, "/quote?nonce="+hex.EncodeToString(nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	var freshQuote struct {
+		Quote string `json:"quote"`
+	}
+	if err := json.Unmarshal(rawQuote, &freshQuote); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote: %w", err)
+	}
+
+	agentInfo, err := v.FetchAgentInfo(ctx, agentUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.VerifyQuote(ctx, []byte(freshQuote.Quote), agentInfo, nonce)
+}
+
+func (v *Verifier) FetchAgentInfo(ctx context.Context, agentUrl string) (*AgentInfo, error) {
+	address, err := v.get(ctx, agentUrl, "/address")
+	if err != nil {
+		return nil, err
+	}
+	if !common.IsHexAddress(string(address)) {
+		return nil, fmt.Errorf("invalid agent address %q", address)
+	}
+
+	rawPublicKey, err := v.get(ctx, agentUrl, "/pubkey")
+	if err != nil {
+		return nil, err
+	}
+	rsaPublicKey, err := ParseRsaPublicKey(rawPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := v.get(ctx, agentUrl, "/config")
+	if err != nil {
+		return nil, err
+	}
+
+	return &AgentInfo{
+		Address:      common.HexToAddress(string(address)),
+		RsaPublicKey: rsaPublicKey,
+		Config:       config,
+	}, nil
+}
+
+// VerifyQuote verifies a quote against the collateral, checks that it comes from an allowlisted measurement of a
+// non-debug TD and that its report data commits to agentInfo, and to nonce if it is not empty.
+func (v *Verifier) VerifyQuote(ctx context.Context, rawQuote []byte, agentInfo *AgentInfo, nonce []byte) (*Result, error) {
+	quote, err := tdx.ParseQuote(rawQuote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quote: %w", err)
+	}
+
+	if err := quote.Verify(v.collateral, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to verify quote: %w", err)
+	}
+
+	if quote.Body.Debug() {
+		return nil, errors.New("quote is from a debug TD")
+	}
+
+	measurement := tdx.MeasurementOf(&quote.Body)
+	if !measurement.AllowedBy(v.allowedMeasurements) {
+		return nil, fmt.Errorf("%w: %s", tdx.ErrMeasurementNotAllowed, measurement)
+	}
+
+	chainId := v.chainId
+	if chainId == nil {
+		chainId, err = v.ethClient.ChainID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chain id: %w", err)
+		}
+	}
+
+	rsaPublicKeyHash, err := HashRsaPublicKey(agentInfo.RsaPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	reportData := &ReportData{
+		Version:          ReportDataVersion1,
+		Address:          agentInfo.Address,
+		FactoryAddress:   v.factoryAddress,
+		ChainId:          chainId,
+		RsaPublicKeyHash: rsaPublicKeyHash,
+		ConfigHash:       HashConfig(agentInfo.Config),
+	}
+	if len(nonce) > 0 {
+		reportData = reportData.WithNonce(nonce)
+	}
+
+	expected, err := reportData.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(quote.Body.ReportData[:], expected) {
+		return nil, fmt.Errorf("report data %s does not commit to the agent, expected %s", hex.EncodeToString(quote.Body.ReportData[:]), hex.EncodeToString(expected))
+	}
+
+	result := &Result{
+		Quote:       quote,
+		Measurement: measurement,
+		ReportData:  reportData,
+	}
+
+	if v.ethClient != nil {
+		factory, err := contractYayoiFactory.NewContractYayoiFactoryCaller(v.factoryAddress, v.ethClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create factory: %w", err)
+		}
+
+		result.AuthorizedSigner, err = factory.IsAuthorizedSigner(&bind.CallOpts{Context: ctx}, agentInfo.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check authorized signer: %w", err)
+		}
+		if !result.AuthorizedSigner {
+			return result, fmt.Errorf("agent %s is not an authorized signer of factory %s", agentInfo.Address, v.factoryAddress)
+		}
+	}
+
+	return result, nil
+}
+
+// ParseRsaPublicKey reads an RSA public key in the format served by /pubkey, with n and e as decimal strings.
+func ParseRsaPublicKey(data []byte) (*rsa.PublicKey, error) {
+	var publicKey map[string]string
+	if err := json.Unmarshal(data, &publicKey); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rsa public key: %w", err)
+	}
+
+	n, ok := new(big.Int).SetString(publicKey["n"], 10)
+	if !ok {
+		return nil, errors.New("invalid rsa modulus")
+	}
+
+	e, err := strconv.Atoi(publicKey["e"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid rsa exponent: %w", err)
+	}
+
+	return &rsa.PublicKey{N: n, E: e}, nil
+}
+
+func (v *Verifier) get(ctx context.Context, agentUrl string, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(agentUrl, "/")+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get %s: unexpected status code %d", path, resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
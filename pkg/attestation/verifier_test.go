@@ -0,0 +1,22 @@
+package attestation_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/NethermindEth/yayois-garden/pkg/attestation"
+	"github.com/NethermindEth/yayois-garden/pkg/attestation/tdx"
+)
+
+func TestNewVerifier(t *testing.T) {
+	_, err := attestation.NewVerifier(attestation.VerifierConfig{ChainId: big.NewInt(8453)})
+	assert.ErrorContains(t, err, "allowed measurement")
+
+	_, err = attestation.NewVerifier(attestation.VerifierConfig{
+		ChainId:             big.NewInt(8453),
+		AllowedMeasurements: []tdx.Measurement{{}},
+	})
+	assert.NoError(t, err)
+}
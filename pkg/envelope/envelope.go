@@ -0,0 +1,154 @@
+// Package envelope implements the hybrid encryption format used for system prompts. A random AES-256-GCM key
+// encrypts the prompt and is itself wrapped with the agent's RSA key using OAEP with SHA-256, so prompts are not
+// limited to the size of a single RSA block.
+//
+// Layout, with lengths in bytes:
+//
+//	magic "YGE" (3) | version (1) | wrapped key length, big endian (2) | wrapped key | nonce (12) | ciphertext and tag
+//
+// The magic and version are authenticated as additional data.
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	Version1 byte = 1
+
+	keySize   = 32
+	nonceSize = 12
+)
+
+var magic = []byte("YGE")
+
+var (
+	ErrNotEnvelope        = errors.New("data is not an envelope")
+	ErrUnsupportedVersion = errors.New("unsupported envelope version")
+	ErrMalformed          = errors.New("malformed envelope")
+)
+
+// IsEnvelope reports whether data starts with the envelope magic.
+func IsEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts plaintext to the public key.
+func Seal(publicKey *rsa.PublicKey, plaintext []byte) ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap key: %w", err)
+	}
+
+	aead, err := newAead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := append(append([]byte{}, magic...), Version1)
+
+	var out bytes.Buffer
+	out.Write(header)
+	binary.Write(&out, binary.BigEndian, uint16(len(wrappedKey)))
+	out.Write(wrappedKey)
+	out.Write(nonce)
+	out.Write(aead.Seal(nil, nonce, plaintext, header))
+
+	return out.Bytes(), nil
+}
+
+// Open decrypts an envelope with the private key.
+func Open(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	if !IsEnvelope(data) {
+		return nil, ErrNotEnvelope
+	}
+	header, wrappedKey, nonce, ciphertext, err := split(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, wrappedKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap key: %w", err)
+	}
+
+	aead, err := newAead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// WrappedKey returns the wrapped content key of an envelope. Every envelope is sealed with a fresh content key, so the
+// wrapped key identifies the sealed prompt wherever the envelope is hosted.
+func WrappedKey(data []byte) ([]byte, error) {
+	if !IsEnvelope(data) {
+		return nil, ErrNotEnvelope
+	}
+
+	_, wrappedKey, _, _, err := split(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrappedKey, nil
+}
+
+func split(data []byte) (header, wrappedKey, nonce, ciphertext []byte, err error) {
+	if len(data) < len(magic)+3 {
+		return nil, nil, nil, nil, ErrMalformed
+	}
+
+	header = data[:len(magic)+1]
+	if version := header[len(magic)]; version != Version1 {
+		return nil, nil, nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	rest := data[len(header):]
+	wrappedKeyLength := int(binary.BigEndian.Uint16(rest))
+	rest = rest[2:]
+	if len(rest) < wrappedKeyLength+nonceSize {
+		return nil, nil, nil, nil, ErrMalformed
+	}
+
+	return header, rest[:wrappedKeyLength], rest[wrappedKeyLength : wrappedKeyLength+nonceSize], rest[wrappedKeyLength+nonceSize:], nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+
+	return aead, nil
+}
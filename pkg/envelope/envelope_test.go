@@ -0,0 +1,85 @@
+package envelope_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NethermindEth/yayois-garden/pkg/envelope"
+)
+
+var rsaPrivateKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+func TestSealOpen(t *testing.T) {
+	// Well beyond the ~190 bytes a single 2048-bit RSA-OAEP block can hold
+	plaintext := []byte(strings.Repeat("a paragraph long system prompt. ", 100))
+
+	sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, plaintext)
+	require.NoError(t, err)
+	assert.True(t, envelope.IsEnvelope(sealed))
+
+	opened, err := envelope.Open(rsaPrivateKey, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, opened)
+}
+
+func TestOpen_Errors(t *testing.T) {
+	sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte("system prompt"))
+	require.NoError(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	unsupported := append([]byte{}, sealed...)
+	unsupported[3] = 2
+
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		data    []byte
+		wantErr error
+	}{
+		{name: "not an envelope", key: rsaPrivateKey, data: []byte("plain text"), wantErr: envelope.ErrNotEnvelope},
+		{name: "unsupported version", key: rsaPrivateKey, data: unsupported, wantErr: envelope.ErrUnsupportedVersion},
+		{name: "truncated", key: rsaPrivateKey, data: sealed[:100], wantErr: envelope.ErrMalformed},
+		{name: "tampered ciphertext", key: rsaPrivateKey, data: tampered},
+		{name: "wrong key", key: otherKey, data: sealed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := envelope.Open(tt.key, tt.data)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWrappedKey(t *testing.T) {
+	sealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte("system prompt"))
+	require.NoError(t, err)
+	resealed, err := envelope.Seal(&rsaPrivateKey.PublicKey, []byte("system prompt"))
+	require.NoError(t, err)
+
+	wrappedKey, err := envelope.WrappedKey(sealed)
+	require.NoError(t, err)
+	assert.Len(t, wrappedKey, rsaPrivateKey.Size())
+
+	otherWrappedKey, err := envelope.WrappedKey(resealed)
+	require.NoError(t, err)
+	assert.NotEqual(t, wrappedKey, otherWrappedKey)
+
+	_, err = envelope.WrappedKey([]byte("plain text"))
+	assert.ErrorIs(t, err, envelope.ErrNotEnvelope)
+	_, err = envelope.WrappedKey(sealed[:10])
+	assert.ErrorIs(t, err, envelope.ErrMalformed)
+}